@@ -0,0 +1,272 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// An ApplyFunc is invoked by Apply for each expression, see Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses an expression(AST) recursively, starting with expr, and calls pre and post for each expression.
+// Either of pre and post may be nil.
+//
+// pre is called for each expression before its children are traversed(pre-order). If pre returns false,
+// no children are traversed, and post is not called for that expression.
+//
+// post is called for each expression after its children are traversed(post-order).
+//
+// Apply modifies the expressions in place and returns the root, which may be a new expression if the root
+// was replaced, or nil if everything was deleted. The children of an expression replaced in pre are traversed,
+// the expressions inserted by InsertBefore and InsertAfter are not.
+//
+// The expressions inserted around the expression of a parenthesis or nested expression(e.g. `a` of `(a)`)
+// or the values of a value list expression(e.g. `a` of `f: (a)`) are combined with it by AND.
+//
+// A deleted expression is removed together with the expressions that can not exist without it:
+// the combination expression is collapsed into the remaining operand, the parenthesis or nested expression
+// around it and the binary, value list or field existence expression that it is the field or value of are deleted.
+// A combination expression with a nil operand is collapsed in the same way.
+func Apply(expr Expr, pre, post ApplyFunc) Expr {
+	a := &application{pre: pre, post: post}
+
+	result, _, _ := a.apply(nil, "", expr)
+
+	return result
+}
+
+// A Cursor describes an expression encountered during Apply.
+// Information about the expression and its parent is available from the Expr, Parent and Name methods.
+type Cursor struct {
+	parent Expr
+	name   string
+	expr   Expr
+
+	before, after []Expr
+}
+
+// Expr returns the current expression, it is nil if the expression was deleted.
+func (c *Cursor) Expr() Expr {
+	return c.expr
+}
+
+// Parent returns the parent of the current expression, it is nil for the root.
+func (c *Cursor) Parent() Expr {
+	return c.parent
+}
+
+// Name returns the name of the parent field that contains the current expression,
+// e.g. "LeftExpr", "RightExpr", "Field", "Value", "Values", "Path" or "Expr". It is empty for the root.
+func (c *Cursor) Name() string {
+	return c.name
+}
+
+// Replace replaces the current expression with expr.
+func (c *Cursor) Replace(expr Expr) {
+	c.expr = expr
+}
+
+// Delete deletes the current expression.
+func (c *Cursor) Delete() {
+	c.expr = nil
+}
+
+// InsertBefore inserts expr before the current expression, it is combined using the keyword of the parent
+// (AND for the left operand of `a NOT b`), or AND if the current expression is the expression of a parenthesis
+// or nested expression or the values of a value list expression. It panics otherwise, e.g. at the root.
+func (c *Cursor) InsertBefore(expr Expr) {
+	c.checkInsert("InsertBefore")
+	c.before = append(c.before, expr)
+}
+
+// InsertAfter inserts expr after the current expression, it is combined in the same way as InsertBefore.
+// It panics if the current expression can not be combined, e.g. at the root.
+func (c *Cursor) InsertAfter(expr Expr) {
+	c.checkInsert("InsertAfter")
+	c.after = append(c.after, expr)
+}
+
+func (c *Cursor) checkInsert(method string) {
+	switch c.parent.(type) {
+	case *CombineExpr:
+		return
+	case *ParenExpr, *NestedExpr:
+		if c.name == "Expr" {
+			return
+		}
+	case *ValueListExpr:
+		if c.name == "Values" {
+			return
+		}
+	}
+
+	panic(fmt.Sprintf("ast: %s can not insert around the %q of %T", method, c.name, c.parent))
+}
+
+type application struct {
+	pre, post ApplyFunc
+}
+
+// apply applies to expr in field name of parent, and returns the result with the inserted expressions.
+func (a *application) apply(parent Expr, name string, expr Expr) (result Expr, before, after []Expr) {
+	if expr == nil {
+		return nil, nil, nil
+	}
+
+	c := &Cursor{parent: parent, name: name, expr: expr}
+	if a.pre != nil && !a.pre(c) {
+		return c.expr, c.before, c.after
+	}
+
+	if c.expr != nil {
+		c.expr = a.applyChildren(c.expr)
+	}
+
+	if c.expr != nil && a.post != nil {
+		a.post(c)
+	}
+
+	return c.expr, c.before, c.after
+}
+
+// applyChildren applies to the children of expr, it returns nil if expr can not exist without them.
+func (a *application) applyChildren(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Field != nil {
+			if e.Field, _, _ = a.apply(e, "Field", e.Field); e.Field == nil {
+				return nil
+			}
+		}
+
+		if e.Value != nil {
+			if e.Value, _, _ = a.apply(e, "Value", e.Value); e.Value == nil {
+				return nil
+			}
+		}
+	case *CombineExpr:
+		return a.applyCombine(e)
+	case *ParenExpr:
+		if e.Expr = a.applyAnd(e, "Expr", e.Expr); e.Expr == nil {
+			return nil
+		}
+	case *NestedExpr:
+		if e.Path, _, _ = a.apply(e, "Path", e.Path); e.Path == nil {
+			return nil
+		}
+
+		if e.Expr = a.applyAnd(e, "Expr", e.Expr); e.Expr == nil {
+			return nil
+		}
+	case *ExistsExpr:
+		if e.Field, _, _ = a.apply(e, "Field", e.Field); e.Field == nil {
+			return nil
+		}
+	case *ValueListExpr:
+		if e.Field, _, _ = a.apply(e, "Field", e.Field); e.Field == nil {
+			return nil
+		}
+
+		if e.Values = a.applyAnd(e, "Values", e.Values); e.Values == nil {
+			return nil
+		}
+	case *Literal, *WildcardExpr, *DateExpr, *BadExpr:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Apply: unexpected expression type %T", e))
+	}
+
+	return expr
+}
+
+// applyAnd applies to expr in field name of parent, and combines the result with the inserted expressions by AND.
+func (a *application) applyAnd(parent Expr, name string, expr Expr) Expr {
+	result, before, after := a.apply(parent, name, expr)
+
+	return combineOperands(joinOperands(before, result, after), token.TokenKindKeywordAnd)
+}
+
+func (a *application) applyCombine(e *CombineExpr) Expr {
+	left, leftBefore, leftAfter := a.apply(e, "LeftExpr", e.LeftExpr)
+	right, rightBefore, rightAfter := a.apply(e, "RightExpr", e.RightExpr)
+
+	inserted := len(leftBefore)+len(leftAfter)+len(rightBefore)+len(rightAfter) > 0
+	if left != nil && right != nil && !inserted {
+		e.LeftExpr, e.RightExpr = left, right
+
+		return e
+	}
+
+	leftOperands := joinOperands(leftBefore, left, leftAfter)
+	rightOperands := joinOperands(rightBefore, right, rightAfter)
+
+	if e.Keyword != token.TokenKindKeywordNot {
+		return combineOperands(append(leftOperands, rightOperands...), e.Keyword)
+	}
+
+	// `a NOT b` means `a AND NOT b`, the left operands are positive and the right operands are negative
+	result := combineOperands(leftOperands, token.TokenKindKeywordAnd)
+	for _, operand := range rightOperands {
+		if result == nil {
+			result = negate(operand)
+
+			continue
+		}
+
+		result = NewCombineExpr(result, token.TokenKindKeywordNot, operand)
+	}
+
+	return result
+}
+
+func joinOperands(before []Expr, expr Expr, after []Expr) []Expr {
+	operands := append([]Expr{}, before...)
+	if expr != nil {
+		operands = append(operands, expr)
+	}
+
+	return append(operands, after...)
+}
+
+// combineOperands combines operands from left to right with keyword, it returns nil if there is no operand.
+func combineOperands(operands []Expr, keyword token.Kind) Expr {
+	if len(operands) == 0 {
+		return nil
+	}
+
+	result := operands[0]
+	for _, operand := range operands[1:] {
+		result = NewCombineExpr(result, keyword, operand)
+	}
+
+	return result
+}
+
+// negate returns the negation of expr.
+func negate(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		negated := *e
+		negated.HasNot = !e.HasNot
+
+		return &negated
+	case *NestedExpr:
+		negated := *e
+		negated.HasNot = !e.HasNot
+
+		return &negated
+	case *ExistsExpr:
+		negated := *e
+		negated.HasNot = !e.HasNot
+
+		return &negated
+	case *ValueListExpr:
+		negated := *e
+		negated.HasNot = !e.HasNot
+
+		return &negated
+	}
+
+	return NewBinaryExpr(expr.Pos(), nil, 0, NewParenExpr(expr.Pos(), expr.End(), expr), true)
+}
@@ -0,0 +1,267 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func newLiteralClause(field, value string) *ast.BinaryExpr {
+	return ast.NewBinaryExpr(0, newField(field), token.TokenKindOperatorEql,
+		ast.NewLiteral(0, 0, token.TokenKindIdent, value, nil), false)
+}
+
+func newField(field string) *ast.Literal {
+	return ast.NewLiteral(0, 0, token.TokenKindIdent, field, nil)
+}
+
+// deleteField returns an ApplyFunc that deletes the binary expressions of field.
+func deleteField(field string) ast.ApplyFunc {
+	return func(c *ast.Cursor) bool {
+		if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == field {
+			c.Delete()
+		}
+
+		return true
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name string
+		expr func() ast.Expr
+		pre  ast.ApplyFunc
+		post ast.ApplyFunc
+		want string
+	}{
+		{
+			name: "rename field",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordAnd, newLiteralClause("b", "2"))
+			},
+			pre: func(c *ast.Cursor) bool {
+				if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == "a" {
+					e.Field = newField("renamed")
+				}
+
+				return true
+			},
+			want: "renamed: 1 AND b: 2",
+		},
+		{
+			name: "replace value",
+			expr: func() ast.Expr {
+				return newLiteralClause("a", "1")
+			},
+			post: func(c *ast.Cursor) bool {
+				if c.Name() == "Value" {
+					c.Replace(ast.NewLiteral(0, 0, token.TokenKindString, "replaced", nil))
+				}
+
+				return true
+			},
+			want: `a: "replaced"`,
+		},
+		{
+			name: "wrap in parenthesis",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(
+					ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordOr, newLiteralClause("b", "2")),
+					token.TokenKindKeywordAnd,
+					newLiteralClause("c", "3"),
+				)
+			},
+			pre: func(c *ast.Cursor) bool {
+				if e, ok := c.Expr().(*ast.CombineExpr); ok && e.Keyword == token.TokenKindKeywordOr {
+					c.Replace(ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, e), false))
+
+					return false
+				}
+
+				return true
+			},
+			want: "(a: 1 OR b: 2) AND c: 3",
+		},
+		{
+			name: "delete collapses combination",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(
+					ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordAnd, newLiteralClause("b", "2")),
+					token.TokenKindKeywordOr,
+					newLiteralClause("c", "3"),
+				)
+			},
+			pre:  deleteField("b"),
+			want: "a: 1 OR c: 3",
+		},
+		{
+			name: "delete collapses parenthesis and value",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(
+					newLiteralClause("a", "1"),
+					token.TokenKindKeywordAnd,
+					ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, newLiteralClause("b", "2")), true),
+				)
+			},
+			pre:  deleteField("b"),
+			want: "a: 1",
+		},
+		{
+			name: "delete left operand of NOT",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordNot, newLiteralClause("b", "2"))
+			},
+			pre:  deleteField("a"),
+			want: "NOT b: 2",
+		},
+		{
+			name: "delete everything",
+			expr: func() ast.Expr {
+				return ast.NewNestedExpr(0, newField("items"), 0, 0, newLiteralClause("a", "1"), false)
+			},
+			pre:  deleteField("a"),
+			want: "",
+		},
+		{
+			name: "insert before and after",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordOr, newLiteralClause("b", "2"))
+			},
+			pre: func(c *ast.Cursor) bool {
+				if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == "b" {
+					c.InsertBefore(newLiteralClause("x", "1"))
+					c.InsertAfter(newLiteralClause("y", "2"))
+				}
+
+				return true
+			},
+			want: "a: 1 OR x: 1 OR b: 2 OR y: 2",
+		},
+		{
+			name: "insert around operands of NOT",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordNot, newLiteralClause("b", "2"))
+			},
+			pre: func(c *ast.Cursor) bool {
+				if _, ok := c.Parent().(*ast.CombineExpr); ok {
+					c.InsertAfter(newLiteralClause("x", c.Name()))
+				}
+
+				return true
+			},
+			want: "a: 1 AND x: LeftExpr NOT b: 2 NOT x: RightExpr",
+		},
+		{
+			name: "insert into parentheses",
+			expr: func() ast.Expr {
+				return ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, newLiteralClause("a", "1")), false)
+			},
+			pre: func(c *ast.Cursor) bool {
+				if _, ok := c.Parent().(*ast.ParenExpr); ok {
+					c.InsertBefore(newLiteralClause("x", "1"))
+					c.InsertAfter(newLiteralClause("y", "2"))
+				}
+
+				return true
+			},
+			want: "(x: 1 AND a: 1 AND y: 2)",
+		},
+		{
+			name: "insert into nested field query",
+			expr: func() ast.Expr {
+				return ast.NewNestedExpr(0, newField("items"), 0, 0, ast.NewCombineExpr(
+					newLiteralClause("a", "1"), token.TokenKindKeywordOr, newLiteralClause("b", "2"),
+				), false)
+			},
+			pre: func(c *ast.Cursor) bool {
+				if c.Name() == "Expr" {
+					c.InsertAfter(newLiteralClause("x", "1"))
+				}
+
+				return true
+			},
+			want: "items: { (a: 1 OR b: 2) AND x: 1 }",
+		},
+		{
+			name: "insert into value list",
+			expr: func() ast.Expr {
+				value := ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 0, token.TokenKindIdent, "a", nil), false)
+
+				return ast.NewValueListExpr(0, newField("f"), token.TokenKindOperatorEql, 0, 0, value, false)
+			},
+			pre: func(c *ast.Cursor) bool {
+				if c.Name() == "Values" {
+					c.Delete()
+					c.InsertAfter(ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 0, token.TokenKindIdent, "b", nil), false))
+				}
+
+				return true
+			},
+			want: "f: (b)",
+		},
+		{
+			name: "dangling combination",
+			expr: func() ast.Expr {
+				return ast.NewCombineExpr(newLiteralClause("a", "1"), token.TokenKindKeywordAnd, nil)
+			},
+			want: "a: 1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ast.Apply(c.expr(), c.pre, c.post)
+			if c.want == "" {
+				assert.Nil(t, got)
+
+				return
+			}
+
+			assert.Equal(t, c.want, got.String())
+		})
+	}
+}
+
+func TestApply_Skip(t *testing.T) {
+	var visited []string
+
+	ast.Apply(ast.NewCombineExpr(
+		ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, newLiteralClause("a", "1")), false),
+		token.TokenKindKeywordAnd,
+		newLiteralClause("b", "2"),
+	), func(c *ast.Cursor) bool {
+		_, isParen := c.Expr().(*ast.ParenExpr)
+
+		return !isParen
+	}, func(c *ast.Cursor) bool {
+		if e, ok := c.Expr().(*ast.BinaryExpr); ok {
+			visited = append(visited, e.String())
+		}
+
+		return true
+	})
+	assert.Equal(t, []string{"(a: 1)", "b: 2"}, visited)
+}
+
+func TestApply_InsertWithoutCombination(t *testing.T) {
+	assert.Panics(t, func() {
+		ast.Apply(newLiteralClause("a", "1"), func(c *ast.Cursor) bool {
+			c.InsertBefore(newLiteralClause("b", "2"))
+
+			return true
+		}, nil)
+	})
+
+	assert.Panics(t, func() {
+		ast.Apply(newLiteralClause("a", "1"), func(c *ast.Cursor) bool {
+			if c.Name() == "Value" {
+				c.InsertAfter(ast.NewLiteral(0, 0, token.TokenKindIdent, "b", nil))
+			}
+
+			return true
+		}, nil)
+	})
+}
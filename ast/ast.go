@@ -1,6 +1,8 @@
 package ast
 
 // Expr represents an expression in KQL.
+//
+// Positions are rune(character) offsets into the query, use ByteOffset to convert them into byte offsets.
 type Expr interface {
 	// Pos returns the position of the expression.
 	Pos() int
@@ -11,3 +13,29 @@ type Expr interface {
 	// String returns the string representation of the expression.
 	String() string
 }
+
+// ByteOffset converts the position pos(rune offset) of an expression in query into the byte offset,
+// e.g. query[ByteOffset(query, expr.Pos()):ByteOffset(query, expr.End())] is the text of expr.
+func ByteOffset(query string, pos int) int {
+	for offset := range query {
+		if pos == 0 {
+			return offset
+		}
+
+		pos--
+	}
+
+	return len(query)
+}
+
+// fieldName returns the unescaped value of a field(*Literal or *WildcardExpr).
+func fieldName(field Expr) string {
+	switch f := field.(type) {
+	case *Literal:
+		return f.Value
+	case *WildcardExpr:
+		return f.Value
+	}
+
+	return ""
+}
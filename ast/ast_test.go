@@ -0,0 +1,45 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+func TestByteOffset(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{query: `日志: "错误" AND 🔥`, want: []string{`日志: "错误" AND 🔥`, `日志: "错误"`, `日志`, `"错误"`, `🔥`, `🔥`}},
+		{query: "\r\n name: café", want: []string{"name: café", "name", "café"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			expr, err := parser.New(c.query).Stmt()
+			require.NoError(t, err)
+
+			var got []string
+
+			ast.Inspect(expr, func(e ast.Expr) bool {
+				if e == nil {
+					return false
+				}
+
+				got = append(got, c.query[ast.ByteOffset(c.query, e.Pos()):ast.ByteOffset(c.query, e.End())])
+
+				return true
+			})
+			assert.Equal(t, c.want, got)
+		})
+	}
+
+	assert.Equal(t, 0, ast.ByteOffset("", 1))
+	assert.Equal(t, 3, ast.ByteOffset("日志", 1))
+	assert.Equal(t, 6, ast.ByteOffset("日志", 5))
+}
@@ -0,0 +1,37 @@
+package ast
+
+// BadExpr is a placeholder for the source that can not be parsed, it is produced by the parser
+// in recovery mode(see parser.WithRecovery), so a partial expression(AST) can still be built.
+//
+// Example:
+//
+//	`f1: ` of `f1: AND f2: v2`
+type BadExpr struct {
+	pos   int
+	end   int
+	Value string // the source text that can not be parsed
+}
+
+// NewBadExpr creates a new bad expression.
+func NewBadExpr(pos, end int, value string) *BadExpr {
+	return &BadExpr{
+		pos:   pos,
+		end:   end,
+		Value: value,
+	}
+}
+
+// Pos returns the position of the bad expression.
+func (e *BadExpr) Pos() int {
+	return e.pos
+}
+
+// End returns the end position of the bad expression.
+func (e *BadExpr) End() int {
+	return e.end
+}
+
+// String returns the source text of the bad expression.
+func (e *BadExpr) String() string {
+	return e.Value
+}
@@ -0,0 +1,27 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/laojianzi/kql-go/ast"
+)
+
+func TestBadExpr(t *testing.T) {
+	expr := ast.NewBadExpr(4, 8, `f1: `)
+	assert.Equal(t, 4, expr.Pos())
+	assert.Equal(t, 8, expr.End())
+	assert.Equal(t, `f1: `, expr.String())
+
+	var visited []ast.Expr
+
+	ast.Inspect(expr, func(e ast.Expr) bool {
+		if e != nil {
+			visited = append(visited, e)
+		}
+
+		return true
+	})
+	assert.Equal(t, []ast.Expr{expr}, visited)
+}
@@ -13,14 +13,14 @@ import (
 //	`NOT f1: "v1"`
 type BinaryExpr struct {
 	pos      int
-	Field    string
+	Field    Expr // *Literal or *WildcardExpr(e.g. `machine.os*`), nil for a value without field
 	Operator token.Kind
 	Value    Expr
 	HasNot   bool
 }
 
 // NewBinaryExpr creates a new binary expression.
-func NewBinaryExpr(pos int, field string, operator token.Kind, value Expr, hasNot bool) *BinaryExpr {
+func NewBinaryExpr(pos int, field Expr, operator token.Kind, value Expr, hasNot bool) *BinaryExpr {
 	return &BinaryExpr{
 		pos:      pos,
 		Field:    field,
@@ -35,15 +35,17 @@ func (e *BinaryExpr) Pos() int {
 	return e.pos
 }
 
-// End returns the end position of the binary expression.
+// End returns the end position of the binary expression, which is the end of the value.
+// The end of a string value is after its closing double quote, e.g. 8 of `f1: "v1"`.
 func (e *BinaryExpr) End() int {
-	if e.Value.End() < len(e.Value.String()) { // e.g. string values with double quotes
-		return e.Value.End() + 1
-	}
-
 	return e.Value.End()
 }
 
+// FieldName returns the unescaped name of the field, it is empty for a value without field.
+func (e *BinaryExpr) FieldName() string {
+	return fieldName(e.Field)
+}
+
 // String returns the string representation of the binary expression.
 func (e *BinaryExpr) String() string {
 	var buf strings.Builder
@@ -51,8 +53,8 @@ func (e *BinaryExpr) String() string {
 		buf.WriteString("NOT ")
 	}
 
-	if e.Field != "" {
-		buf.WriteString(e.Field)
+	if e.Field != nil {
+		buf.WriteString(e.Field.String())
 
 		if e.Operator != token.TokenKindOperatorEql {
 			buf.WriteByte(' ')
@@ -4,15 +4,17 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
 	"github.com/laojianzi/kql-go/token"
 )
 
 func TestBinaryExpr(t *testing.T) {
 	type args struct {
 		pos      int
-		field    string
+		field    ast.Expr
 		operator token.Kind
 		value    ast.Expr
 		hasNot   bool
@@ -48,7 +50,7 @@ func TestBinaryExpr(t *testing.T) {
 		{
 			name: `f1: "v1"`,
 			args: args{
-				field:    "f1",
+				field:    ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil),
 				operator: token.TokenKindOperatorEql,
 				value:    ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil),
 				hasNot:   false,
@@ -60,7 +62,7 @@ func TestBinaryExpr(t *testing.T) {
 			name: `NOT f1: "v1"`,
 			args: args{
 				pos:      0,
-				field:    "f1",
+				field:    ast.NewLiteral(4, 6, token.TokenKindIdent, "f1", nil),
 				operator: token.TokenKindOperatorEql,
 				value:    ast.NewLiteral(8, 12, token.TokenKindString, "v1", nil),
 				hasNot:   true,
@@ -79,3 +81,22 @@ func TestBinaryExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestBinaryExpr_End(t *testing.T) {
+	// the end of a quoted value is after the closing double quote, the positions are in runes
+	for _, query := range []string{`"a b"`, `NOT "a b"`, `f: "a\"b"`, `f: "日志" AND g: x`, `"日志" OR g: "x"`, `f: ("a" OR "b")`} {
+		t.Run(query, func(t *testing.T) {
+			expr, err := parser.New(query).Stmt()
+			require.NoError(t, err)
+
+			ast.Inspect(expr, func(e ast.Expr) bool {
+				if binary, ok := e.(*ast.BinaryExpr); ok {
+					text := query[ast.ByteOffset(query, binary.Pos()):ast.ByteOffset(query, binary.End())]
+					assert.Equal(t, binary.String(), text)
+				}
+
+				return e != nil
+			})
+		})
+	}
+}
@@ -37,18 +37,53 @@ func (e *CombineExpr) End() int {
 }
 
 // String returns the string representation of the combination expression.
+//
+// An operand that would be grouped differently when parsed back(e.g. an OR expression on either side of AND)
+// is wrapped in parentheses.
 func (e *CombineExpr) String() string {
 	var buf strings.Builder
 	if e.LeftExpr != nil {
-		buf.WriteString(e.LeftExpr.String())
+		writeOperand(&buf, e.LeftExpr, KeywordPrecedence(e.Keyword), false)
 	}
 
 	if e.RightExpr != nil {
 		buf.WriteByte(' ')
 		buf.WriteString(e.Keyword.String())
 		buf.WriteByte(' ')
-		buf.WriteString(e.RightExpr.String())
+		writeOperand(&buf, e.RightExpr, KeywordPrecedence(e.Keyword), true)
 	}
 
 	return buf.String()
 }
+
+// writeOperand writes an operand of the combination expression, with parentheses if it binds looser than parent.
+func writeOperand(buf *strings.Builder, operand Expr, parent int, right bool) {
+	combine, ok := operand.(*CombineExpr)
+	if !ok {
+		buf.WriteString(operand.String())
+
+		return
+	}
+
+	precedence := KeywordPrecedence(combine.Keyword)
+	if precedence > parent || (precedence == parent && !right) {
+		buf.WriteString(combine.String())
+
+		return
+	}
+
+	buf.WriteByte('(')
+	buf.WriteString(combine.String())
+	buf.WriteByte(')')
+}
+
+// KeywordPrecedence returns the binding power of the keyword of a combination expression in the Kibana precedence,
+// the higher binds tighter: AND and NOT bind tighter than OR. A combination expression that binds looser than
+// its parent, or as tight as its parent on the right, is written in parentheses.
+func KeywordPrecedence(keyword token.Kind) int {
+	if keyword == token.TokenKindKeywordOr {
+		return 1
+	}
+
+	return 2
+}
@@ -26,9 +26,9 @@ func TestCombineExpr(t *testing.T) {
 		{
 			name: `f1: "v1" OR NOT f1: "v2"`,
 			args: args{
-				leftExpr:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+				leftExpr:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
 				keyword:   token.TokenKindKeywordOr,
-				rightExpr: ast.NewBinaryExpr(12, "f1", token.TokenKindOperatorEql, ast.NewLiteral(20, 24, token.TokenKindString, "v2", nil), true),
+				rightExpr: ast.NewBinaryExpr(12, ast.NewLiteral(16, 18, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(20, 24, token.TokenKindString, "v2", nil), true),
 			},
 			wantEnd:    24,
 			wantString: `f1: "v1" OR NOT f1: "v2"`,
@@ -37,26 +37,53 @@ func TestCombineExpr(t *testing.T) {
 			name: `NOT f1: ("v1" OR "v2") AND f3: "v3"`,
 			args: args{
 				leftExpr: ast.NewBinaryExpr(
-					0,
-					"f1",
+					0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil),
 					token.TokenKindOperatorEql,
 					ast.NewParenExpr(
 						8,
 						22,
 						ast.NewCombineExpr(
-							ast.NewBinaryExpr(9, "", 0, ast.NewLiteral(9, 13, token.TokenKindString, "v1", nil), false),
+							ast.NewBinaryExpr(9, nil, 0, ast.NewLiteral(9, 13, token.TokenKindString, "v1", nil), false),
 							token.TokenKindKeywordOr,
-							ast.NewBinaryExpr(17, "", 0, ast.NewLiteral(17, 21, token.TokenKindString, "v2", nil), false),
+							ast.NewBinaryExpr(17, nil, 0, ast.NewLiteral(17, 21, token.TokenKindString, "v2", nil), false),
 						),
 					),
 					true,
 				),
 				keyword:   token.TokenKindKeywordAnd,
-				rightExpr: ast.NewBinaryExpr(27, "f3", token.TokenKindOperatorEql, ast.NewLiteral(31, 35, token.TokenKindString, "v3", nil), false),
+				rightExpr: ast.NewBinaryExpr(27, ast.NewLiteral(27, 29, token.TokenKindIdent, "f3", nil), token.TokenKindOperatorEql, ast.NewLiteral(31, 35, token.TokenKindString, "v3", nil), false),
 			},
 			wantEnd:    35,
 			wantString: `NOT f1: ("v1" OR "v2") AND f3: "v3"`,
 		},
+		{
+			name: `(f1: "v1" OR f2: "v2") AND f3: "v3"`,
+			args: args{
+				leftExpr: ast.NewCombineExpr(
+					ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+					token.TokenKindKeywordOr,
+					ast.NewBinaryExpr(12, ast.NewLiteral(12, 14, token.TokenKindIdent, "f2", nil), token.TokenKindOperatorEql, ast.NewLiteral(16, 20, token.TokenKindString, "v2", nil), false),
+				),
+				keyword:   token.TokenKindKeywordAnd,
+				rightExpr: ast.NewBinaryExpr(25, ast.NewLiteral(25, 27, token.TokenKindIdent, "f3", nil), token.TokenKindOperatorEql, ast.NewLiteral(29, 33, token.TokenKindString, "v3", nil), false),
+			},
+			wantEnd:    33,
+			wantString: `(f1: "v1" OR f2: "v2") AND f3: "v3"`,
+		},
+		{
+			name: `f1: "v1" OR (f2: "v2" OR f3: "v3")`,
+			args: args{
+				leftExpr: ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+				keyword:  token.TokenKindKeywordOr,
+				rightExpr: ast.NewCombineExpr(
+					ast.NewBinaryExpr(12, ast.NewLiteral(12, 14, token.TokenKindIdent, "f2", nil), token.TokenKindOperatorEql, ast.NewLiteral(16, 20, token.TokenKindString, "v2", nil), false),
+					token.TokenKindKeywordOr,
+					ast.NewBinaryExpr(24, ast.NewLiteral(24, 26, token.TokenKindIdent, "f3", nil), token.TokenKindOperatorEql, ast.NewLiteral(28, 32, token.TokenKindString, "v3", nil), false),
+				),
+			},
+			wantEnd:    32,
+			wantString: `f1: "v1" OR (f2: "v2" OR f3: "v3")`,
+		},
 	}
 
 	for _, c := range cases {
@@ -68,3 +95,10 @@ func TestCombineExpr(t *testing.T) {
 		})
 	}
 }
+
+func TestKeywordPrecedence(t *testing.T) {
+	or := ast.KeywordPrecedence(token.TokenKindKeywordOr)
+	assert.Greater(t, ast.KeywordPrecedence(token.TokenKindKeywordAnd), or)
+	assert.Greater(t, ast.KeywordPrecedence(token.TokenKindKeywordNot), or)
+	assert.Equal(t, ast.KeywordPrecedence(token.TokenKindKeywordAnd), ast.KeywordPrecedence(token.TokenKindKeywordNot))
+}
@@ -0,0 +1,316 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// DateNow is the anchor of the date math values relative to the current time, e.g. `now-7d`.
+const DateNow = "now"
+
+// DateUnit is the unit of an offset or the rounding of a date math value.
+type DateUnit int
+
+const (
+	DateUnitYear   DateUnit = iota + 1 // y
+	DateUnitMonth                      // M
+	DateUnitWeek                       // w, a week starts on Monday
+	DateUnitDay                        // d
+	DateUnitHour                       // h or H
+	DateUnitMinute                     // m
+	DateUnitSecond                     // s
+)
+
+var dateUnits = [...]string{
+	DateUnitYear:   "y",
+	DateUnitMonth:  "M",
+	DateUnitWeek:   "w",
+	DateUnitDay:    "d",
+	DateUnitHour:   "h",
+	DateUnitMinute: "m",
+	DateUnitSecond: "s",
+}
+
+// ParseDateUnit returns the unit of s, which is one of y, M, w, d, h, H, m and s.
+func ParseDateUnit(s string) (DateUnit, error) {
+	if s == "H" {
+		return DateUnitHour, nil
+	}
+
+	for u, name := range dateUnits {
+		if name != "" && name == s {
+			return DateUnit(u), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown date unit %q", s)
+}
+
+// String returns the name of the unit, e.g. "d".
+func (u DateUnit) String() string {
+	if u > 0 && int(u) < len(dateUnits) {
+		return dateUnits[u]
+	}
+
+	return fmt.Sprintf("DateUnit(%d)", u)
+}
+
+// DateOffset is an offset of a date math value, e.g. `-7d`.
+type DateOffset struct {
+	Value int // the signed number of units, e.g. -7 of `-7d`
+	Unit  DateUnit
+}
+
+// String returns the offset as it is written in date math, e.g. "-7d".
+func (o DateOffset) String() string {
+	if o.Value < 0 {
+		return strconv.Itoa(o.Value) + o.Unit.String()
+	}
+
+	return "+" + strconv.Itoa(o.Value) + o.Unit.String()
+}
+
+// DateExpr is a date or an Elasticsearch date math value of a range clause, it is an anchor
+// followed by the offsets and an optional rounding.
+//
+// Example:
+//
+//	`now-7d/d`
+//	`"2024-01-01"`
+//	`"2024-01-01T08:00:00Z||+1M/d"`
+type DateExpr struct {
+	*Literal // identifier or string
+
+	Anchor   string       // DateNow or a date(see Literal.Time), e.g. "2024-01-01" of `"2024-01-01||+1M/d"`
+	Offsets  []DateOffset // e.g. +1M of `"2024-01-01||+1M/d"`
+	Rounding DateUnit     // e.g. d of `now/d`, 0 for no rounding
+}
+
+// NewDateExpr creates a new date expression.
+func NewDateExpr(lit *Literal, anchor string, offsets []DateOffset, rounding DateUnit) *DateExpr {
+	return &DateExpr{
+		Literal:  lit,
+		Anchor:   anchor,
+		Offsets:  offsets,
+		Rounding: rounding,
+	}
+}
+
+// ParseDateExpr parses the value of lit as a date or a date math value:
+//
+//   - `now`, optionally followed by the offsets and the rounding, e.g. `now-7d/d`
+//   - a date(see Literal.Time, except the milliseconds since the Unix epoch), e.g. `"2024-01-01"`
+//   - a date followed by `||`, the offsets and the rounding, e.g. `"2024-01-01||+1M/d"`
+//
+// An offset is a sign, an optional number(1 by default) and a unit, e.g. `+1h` and `-d`,
+// the rounding is a slash and a unit, e.g. `/d`. The units are y, M, w, d, h(or H), m and s.
+func ParseDateExpr(lit *Literal) (*DateExpr, error) {
+	anchor, math := lit.Value, ""
+
+	switch i := strings.Index(lit.Value, "||"); {
+	case strings.HasPrefix(lit.Value, DateNow):
+		anchor, math = DateNow, lit.Value[len(DateNow):]
+	case i >= 0:
+		anchor, math = lit.Value[:i], lit.Value[i+2:]
+	}
+
+	if anchor != DateNow {
+		if _, err := parseTime(anchor, timeLayouts, time.UTC); err != nil {
+			return nil, fmt.Errorf("invalid date %s: %w", lit, err)
+		}
+	}
+
+	offsets, rounding, err := parseDateMath(math)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date math %s: %w", lit, err)
+	}
+
+	return NewDateExpr(lit, anchor, offsets, rounding), nil
+}
+
+// IsDateMath reports whether s is meant to be a date math value, i.e. it starts with `now` or contains `||`.
+func IsDateMath(s string) bool {
+	return strings.HasPrefix(s, DateNow) || strings.Contains(s, "||")
+}
+
+// parseDateMath parses the offsets and the rounding after the anchor of a date math value, e.g. `-7d/d`.
+func parseDateMath(s string) (offsets []DateOffset, rounding DateUnit, err error) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '+' && c != '-' && c != '/' {
+			return nil, 0, fmt.Errorf("expected +, - or /, but got %q", s[i:])
+		}
+
+		if rounding > 0 {
+			return nil, 0, errors.New("expected the rounding at the end")
+		}
+
+		i++
+
+		n := i
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' && c != '/' {
+			n++
+		}
+
+		value := 1
+		if n > i {
+			if value, err = strconv.Atoi(s[i:n]); err != nil {
+				return nil, 0, fmt.Errorf("expected number, but got %q", s[i:n])
+			}
+		}
+
+		if n == len(s) {
+			return nil, 0, errors.New("expected unit at the end")
+		}
+
+		unit, err := ParseDateUnit(s[n : n+1])
+		if err != nil {
+			return nil, 0, err
+		}
+
+		i = n + 1
+
+		if c == '/' {
+			rounding = unit
+
+			continue
+		}
+
+		if c == '-' {
+			value = -value
+		}
+
+		offsets = append(offsets, DateOffset{Value: value, Unit: unit})
+	}
+
+	return offsets, rounding, nil
+}
+
+// Pos returns the position of the date expression.
+func (e *DateExpr) Pos() int {
+	return e.pos
+}
+
+// End returns the end position of the date expression.
+func (e *DateExpr) End() int {
+	return e.end
+}
+
+// String returns the string representation of the date expression.
+func (e *DateExpr) String() string {
+	return e.Literal.String()
+}
+
+// DateResolver resolves the date expressions into times.
+type DateResolver struct {
+	Clock    func() time.Time // the reference clock of `now`, time.Now if nil
+	Location *time.Location   // the time zone of the dates without time zone and of the rounding, UTC if nil
+}
+
+// Resolve returns the time of e as the value of the range operator op. Like Elasticsearch, the rounding rounds down
+// for >= and <, and rounds up to the last nanosecond of the unit for > and <=,
+// e.g. `<= now/d` is up to the end of today and `> now/d` is after the end of today.
+func (r DateResolver) Resolve(e *DateExpr, op token.Kind) (time.Time, error) {
+	loc := r.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var t time.Time
+
+	if e.Anchor == DateNow {
+		clock := r.Clock
+		if clock == nil {
+			clock = time.Now
+		}
+
+		t = clock()
+	} else {
+		anchor, err := parseTime(e.Anchor, timeLayouts, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %s: %w", e, err)
+		}
+
+		t = anchor
+	}
+
+	t = t.In(loc)
+	for _, offset := range e.Offsets {
+		t = addDate(t, offset.Value, offset.Unit)
+	}
+
+	if e.Rounding == 0 {
+		return t, nil
+	}
+
+	t = truncateDate(t, e.Rounding)
+	if op == token.TokenKindOperatorGtr || op == token.TokenKindOperatorLeq {
+		t = addDate(t, 1, e.Rounding).Add(-time.Nanosecond)
+	}
+
+	return t, nil
+}
+
+// addDate adds n units to t, the years, months, weeks and days keep the clock time in the time zone of t.
+// Like Elasticsearch, the day of month is clamped to the last day of the month, e.g. 2024-01-31||+1M is 2024-02-29.
+func addDate(t time.Time, n int, unit DateUnit) time.Time {
+	switch unit {
+	case DateUnitYear:
+		return addMonths(t, 12*n)
+	case DateUnitMonth:
+		return addMonths(t, n)
+	case DateUnitWeek:
+		return t.AddDate(0, 0, 7*n)
+	case DateUnitDay:
+		return t.AddDate(0, 0, n)
+	case DateUnitHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case DateUnitMinute:
+		return t.Add(time.Duration(n) * time.Minute)
+	case DateUnitSecond:
+		return t.Add(time.Duration(n) * time.Second)
+	}
+
+	return t
+}
+
+// addMonths adds n months to t, the day of month is clamped to the last day of the month.
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+
+	first := time.Date(year, month+time.Month(n), 1, hour, minute, second, t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
+
+// truncateDate rounds t down to the start of its unit in the time zone of t.
+func truncateDate(t time.Time, unit DateUnit) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+
+	switch unit {
+	case DateUnitYear:
+		month, day, hour, minute, second = time.January, 1, 0, 0, 0
+	case DateUnitMonth:
+		day, hour, minute, second = 1, 0, 0, 0
+	case DateUnitWeek:
+		day, hour, minute, second = day-(int(t.Weekday())+6)%7, 0, 0, 0
+	case DateUnitDay:
+		hour, minute, second = 0, 0, 0
+	case DateUnitHour:
+		minute, second = 0, 0
+	case DateUnitMinute:
+		second = 0
+	}
+
+	return time.Date(year, month, day, hour, minute, second, 0, t.Location())
+}
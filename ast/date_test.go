@@ -0,0 +1,158 @@
+package ast_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestParseDateExpr(t *testing.T) {
+	cases := []struct {
+		value    string
+		anchor   string
+		offsets  []ast.DateOffset
+		rounding ast.DateUnit
+	}{
+		{value: "now", anchor: "now"},
+		{value: "now-7d/d", anchor: "now", offsets: []ast.DateOffset{{-7, ast.DateUnitDay}}, rounding: ast.DateUnitDay},
+		{value: "now+1h-30m", anchor: "now", offsets: []ast.DateOffset{{1, ast.DateUnitHour}, {-30, ast.DateUnitMinute}}},
+		{value: "now-M/w", anchor: "now", offsets: []ast.DateOffset{{-1, ast.DateUnitMonth}}, rounding: ast.DateUnitWeek},
+		{value: "now/H", anchor: "now", rounding: ast.DateUnitHour},
+		{value: "2024-01-01", anchor: "2024-01-01"},
+		{value: "2024-01-01T08:00:00", anchor: "2024-01-01T08:00:00"},
+		{
+			value: "2024-01-01T08:00:00+08:00||+1y-2s/M", anchor: "2024-01-01T08:00:00+08:00",
+			offsets:  []ast.DateOffset{{1, ast.DateUnitYear}, {-2, ast.DateUnitSecond}},
+			rounding: ast.DateUnitMonth,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			lit := ast.NewLiteral(0, len(c.value), token.TokenKindIdent, c.value, nil)
+
+			got, err := ast.ParseDateExpr(lit)
+			require.NoError(t, err)
+			assert.Equal(t, ast.NewDateExpr(lit, c.anchor, c.offsets, c.rounding), got)
+			assert.Equal(t, c.value, got.String())
+		})
+	}
+}
+
+func TestParseDateExpr_Error(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{value: "yesterday", want: `invalid date "yesterday": expected layouts`},
+		{value: "2024-13-01||+1d", want: `invalid date "2024-13-01||+1d": expected layouts`},
+		{value: "now-1x", want: `invalid date math "now-1x": unknown date unit "x"`},
+		{value: "now-", want: `invalid date math "now-": expected unit at the end`},
+		{value: "now/d+1h", want: `invalid date math "now/d+1h": expected the rounding at the end`},
+		{value: "now*2", want: `invalid date math "now*2": expected +, - or /, but got "*2"`},
+		{value: "now/1d", want: `invalid date math "now/1d": unknown date unit "1"`},
+		{value: "now+99999999999999999999d", want: `expected number, but got "99999999999999999999"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			_, err := ast.ParseDateExpr(ast.NewLiteral(0, 0, token.TokenKindString, c.value, nil))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.want)
+		})
+	}
+
+	assert.True(t, ast.IsDateMath("now-1x"))
+	assert.True(t, ast.IsDateMath("x||+1d"))
+	assert.False(t, ast.IsDateMath("2024-01-01"))
+}
+
+func TestDateResolver(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	now := time.Date(2024, 3, 14, 15, 9, 26, 535, time.UTC) // Thursday
+
+	cases := []struct {
+		value    string
+		op       token.Kind
+		location *time.Location
+		want     time.Time
+	}{
+		{value: "now", op: token.TokenKindOperatorGeq, want: now},
+		{value: "now-15m", op: token.TokenKindOperatorGeq, want: now.Add(-15 * time.Minute)},
+		{value: "now-7d/d", op: token.TokenKindOperatorGeq, want: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{value: "now-7d/d", op: token.TokenKindOperatorLss, want: time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{value: "now/d", op: token.TokenKindOperatorLeq, want: time.Date(2024, 3, 14, 23, 59, 59, 999999999, time.UTC)},
+		{value: "now/d", op: token.TokenKindOperatorGtr, want: time.Date(2024, 3, 14, 23, 59, 59, 999999999, time.UTC)},
+		{value: "now/w", op: token.TokenKindOperatorGeq, want: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{value: "now/M", op: token.TokenKindOperatorLeq, want: time.Date(2024, 3, 31, 23, 59, 59, 999999999, time.UTC)},
+		{value: "now+1y/y", op: token.TokenKindOperatorGeq, want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "now/h", op: token.TokenKindOperatorGeq, want: time.Date(2024, 3, 14, 15, 0, 0, 0, time.UTC)},
+		{value: "now/m", op: token.TokenKindOperatorGeq, want: time.Date(2024, 3, 14, 15, 9, 0, 0, time.UTC)},
+		{value: "now/s", op: token.TokenKindOperatorGeq, want: time.Date(2024, 3, 14, 15, 9, 26, 0, time.UTC)},
+		{
+			value: "now/d", op: token.TokenKindOperatorGeq, location: shanghai,
+			want: time.Date(2024, 3, 14, 0, 0, 0, 0, shanghai),
+		},
+		{value: "2024-01-31", op: token.TokenKindOperatorGeq, want: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{
+			value: "2024-01-31||+1M/d", op: token.TokenKindOperatorLeq,
+			want: time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC),
+		},
+		{value: "2024-02-29||+1y", op: token.TokenKindOperatorGeq, want: time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-03-31||-1M", op: token.TokenKindOperatorGeq, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{
+			value: "2024-01-01T08:00:00", op: token.TokenKindOperatorGeq, location: shanghai,
+			want: time.Date(2024, 1, 1, 8, 0, 0, 0, shanghai),
+		},
+		{
+			value: "2024-01-01T00:00:00Z||/d", op: token.TokenKindOperatorGeq, location: shanghai,
+			want: time.Date(2024, 1, 1, 0, 0, 0, 0, shanghai),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value+" "+c.op.String(), func(t *testing.T) {
+			date, err := ast.ParseDateExpr(ast.NewLiteral(0, 0, token.TokenKindString, c.value, nil))
+			require.NoError(t, err)
+
+			r := ast.DateResolver{Clock: func() time.Time { return now }, Location: c.location}
+
+			got, err := r.Resolve(date, c.op)
+			require.NoError(t, err)
+			assert.True(t, c.want.Equal(got), "want %s, but got %s", c.want, got)
+		})
+	}
+
+	before := time.Now()
+	got, err := ast.DateResolver{}.Resolve(ast.NewDateExpr(nil, ast.DateNow, nil, 0), token.TokenKindOperatorGeq)
+	require.NoError(t, err)
+	assert.False(t, got.Before(before))
+	assert.Equal(t, time.UTC, got.Location())
+
+	_, err = ast.DateResolver{}.Resolve(ast.NewDateExpr(ast.NewLiteral(0, 0, token.TokenKindString, "x", nil), "x", nil, 0),
+		token.TokenKindOperatorGeq)
+	assert.Error(t, err)
+}
+
+func TestDateUnit(t *testing.T) {
+	for _, s := range []string{"y", "M", "w", "d", "h", "m", "s"} {
+		unit, err := ast.ParseDateUnit(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, unit.String())
+	}
+
+	unit, err := ast.ParseDateUnit("H")
+	require.NoError(t, err)
+	assert.Equal(t, ast.DateUnitHour, unit)
+
+	_, err = ast.ParseDateUnit("D")
+	assert.EqualError(t, err, `unknown date unit "D"`)
+	assert.Equal(t, "DateUnit(0)", ast.DateUnit(0).String())
+	assert.Equal(t, "-7d", ast.DateOffset{Value: -7, Unit: ast.DateUnitDay}.String())
+	assert.Equal(t, "+1M", ast.DateOffset{Value: 1, Unit: ast.DateUnitMonth}.String())
+}
@@ -0,0 +1,160 @@
+package ast
+
+// ChangeKind is the kind of a change reported by Diff.
+type ChangeKind int
+
+const (
+	ChangeAdded    ChangeKind = iota + 1 // a clause only in the new expression
+	ChangeRemoved                        // a clause only in the old expression
+	ChangeModified                       // a clause of the same field with another value, operator or NOT
+)
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+
+	return "unknown"
+}
+
+// Change is a clause that is added, removed or modified, From is nil for an added clause
+// and To is nil for a removed one.
+type Change struct {
+	Kind     ChangeKind
+	From, To Expr
+}
+
+// String returns the change as a line of a diff, e.g. `+ level: warn`, `- level: error`
+// and `~ level: error -> level: warn`.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return "+ " + c.To.String()
+	case ChangeRemoved:
+		return "- " + c.From.String()
+	}
+
+	return "~ " + c.From.String() + " -> " + c.To.String()
+}
+
+// Diff reports the clauses that are added, removed or modified from a to b, e.g. to show how a saved query changed.
+//
+// The clauses are the field queries(*BinaryExpr, *ExistsExpr, and *ValueListExpr and *NestedExpr as a whole),
+// the values without field, the negated groups(e.g. `NOT (a OR b)`) and *BadExpr, the combination expressions
+// and the parentheses around them are looked through. A clause of a that equals(regardless of the positions)
+// a clause of b is unchanged, the rest are paired by the field in order of appearance as the modified clauses.
+//
+// The changes are ordered as the removed and modified clauses in a, followed by the added clauses in b.
+// Diff does not report the changes of the keywords and the grouping, use Equal to tell whether the trees differ.
+func Diff(a, b Expr) []Change {
+	var (
+		from = clauses(a)
+		to   = clauses(b)
+		used = make([]bool, len(to))
+		opts = EqualOptions{IgnorePositions: true}
+	)
+
+	// unchanged clauses first, so a modified clause is not paired with a clause that is kept
+	removed := make([]Expr, 0, len(from))
+
+	for _, clause := range from {
+		if i := findClause(clause, to, used, func(x, y Expr) bool { return Equal(x, y, opts) }); i >= 0 {
+			used[i] = true
+		} else {
+			removed = append(removed, clause)
+		}
+	}
+
+	var changes []Change
+
+	for _, clause := range removed {
+		if i := findClause(clause, to, used, sameField); i >= 0 {
+			used[i] = true
+			changes = append(changes, Change{Kind: ChangeModified, From: clause, To: to[i]})
+		} else {
+			changes = append(changes, Change{Kind: ChangeRemoved, From: clause})
+		}
+	}
+
+	for i, clause := range to {
+		if !used[i] {
+			changes = append(changes, Change{Kind: ChangeAdded, To: clause})
+		}
+	}
+
+	return changes
+}
+
+// clauses returns the clauses of expr in source order.
+func clauses(expr Expr) []Expr {
+	var list []Expr
+
+	Inspect(expr, func(e Expr) bool {
+		switch e := e.(type) {
+		case *CombineExpr, *ParenExpr:
+			return true
+		case *BinaryExpr:
+			if _, ok := e.Value.(*ParenExpr); ok && e.Field == nil && !e.HasNot { // a group of clauses
+				return true
+			}
+		case nil:
+			return false
+		}
+
+		list = append(list, e)
+
+		return false
+	})
+
+	return list
+}
+
+// findClause returns the index of the first clause of list that is not used and matches clause, -1 if none.
+func findClause(clause Expr, list []Expr, used []bool, match func(x, y Expr) bool) int {
+	for i, other := range list {
+		if !used[i] && match(clause, other) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// sameField reports whether two clauses query the same field, e.g. `f: *` and `f > 1`.
+// The values without field are not paired.
+func sameField(x, y Expr) bool {
+	if x, ok := x.(*NestedExpr); ok {
+		y, ok := y.(*NestedExpr)
+
+		return ok && x.PathName() == y.PathName()
+	}
+
+	name, ok := clauseField(x)
+	if !ok {
+		return false
+	}
+
+	other, ok := clauseField(y)
+
+	return ok && name == other
+}
+
+// clauseField returns the field name of a field query(*BinaryExpr, *ValueListExpr or *ExistsExpr).
+func clauseField(expr Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return e.FieldName(), e.Field != nil
+	case *ValueListExpr:
+		return e.FieldName(), true
+	case *ExistsExpr:
+		return e.FieldName(), true
+	}
+
+	return "", false
+}
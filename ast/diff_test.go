@@ -0,0 +1,100 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+func TestDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "no changes",
+			a:    `status: active AND (level: error OR level: warn)`,
+			b:    `status:active and (level:error or level:warn)`,
+		},
+		{
+			name: "moved clauses",
+			a:    `a: 1 AND b: 2`,
+			b:    `b: 2 AND a: 1`,
+		},
+		{
+			name: "added clause",
+			a:    `status: active`,
+			b:    `status: active AND NOT user: bot`,
+			want: []string{"+ NOT user: bot"},
+		},
+		{
+			name: "removed clause",
+			a:    `status: active AND (level: error OR level: warn)`,
+			b:    `status: active AND level: error`,
+			want: []string{"- level: warn"},
+		},
+		{
+			name: "modified clauses",
+			a:    `status: active AND level: error AND latency > 1 AND f: * AND items: { a: 1 }`,
+			b:    `status: active AND level: warn AND latency >= 2 AND NOT f: x AND items: { a: 2 }`,
+			want: []string{
+				"~ level: error -> level: warn",
+				"~ latency > 1 -> latency >= 2",
+				"~ f: * -> NOT f: x",
+				"~ items: { a: 1 } -> items: { a: 2 }",
+			},
+		},
+		{
+			name: "kept clause of the same field",
+			a:    `level: error`,
+			b:    `level: warn OR level: error`,
+			want: []string{"+ level: warn"},
+		},
+		{
+			name: "values without field",
+			a:    `error AND NOT (a OR b)`,
+			b:    `warn AND NOT (a OR c)`,
+			want: []string{"- error", "- NOT (a OR b)", "+ warn", "+ NOT (a OR c)"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, err := parser.New(c.a).Stmt()
+			require.NoError(t, err)
+
+			b, err := parser.New(c.b).Stmt()
+			require.NoError(t, err)
+
+			var got []string
+			for _, change := range ast.Diff(a, b) {
+				got = append(got, change.String())
+			}
+
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestDiff_Kind(t *testing.T) {
+	a, err := parser.New(`a: 1 AND b: 2`).Stmt()
+	require.NoError(t, err)
+
+	b, err := parser.New(`a: 2 AND c: 3`).Stmt()
+	require.NoError(t, err)
+
+	changes := ast.Diff(a, b)
+	require.Len(t, changes, 3)
+	assert.Equal(t, ast.ChangeModified, changes[0].Kind)
+	assert.Equal(t, ast.ChangeRemoved, changes[1].Kind)
+	assert.Nil(t, changes[1].To)
+	assert.Equal(t, ast.ChangeAdded, changes[2].Kind)
+	assert.Nil(t, changes[2].From)
+	assert.Equal(t, "added removed modified unknown",
+		ast.ChangeAdded.String()+" "+ast.ChangeRemoved.String()+" "+ast.ChangeModified.String()+" "+ast.ChangeKind(0).String())
+}
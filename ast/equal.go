@@ -0,0 +1,168 @@
+package ast
+
+import "net"
+
+// EqualOptions configures Equal.
+type EqualOptions struct {
+	// IgnorePositions compares the expressions regardless of the positions of their nodes,
+	// e.g. `a: 1` and ` a : 1` are equal.
+	IgnorePositions bool
+}
+
+// Equal reports whether a and b are the same tree: the nodes have the same types, attributes(e.g. the keyword,
+// the quote and the escapes of a value) and children, and unless opts.IgnorePositions the same positions.
+//
+// Unlike reflect.DeepEqual it takes the unexported positions into account only if asked,
+// and unlike comparing the String methods it tells apart the trees that are printed the same,
+// e.g. a nil field and an empty one.
+func Equal(a, b Expr, opts EqualOptions) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch x := a.(type) {
+	case *BinaryExpr:
+		y, ok := b.(*BinaryExpr)
+
+		return ok && opts.pos(x.pos, y.pos) && x.Operator == y.Operator && x.HasNot == y.HasNot &&
+			Equal(x.Field, y.Field, opts) && Equal(x.Value, y.Value, opts)
+	case *CombineExpr:
+		y, ok := b.(*CombineExpr)
+
+		return ok && x.Keyword == y.Keyword && Equal(x.LeftExpr, y.LeftExpr, opts) && Equal(x.RightExpr, y.RightExpr, opts)
+	case *ParenExpr:
+		y, ok := b.(*ParenExpr)
+
+		return ok && opts.pos(x.L, y.L) && opts.pos(x.R, y.R) && Equal(x.Expr, y.Expr, opts)
+	case *NestedExpr:
+		y, ok := b.(*NestedExpr)
+
+		return ok && opts.pos(x.pos, y.pos) && opts.pos(x.L, y.L) && opts.pos(x.R, y.R) && x.HasNot == y.HasNot &&
+			Equal(x.Path, y.Path, opts) && Equal(x.Expr, y.Expr, opts)
+	case *ValueListExpr:
+		y, ok := b.(*ValueListExpr)
+
+		return ok && opts.pos(x.pos, y.pos) && opts.pos(x.L, y.L) && opts.pos(x.R, y.R) && x.Operator == y.Operator &&
+			x.HasNot == y.HasNot && Equal(x.Field, y.Field, opts) && Equal(x.Values, y.Values, opts)
+	case *ExistsExpr:
+		y, ok := b.(*ExistsExpr)
+
+		return ok && opts.pos(x.pos, y.pos) && opts.pos(x.end, y.end) && x.HasNot == y.HasNot &&
+			Equal(x.Field, y.Field, opts)
+	case *Literal:
+		y, ok := b.(*Literal)
+
+		return ok && x.equal(y, opts)
+	case *WildcardExpr:
+		y, ok := b.(*WildcardExpr)
+
+		return ok && x.Literal.equal(y.Literal, opts) && equalInts(x.Indexes, y.Indexes)
+	case *DateExpr:
+		y, ok := b.(*DateExpr)
+
+		return ok && x.Literal.equal(y.Literal, opts) && x.Anchor == y.Anchor && x.Rounding == y.Rounding &&
+			equalOffsets(x.Offsets, y.Offsets)
+	case *BadExpr:
+		y, ok := b.(*BadExpr)
+
+		return ok && opts.pos(x.pos, y.pos) && opts.pos(x.end, y.end) && x.Value == y.Value
+	}
+
+	return false
+}
+
+// Clone returns a deep copy of expr, which shares nothing with expr, including the slices
+// of the escape indexes, the wildcard indexes and the date offsets.
+func Clone(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return NewBinaryExpr(e.pos, Clone(e.Field), e.Operator, Clone(e.Value), e.HasNot)
+	case *CombineExpr:
+		return NewCombineExpr(Clone(e.LeftExpr), e.Keyword, Clone(e.RightExpr))
+	case *ParenExpr:
+		return NewParenExpr(e.L, e.R, Clone(e.Expr))
+	case *NestedExpr:
+		return NewNestedExpr(e.pos, Clone(e.Path), e.L, e.R, Clone(e.Expr), e.HasNot)
+	case *ValueListExpr:
+		return NewValueListExpr(e.pos, Clone(e.Field), e.Operator, e.L, e.R, Clone(e.Values), e.HasNot)
+	case *ExistsExpr:
+		return NewExistsExpr(e.pos, e.end, Clone(e.Field), e.HasNot)
+	case *Literal:
+		return e.clone()
+	case *WildcardExpr:
+		return NewWildcardExpr(e.Literal.clone(), copyInts(e.Indexes))
+	case *DateExpr:
+		return NewDateExpr(e.Literal.clone(), e.Anchor, append([]DateOffset(nil), e.Offsets...), e.Rounding)
+	case *BadExpr:
+		return NewBadExpr(e.pos, e.end, e.Value)
+	}
+
+	return expr
+}
+
+func (o EqualOptions) pos(x, y int) bool {
+	return o.IgnorePositions || x == y
+}
+
+func (e *Literal) equal(other *Literal, opts EqualOptions) bool {
+	if e == nil || other == nil {
+		return e == other
+	}
+
+	return opts.pos(e.pos, other.pos) && opts.pos(e.end, other.end) && e.Kind == other.Kind &&
+		e.Value == other.Value && e.WithDoubleQuote == other.WithDoubleQuote &&
+		equalInts(e.escapeIndexes, other.escapeIndexes)
+}
+
+func (e *Literal) clone() *Literal {
+	if e == nil {
+		return nil
+	}
+
+	lit := *e
+	lit.escapeIndexes = copyInts(e.escapeIndexes)
+
+	if ip, ok := e.Coerced.(net.IP); ok {
+		lit.Coerced = append(net.IP(nil), ip...)
+	}
+
+	return &lit
+}
+
+// equalInts reports whether x and y have the same elements, a nil slice equals an empty one.
+func equalInts(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func copyInts(s []int) []int {
+	if s == nil {
+		return nil
+	}
+
+	return append(make([]int, 0, len(s)), s...)
+}
+
+// equalOffsets reports whether x and y have the same offsets, a nil slice equals an empty one.
+func equalOffsets(x, y []DateOffset) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,113 @@
+package ast_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestEqual(t *testing.T) {
+	cases := []struct {
+		a, b            string
+		equal           bool
+		ignorePositions bool
+	}{
+		{a: `a: 1 AND b: "x"`, b: `a: 1 AND b: "x"`, equal: true},
+		{a: `a: 1 AND b: "x"`, b: ` a:1  AND b: "x"`, equal: false},
+		{a: `a: 1 AND b: "x"`, b: ` a:1  AND b: "x"`, equal: true, ignorePositions: true},
+		{a: `a: 1 AND b: "x"`, b: `a: 1 AND b: x`, equal: false, ignorePositions: true},
+		{a: `a: 1 AND b: "x"`, b: `a: 1 OR b: "x"`, equal: false, ignorePositions: true},
+		{a: `a: 1 AND b: "x"`, b: `a: 1 AND NOT b: "x"`, equal: false, ignorePositions: true},
+		{a: `a: x\*y`, b: `a: x*y`, equal: false, ignorePositions: true},
+		{a: `a: x*y*`, b: ` a: x*y*`, equal: true, ignorePositions: true},
+		{a: `(a: 1)`, b: `a: 1`, equal: false, ignorePositions: true},
+		{a: `a: (1 OR 2)`, b: `a:(1 OR 2)`, equal: true, ignorePositions: true},
+		{a: `items: { a: 1 }`, b: `items:{a:1}`, equal: true, ignorePositions: true},
+		{a: `items: { a: 1 }`, b: `items: { a: 2 }`, equal: false, ignorePositions: true},
+		{a: `NOT f: *`, b: `NOT f : *`, equal: true, ignorePositions: true},
+		{a: `f: *`, b: `f: "*"`, equal: false, ignorePositions: true},
+		{a: `a >= 1`, b: `a > 1`, equal: false, ignorePositions: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.a+" "+c.b, func(t *testing.T) {
+			a, err := parser.New(c.a).Stmt()
+			require.NoError(t, err)
+
+			b, err := parser.New(c.b).Stmt()
+			require.NoError(t, err)
+
+			opts := ast.EqualOptions{IgnorePositions: c.ignorePositions}
+			assert.Equal(t, c.equal, ast.Equal(a, b, opts))
+			assert.Equal(t, c.equal, ast.Equal(b, a, opts))
+		})
+	}
+
+	assert.True(t, ast.Equal(nil, nil, ast.EqualOptions{}))
+	assert.False(t, ast.Equal(nil, ast.NewBadExpr(0, 1, "x"), ast.EqualOptions{}))
+	assert.True(t, ast.Equal(ast.NewBadExpr(0, 1, "x"), ast.NewBadExpr(2, 3, "x"), ast.EqualOptions{IgnorePositions: true}))
+}
+
+func TestClone(t *testing.T) {
+	inputs := []string{
+		`a\:b: "x \"y\"" AND NOT c: x*\*y*`,
+		`(a OR b) AND items: { NOT a: 1 } AND f: *`,
+		`a NOT b OR c >= -1.5`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			expr, err := parser.New(input).Stmt()
+			require.NoError(t, err)
+
+			clone := ast.Clone(expr)
+			require.True(t, ast.Equal(expr, clone, ast.EqualOptions{}))
+
+			// the clone shares nothing with the original
+			original := make(map[ast.Expr]bool)
+			ast.Inspect(expr, func(e ast.Expr) bool {
+				original[e] = e != nil
+
+				return true
+			})
+
+			ast.Inspect(clone, func(e ast.Expr) bool {
+				assert.False(t, original[e], "%T %s", e, e)
+
+				if w, ok := e.(*ast.WildcardExpr); ok {
+					w.Indexes[0] = -1
+					w.Literal.Value = "changed"
+				}
+
+				return true
+			})
+
+			assert.Equal(t, input, expr.String())
+		})
+	}
+
+	assert.Nil(t, ast.Clone(nil))
+
+	w := ast.NewWildcardExpr(ast.NewLiteral(0, 3, token.TokenKindIdent, "a*b", nil), []int{1})
+	clone, ok := ast.Clone(w).(*ast.WildcardExpr)
+	require.True(t, ok)
+
+	clone.Indexes[0] = 2
+	assert.Equal(t, []int{1}, w.Indexes)
+
+	ip := ast.NewLiteral(0, 8, token.TokenKindFloat, "10.0.0.1", nil)
+	ip.Coerced = net.IPv4(10, 0, 0, 1)
+
+	ipClone, ok := ast.Clone(ip).(*ast.Literal)
+	require.True(t, ok)
+
+	ipClone.Coerced.(net.IP)[15] = 2
+	assert.Equal(t, net.IPv4(10, 0, 0, 1), ip.Coerced)
+	assert.True(t, ast.Equal(ast.NewLiteral(0, 8, token.TokenKindFloat, "10.0.0.1", nil), ip, ast.EqualOptions{}))
+}
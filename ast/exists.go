@@ -0,0 +1,55 @@
+package ast
+
+import "strings"
+
+// ExistsExpr is a field existence expression, which is a field followed by `:` and a bare(unquoted) wildcard.
+//
+// Example:
+//
+//	`f1: *`
+//	`NOT f1: *`
+type ExistsExpr struct {
+	pos    int
+	end    int
+	Field  Expr // *Literal or *WildcardExpr(e.g. `labels.*`)
+	HasNot bool
+}
+
+// NewExistsExpr creates a new field existence expression.
+func NewExistsExpr(pos, end int, field Expr, hasNot bool) *ExistsExpr {
+	return &ExistsExpr{
+		pos:    pos,
+		end:    end,
+		Field:  field,
+		HasNot: hasNot,
+	}
+}
+
+// Pos returns the position of the field existence expression.
+func (e *ExistsExpr) Pos() int {
+	return e.pos
+}
+
+// End returns the end position of the field existence expression.
+func (e *ExistsExpr) End() int {
+	return e.end
+}
+
+// FieldName returns the unescaped name of the field.
+func (e *ExistsExpr) FieldName() string {
+	return fieldName(e.Field)
+}
+
+// String returns the string representation of the field existence expression.
+func (e *ExistsExpr) String() string {
+	var buf strings.Builder
+
+	if e.HasNot {
+		buf.WriteString("NOT ")
+	}
+
+	buf.WriteString(e.Field.String())
+	buf.WriteString(": *")
+
+	return buf.String()
+}
@@ -0,0 +1,48 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestExistsExpr(t *testing.T) {
+	type args struct {
+		pos, end int
+		field    ast.Expr
+		hasNot   bool
+	}
+
+	cases := []struct {
+		name       string
+		args       args
+		wantPos    int
+		wantEnd    int
+		wantString string
+	}{
+		{
+			name:       "f1: *",
+			args:       args{end: 5, field: ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil)},
+			wantEnd:    5,
+			wantString: "f1: *",
+		},
+		{
+			name:       "NOT f1: *",
+			args:       args{pos: 0, end: 9, field: ast.NewLiteral(4, 6, token.TokenKindIdent, "f1", nil), hasNot: true},
+			wantEnd:    9,
+			wantString: "NOT f1: *",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr := ast.NewExistsExpr(c.args.pos, c.args.end, c.args.field, c.args.hasNot)
+			assert.Equal(t, c.wantPos, expr.Pos())
+			assert.Equal(t, c.wantEnd, expr.End())
+			assert.Equal(t, c.wantString, expr.String())
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// ExpandField rewrites a clause(*BinaryExpr, *ValueListExpr or *ExistsExpr) with a wildcard field
+// (e.g. `machine.os*: windows`) into the clauses of the fields that match the pattern,
+// fields is the list of the known field names.
+//
+// A single matching field replaces the wildcard field in place, several matching fields are combined with OR
+// in parenthesis(e.g. `(machine.os: windows OR machine.os.keyword: windows)`), which keeps the NOT of the clause.
+// The value is shared by the expanded clauses. Any other expression is returned unchanged.
+//
+// An error is returned if no field matches the pattern.
+func ExpandField(expr Expr, fields []string) (Expr, error) {
+	return expandField(expr, "", fields)
+}
+
+// ExpandFields rewrites every clause with a wildcard field in expr with ExpandField, the fields inside
+// a nested field query(e.g. `items: { n*: x }`) are matched with the path as prefix(e.g. `items.n*`).
+//
+// ExpandFields modifies the expressions in place like Apply.
+func ExpandFields(expr Expr, fields []string) (Expr, error) {
+	var (
+		prefixes = []string{""}
+		err      error
+	)
+
+	result := Apply(expr, func(c *Cursor) bool {
+		if err != nil {
+			return false
+		}
+
+		switch e := c.Expr().(type) {
+		case *NestedExpr:
+			prefixes = append(prefixes, prefixes[len(prefixes)-1]+e.PathName()+".")
+		case *BinaryExpr, *ValueListExpr, *ExistsExpr:
+			var expanded Expr
+			if expanded, err = expandField(e, prefixes[len(prefixes)-1], fields); err != nil || expanded != e {
+				c.Replace(expanded)
+
+				return false
+			}
+		}
+
+		return true
+	}, func(c *Cursor) bool {
+		if _, ok := c.Expr().(*NestedExpr); ok {
+			prefixes = prefixes[:len(prefixes)-1]
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// expandField expands expr, prefix is the path(with trailing dot) of the nested field query expr belongs to.
+func expandField(expr Expr, prefix string, fields []string) (Expr, error) {
+	var (
+		field  *WildcardExpr
+		clause func(field Expr, hasNot bool) Expr
+		hasNot bool
+	)
+
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		field, _ = e.Field.(*WildcardExpr)
+		clause = func(field Expr, hasNot bool) Expr {
+			return NewBinaryExpr(e.pos, field, e.Operator, e.Value, hasNot)
+		}
+		hasNot = e.HasNot
+	case *ValueListExpr:
+		field, _ = e.Field.(*WildcardExpr)
+		clause = func(field Expr, hasNot bool) Expr {
+			return NewValueListExpr(e.pos, field, e.Operator, e.L, e.R, e.Values, hasNot)
+		}
+		hasNot = e.HasNot
+	case *ExistsExpr:
+		field, _ = e.Field.(*WildcardExpr)
+		clause = func(field Expr, hasNot bool) Expr {
+			return NewExistsExpr(e.pos, e.end, field, hasNot)
+		}
+		hasNot = e.HasNot
+	}
+
+	if field == nil {
+		return expr, nil
+	}
+
+	var matched []string
+
+	for _, name := range fields {
+		if strings.HasPrefix(name, prefix) && field.Match(strings.TrimPrefix(name, prefix)) {
+			matched = append(matched, strings.TrimPrefix(name, prefix))
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("no field matches %q", prefix+field.Value)
+	case 1:
+		return clause(newFieldLiteral(field.pos, matched[0]), hasNot), nil
+	}
+
+	clauses := make([]Expr, 0, len(matched))
+	for _, name := range matched {
+		clauses = append(clauses, clause(newFieldLiteral(field.pos, name), false))
+	}
+
+	combined := combineOperands(clauses, token.TokenKindKeywordOr)
+
+	return NewBinaryExpr(expr.Pos(), nil, 0, NewParenExpr(expr.Pos(), expr.End(), combined), hasNot), nil
+}
+
+// newFieldLiteral creates the field literal of name at pos, the characters that require escaping are escaped.
+func newFieldLiteral(pos int, name string) *Literal {
+	var indexes []int
+
+	if token.IsKeyword(name) {
+		indexes = append(indexes, 0)
+	}
+
+	for i, r := range []rune(name) {
+		if r == '*' || token.RequireEscape(string(r), token.TokenKindIdent) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	lit := NewLiteral(pos, pos, token.TokenKindIdent, name, indexes)
+	lit.end = pos + len([]rune(lit.String()))
+
+	return lit
+}
@@ -0,0 +1,82 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+var expandTestFields = []string{"machine.os", "machine.os.keyword", "machine.ram", "host", "AND", "a*b", "items.name", "items.note"}
+
+func TestExpandField(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: "machine.os*: windows", want: "(machine.os: windows OR machine.os.keyword: windows)"},
+		{input: "NOT machine.os*: windows", want: "NOT (machine.os: windows OR machine.os.keyword: windows)"},
+		{input: "machine.r*: *", want: "machine.ram: *"},
+		{input: "NOT machine.r* >= 1", want: "NOT machine.ram >= 1"},
+		{input: "machine.os*: (a OR b)", want: "(machine.os: (a OR b) OR machine.os.keyword: (a OR b))"},
+		{input: `A*: 1`, want: `\AND: 1`},
+		{input: `a\**: 1`, want: `a\*b: 1`},
+		{input: "host: 1", want: "host: 1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			expr, err := parser.New(c.input).Stmt()
+			require.NoError(t, err)
+
+			got, err := ast.ExpandField(expr, expandTestFields)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got.String())
+
+			_, err = parser.New(got.String()).Stmt()
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestExpandField_NoMatch(t *testing.T) {
+	expr, err := parser.New("user.*: x").Stmt()
+	require.NoError(t, err)
+
+	_, err = ast.ExpandField(expr, expandTestFields)
+	assert.EqualError(t, err, `no field matches "user.*"`)
+}
+
+func TestExpandFields(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{
+			input: "h*: x AND NOT machine.os*: windows",
+			want:  "host: x AND NOT (machine.os: windows OR machine.os.keyword: windows)",
+		},
+		{input: "items: { n*: x }", want: "items: { (name: x OR note: x) }"},
+		{input: "(h*: x OR machine.ram > 1)", want: "(host: x OR machine.ram > 1)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			expr, err := parser.New(c.input).Stmt()
+			require.NoError(t, err)
+
+			got, err := ast.ExpandFields(expr, expandTestFields)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got.String())
+		})
+	}
+
+	expr, err := parser.New("host: x AND items: { x*: 1 }").Stmt()
+	require.NoError(t, err)
+
+	_, err = ast.ExpandFields(expr, expandTestFields)
+	assert.EqualError(t, err, `no field matches "items.x*"`)
+}
@@ -0,0 +1,399 @@
+package ast
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// KeywordCase is the letter case of the keywords printed by Format.
+type KeywordCase int
+
+const (
+	// KeywordUpper prints the keywords in upper case, e.g. `AND`.
+	KeywordUpper KeywordCase = iota
+	// KeywordLower prints the keywords in lower case, e.g. `and`.
+	KeywordLower
+)
+
+// ColonSpacing is the spacing around the `:` operator printed by Format.
+type ColonSpacing int
+
+const (
+	// ColonSpaceAfter prints a space after `:`, e.g. `f: v`.
+	ColonSpaceAfter ColonSpacing = iota
+	// ColonSpaceNone prints no space around `:`, e.g. `f:v`.
+	ColonSpaceNone
+	// ColonSpaceAround prints a space before and after `:`, e.g. `f : v`.
+	ColonSpaceAround
+)
+
+// ParenStyle decides the parentheses printed by Format.
+type ParenStyle int
+
+const (
+	// ParenKeep prints the parentheses of the expression, plus the ones required by the precedence.
+	ParenKeep ParenStyle = iota
+	// ParenMinimal prints only the parentheses required by the precedence, the value lists(e.g. `f: (a OR b)`)
+	// and NOT, e.g. `((a: 1)) AND (b: 2 AND c: 3)` is printed as `a: 1 AND b: 2 AND c: 3`.
+	ParenMinimal
+	// ParenRedundant prints the parentheses of the expression, plus the ones around every group of clauses
+	// mixed with another keyword, e.g. `a OR b AND c` is printed as `a OR (b AND c)`.
+	ParenRedundant
+)
+
+// QuoteStyle decides the double quotes of the values printed by Format.
+type QuoteStyle int
+
+const (
+	// QuoteKeep prints the values as they are.
+	QuoteKeep QuoteStyle = iota
+	// QuoteAlways quotes every value except the numbers and the wildcards, e.g. `f: v` is printed as `f: "v"`.
+	QuoteAlways
+	// QuoteMinimal unquotes the string values that can be written as identifiers,
+	// e.g. `f: "v"` is printed as `f: v` but `f: "a b"` and `f: "1"` are kept.
+	QuoteMinimal
+)
+
+// FormatOptions configures Format, the zero value prints the same as the String methods.
+type FormatOptions struct {
+	KeywordCase  KeywordCase
+	ColonSpacing ColonSpacing
+	Parens       ParenStyle
+	Quote        QuoteStyle
+
+	// Width is the maximum width(in characters) of a line, a parenthesis, nested field query or combination
+	// expression that does not fit is printed in multiple indented lines. 0 means no limit.
+	Width int
+	// Indent is the indentation of the multiple lines, the default is two spaces.
+	Indent string
+}
+
+// Format prints expr in the style of opts, e.g. as the canonical form of the queries checked into version control.
+//
+// With Width, the expression is broken into lines from the outermost parenthesis, brace or combination expression
+// that is too long, a combination expression has one operand per line led by the keyword:
+//
+//	status: active
+//	AND (
+//	  level: error
+//	  OR level: warn
+//	)
+func Format(expr Expr, opts FormatOptions) string {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+
+	f := &formatter{opts: opts}
+	if opts.Parens == ParenMinimal {
+		expr = stripParen(expr)
+	}
+
+	return f.format(expr, 0, 0)
+}
+
+type formatter struct {
+	opts FormatOptions
+}
+
+// format prints expr that starts at the column col of a line with the indentation level,
+// the lines after the first one are indented.
+func (f *formatter) format(expr Expr, level, col int) string {
+	flat := f.print(expr, level, col, false)
+	if f.opts.Width <= 0 || col+utf8.RuneCountInString(flat) <= f.opts.Width {
+		return flat
+	}
+
+	return f.print(expr, level, col, true)
+}
+
+// print prints expr, in multiple lines if multiline is true, the children decide their own lines.
+func (f *formatter) print(expr Expr, level, col int, multiline bool) string {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return f.printBinary(e, level, col, multiline)
+	case *CombineExpr:
+		return f.printCombine(e, level, col, multiline)
+	case *ParenExpr:
+		return f.group("(", ")", e.Expr, level, col, multiline)
+	case *NestedExpr:
+		prefix := f.not(e.HasNot) + e.Path.String() + f.operator(token.TokenKindOperatorEql)
+
+		return prefix + f.group("{ ", " }", e.Expr, level, col+utf8.RuneCountInString(prefix), multiline)
+	case *ValueListExpr:
+		return f.printValueList(e, level, col, multiline)
+	case *ExistsExpr:
+		return f.not(e.HasNot) + e.Field.String() + f.operator(token.TokenKindOperatorEql) + "*"
+	}
+
+	return expr.String()
+}
+
+func (f *formatter) printBinary(e *BinaryExpr, level, col int, multiline bool) string {
+	prefix := f.not(e.HasNot)
+	if e.Field != nil {
+		prefix += e.Field.String() + f.operator(e.Operator)
+	}
+
+	if e.Value == nil {
+		return prefix
+	}
+
+	col += utf8.RuneCountInString(prefix)
+
+	value := e.Value
+	if f.opts.Parens == ParenMinimal && e.Field != nil {
+		if value = stripParen(value); !isAtomValue(value) {
+			return prefix + f.group("(", ")", value, level, col, multiline)
+		}
+	}
+
+	switch v := value.(type) {
+	case *BinaryExpr: // an atom value in parentheses
+		return prefix + f.printBinary(v, level, col, multiline)
+	case *ParenExpr:
+		return prefix + f.group("(", ")", v.Expr, level, col, multiline)
+	}
+
+	return prefix + f.value(value)
+}
+
+func (f *formatter) printValueList(e *ValueListExpr, level, col int, multiline bool) string {
+	prefix := f.not(e.HasNot) + e.Field.String() + f.operator(e.Operator)
+	col += utf8.RuneCountInString(prefix)
+
+	if values := stripParen(e.Values); f.opts.Parens == ParenMinimal && isAtomValue(values) {
+		if binary, ok := values.(*BinaryExpr); ok {
+			values = binary.Value
+		}
+
+		return prefix + f.value(values)
+	}
+
+	return prefix + f.group("(", ")", e.Values, level, col, multiline)
+}
+
+func (f *formatter) printCombine(e *CombineExpr, level, col int, multiline bool) string {
+	var (
+		buf      strings.Builder
+		operands = f.operands(e)
+		keyword  = f.keyword(e.Keyword)
+	)
+
+	for i, operand := range operands {
+		if i > 0 {
+			if multiline {
+				buf.WriteString("\n" + strings.Repeat(f.opts.Indent, level))
+				col = utf8.RuneCountInString(strings.Repeat(f.opts.Indent, level))
+			} else {
+				buf.WriteByte(' ')
+				col++
+			}
+
+			buf.WriteString(keyword + " ")
+			col += utf8.RuneCountInString(keyword) + 1
+		}
+
+		var text string
+
+		switch paren := f.needParen(operand, e.Keyword, i > 0); {
+		case !multiline:
+			text = f.print(operand, level, col, false)
+			if paren {
+				text = "(" + text + ")"
+			}
+		case paren:
+			if text = "(" + f.print(operand, level, col+1, false) + ")"; col+utf8.RuneCountInString(text) > f.opts.Width {
+				text = f.group("(", ")", operand, level, col, true)
+			}
+		default:
+			text = f.format(operand, level, col)
+		}
+
+		buf.WriteString(text)
+		col += utf8.RuneCountInString(text)
+	}
+
+	return buf.String()
+}
+
+// group prints expr between open and close, in multiple lines with expr indented if multiline is true.
+func (f *formatter) group(open, close string, expr Expr, level, col int, multiline bool) string {
+	if f.opts.Parens == ParenMinimal {
+		expr = stripParen(expr)
+	}
+
+	if !multiline {
+		return open + f.print(expr, level, col+len(open), false) + close
+	}
+
+	indent := strings.Repeat(f.opts.Indent, level+1)
+	inner := f.format(expr, level+1, utf8.RuneCountInString(indent))
+
+	return strings.TrimSpace(open) + "\n" + indent + inner + "\n" +
+		strings.Repeat(f.opts.Indent, level) + strings.TrimSpace(close)
+}
+
+// operands returns the operands of the clauses joined by the keyword of e, e.g. `a`, `b` and `c` of `a AND b AND c`.
+func (f *formatter) operands(e *CombineExpr) []Expr {
+	var list []Expr
+
+	for i, operand := range []Expr{e.LeftExpr, e.RightExpr} {
+		if f.opts.Parens == ParenMinimal {
+			operand = stripParen(operand)
+		}
+
+		// the left operand is grouped from left to right, the right one only for the associative AND and OR
+		combine, ok := operand.(*CombineExpr)
+		if ok && combine.Keyword == e.Keyword &&
+			(i == 0 || f.opts.Parens == ParenMinimal && e.Keyword != token.TokenKindKeywordNot) {
+			list = append(list, f.operands(combine)...)
+		} else if operand != nil {
+			list = append(list, operand)
+		}
+	}
+
+	return list
+}
+
+// needParen reports whether an operand of a combination expression of keyword needs parentheses.
+func (f *formatter) needParen(operand Expr, keyword token.Kind, right bool) bool {
+	combine, ok := operand.(*CombineExpr)
+	if !ok {
+		return false
+	}
+
+	if f.opts.Parens == ParenRedundant && combine.Keyword != keyword {
+		return true
+	}
+
+	precedence, parent := KeywordPrecedence(combine.Keyword), KeywordPrecedence(keyword)
+
+	return precedence < parent || (precedence == parent && right)
+}
+
+// value prints a value, which is quoted in the style of the options.
+func (f *formatter) value(value Expr) string {
+	lit, ok := value.(*Literal)
+	if !ok {
+		return value.String()
+	}
+
+	switch f.opts.Quote {
+	case QuoteAlways:
+		if lit.Kind == token.TokenKindIdent {
+			return quote(lit.Value)
+		}
+	case QuoteMinimal:
+		if lit.Kind == token.TokenKindString && isIdent(lit.Value) {
+			return lit.Value
+		}
+	}
+
+	return lit.String()
+}
+
+func (f *formatter) keyword(kind token.Kind) string {
+	if f.opts.KeywordCase == KeywordLower {
+		return strings.ToLower(kind.String())
+	}
+
+	return kind.String()
+}
+
+func (f *formatter) not(hasNot bool) string {
+	if !hasNot {
+		return ""
+	}
+
+	return f.keyword(token.TokenKindKeywordNot) + " "
+}
+
+// operator prints op with the spaces around it.
+func (f *formatter) operator(op token.Kind) string {
+	if op != token.TokenKindOperatorEql {
+		return " " + op.String() + " "
+	}
+
+	switch f.opts.ColonSpacing {
+	case ColonSpaceNone:
+		return ":"
+	case ColonSpaceAround:
+		return " : "
+	}
+
+	return ": "
+}
+
+// stripParen removes the parentheses around expr.
+func stripParen(expr Expr) Expr {
+	for {
+		switch e := expr.(type) {
+		case *ParenExpr:
+			expr = e.Expr
+		case *BinaryExpr: // a group of clauses, e.g. `(a OR b)` of `(a OR b) AND c`
+			paren, ok := e.Value.(*ParenExpr)
+			if !ok || e.Field != nil || e.HasNot {
+				return expr
+			}
+
+			expr = paren
+		default:
+			return expr
+		}
+	}
+}
+
+// isAtomValue reports whether expr is a single value without NOT, which needs no parentheses as a field value.
+func isAtomValue(expr Expr) bool {
+	if binary, ok := expr.(*BinaryExpr); ok && binary.Field == nil && !binary.HasNot {
+		expr = binary.Value
+	}
+
+	switch expr.(type) {
+	case *Literal, *WildcardExpr, *DateExpr:
+		return true
+	}
+
+	return false
+}
+
+// quote returns s as a string value in double quotes.
+func quote(s string) string {
+	return NewLiteral(0, 0, token.TokenKindString, s, stringEscapes(s)).String()
+}
+
+// stringEscapes returns the escape indexes of s as a string value,
+// the wildcards are escaped too as a string value with them is parsed as a wildcard expression.
+func stringEscapes(s string) []int {
+	var indexes []int
+
+	for i, r := range []rune(s) {
+		if r == '*' || token.RequireEscape(string(r), token.TokenKindString) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+// isIdent reports whether s can be written as an identifier value without escapes.
+func isIdent(s string) bool {
+	if s == "" || token.IsKeyword(s) {
+		return false
+	}
+
+	if r, _ := utf8.DecodeRuneInString(s); r == '+' || r == '-' || unicode.IsDigit(r) { // lexed as a number
+		return false
+	}
+
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '*' || token.RequireEscape(string(r), token.TokenKindIdent) {
+			return false
+		}
+	}
+
+	return true
+}
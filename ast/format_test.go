@@ -0,0 +1,136 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		opts  ast.FormatOptions
+		want  string
+	}{
+		{
+			name:  "lower keywords",
+			input: "NOT a: 1 AND b: 2 or NOT items: { c: * }",
+			opts:  ast.FormatOptions{KeywordCase: ast.KeywordLower},
+			want:  "not a: 1 and b: 2 or not items: { c: * }",
+		},
+		{
+			name:  "no space around colon",
+			input: "a: 1 AND b > 2 AND items: { c: * }",
+			opts:  ast.FormatOptions{ColonSpacing: ast.ColonSpaceNone},
+			want:  "a:1 AND b > 2 AND items:{ c:* }",
+		},
+		{
+			name:  "spaces around colon",
+			input: "a: 1 AND b > 2",
+			opts:  ast.FormatOptions{ColonSpacing: ast.ColonSpaceAround},
+			want:  "a : 1 AND b > 2",
+		},
+		{
+			name:  "minimal parentheses",
+			input: "((a: 1)) AND (b: 2 AND (c: 3)) AND f: ((x)) AND g: (NOT y) AND (d: 4 OR e: 5)",
+			opts:  ast.FormatOptions{Parens: ast.ParenMinimal},
+			want:  "a: 1 AND b: 2 AND c: 3 AND f: x AND g: (NOT y) AND (d: 4 OR e: 5)",
+		},
+		{
+			name:  "minimal parentheses keep NOT",
+			input: "a NOT (b NOT c) AND NOT (d OR e)",
+			opts:  ast.FormatOptions{Parens: ast.ParenMinimal},
+			want:  "a NOT (b NOT c) AND NOT (d OR e)",
+		},
+		{
+			name:  "redundant parentheses",
+			input: "a OR b AND c OR (d)",
+			opts:  ast.FormatOptions{Parens: ast.ParenRedundant},
+			want:  "a OR (b AND c) OR (d)",
+		},
+		{
+			name:  "quote always",
+			input: `a: b AND c: "d" AND e: 1 AND f: g* AND h: i\*j AND \and: \or`,
+			opts:  ast.FormatOptions{Quote: ast.QuoteAlways},
+			want:  `a: "b" AND c: "d" AND e: 1 AND f: g* AND h: "i\*j" AND \and: "or"`,
+		},
+		{
+			name:  "quote minimal",
+			input: `a: "b" AND c: "d e" AND f: "1" AND g: "or" AND h: "-x" AND i: "j:k" AND l: "*"`,
+			opts:  ast.FormatOptions{Quote: ast.QuoteMinimal},
+			want:  `a: b AND c: "d e" AND f: "1" AND g: "or" AND h: "-x" AND i: "j:k" AND l: "*"`,
+		},
+		{
+			name:  "fits the width",
+			input: "a: 1 AND (b: 2 OR c: 3)",
+			opts:  ast.FormatOptions{Width: 23},
+			want:  "a: 1 AND (b: 2 OR c: 3)",
+		},
+		{
+			name:  "multiple lines",
+			input: `status: active AND (level: error OR level: warn OR level: "fatal error") AND NOT user: bot`,
+			opts:  ast.FormatOptions{Width: 40},
+			want: "status: active\n" +
+				"AND (\n" +
+				"  level: error\n" +
+				"  OR level: warn\n" +
+				"  OR level: \"fatal error\"\n" +
+				")\n" +
+				"AND NOT user: bot",
+		},
+		{
+			name:  "multiple lines of value list and nested field query",
+			input: `level: (error OR warn OR fatal) AND items: { name: "a long name" AND qty > 2 }`,
+			opts:  ast.FormatOptions{Width: 32, Indent: "\t"},
+			want: "level: (error OR warn OR fatal)\n" +
+				"AND items: {\n" +
+				"\tname: \"a long name\" AND qty > 2\n" +
+				"}",
+		},
+		{
+			name:  "multiple lines of precedence parentheses",
+			input: "a: 1 OR b: 2 AND c: 3",
+			opts:  ast.FormatOptions{Width: 12, Parens: ast.ParenRedundant},
+			want: "a: 1\n" +
+				"OR (\n" +
+				"  b: 2\n" +
+				"  AND c: 3\n" +
+				")",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr, err := parser.New(c.input).Stmt()
+			require.NoError(t, err)
+
+			got := ast.Format(expr, c.opts)
+			assert.Equal(t, c.want, got)
+
+			formatted, err := parser.New(got).Stmt()
+			require.NoError(t, err)
+			assert.Equal(t, got, ast.Format(formatted, c.opts), "the format is stable")
+		})
+	}
+}
+
+func TestFormat_ZeroOptions(t *testing.T) {
+	inputs := []string{
+		`a\:b: "x \"y\""`,
+		`NOT f: * AND f*: a*b`,
+		`a: (1 OR 2) AND items: { a: 1 AND NOT b: 2 }`,
+		`a >= -1.5 and b<2 OR c`,
+		`((a)) OR a NOT (b NOT c)`,
+	}
+
+	for _, input := range inputs {
+		expr, err := parser.New(input).Stmt()
+		require.NoError(t, err)
+		assert.Equal(t, expr.String(), ast.Format(expr, ast.FormatOptions{}))
+	}
+}
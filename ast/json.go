@@ -0,0 +1,610 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// The values of the "type" member of the JSON objects of the expressions, see UnmarshalExpr.
+const (
+	JSONTypeBinary    = "binary"
+	JSONTypeCombine   = "combine"
+	JSONTypeParen     = "paren"
+	JSONTypeNested    = "nested"
+	JSONTypeValueList = "value_list"
+	JSONTypeExists    = "exists"
+	JSONTypeLiteral   = "literal"
+	JSONTypeWildcard  = "wildcard"
+	JSONTypeDate      = "date"
+	JSONTypeBad       = "bad"
+)
+
+// literalKinds are the values of the "kind" member of the JSON objects of the literal values.
+var literalKinds = map[string]token.Kind{
+	"int":    token.TokenKindInt,
+	"float":  token.TokenKindFloat,
+	"string": token.TokenKindString,
+	"ident":  token.TokenKindIdent,
+}
+
+// UnmarshalExpr decodes an expression encoded by json.Marshal, whose type is told by the "type" member
+// of the JSON object, e.g. {"type": "binary", ...}. The String method of the expression is identical
+// to the one of the encoded expression. JSON null is decoded as a nil expression.
+//
+// The structure is checked while decoding, since the JSON may come from other programs: the child expressions
+// (except the field of a value without field) must not be null and the indexes of the escapes and the wildcards
+// must be ascending indexes in the value.
+//
+// The format of the JSON objects is described by the JSON Schema in expr.schema.json.
+func UnmarshalExpr(data []byte) (Expr, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+
+	var head struct {
+		Type string `json:"type"`
+	}
+
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	var expr interface {
+		Expr
+		json.Unmarshaler
+	}
+
+	switch head.Type {
+	case JSONTypeBinary:
+		expr = new(BinaryExpr)
+	case JSONTypeCombine:
+		expr = new(CombineExpr)
+	case JSONTypeParen:
+		expr = new(ParenExpr)
+	case JSONTypeNested:
+		expr = new(NestedExpr)
+	case JSONTypeValueList:
+		expr = new(ValueListExpr)
+	case JSONTypeExists:
+		expr = new(ExistsExpr)
+	case JSONTypeLiteral:
+		expr = new(Literal)
+	case JSONTypeWildcard:
+		expr = new(WildcardExpr)
+	case JSONTypeDate:
+		expr = new(DateExpr)
+	case JSONTypeBad:
+		expr = new(BadExpr)
+	default:
+		return nil, fmt.Errorf("unknown expression type %q", head.Type)
+	}
+
+	if err := expr.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+// exprJSON is an expression as a member of a JSON object, which is decoded with UnmarshalExpr.
+type exprJSON struct {
+	Expr
+}
+
+func (e exprJSON) MarshalJSON() ([]byte, error) {
+	if e.Expr == nil {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(e.Expr)
+}
+
+func (e *exprJSON) UnmarshalJSON(data []byte) (err error) {
+	e.Expr, err = UnmarshalExpr(data)
+
+	return err
+}
+
+// checkType returns an error if the "type" member is not want.
+func checkType(got, want string) error {
+	if got != want {
+		return fmt.Errorf("expected expression type %q, but got %q", want, got)
+	}
+
+	return nil
+}
+
+// checkExpr returns an error if the member name of the expression type typ is null or missing.
+func checkExpr(typ, name string, e exprJSON) error {
+	if e.Expr == nil {
+		return fmt.Errorf("missing %s of expression type %q", name, typ)
+	}
+
+	return nil
+}
+
+// checkIndexes returns an error if indexes(the "escapes" or "wildcards" member) are not ascending rune indexes
+// of value, which is the unescaped value for the escapes and the quoted value with escapes for the wildcards.
+func checkIndexes(name string, indexes []int, value string) error {
+	n := utf8.RuneCountInString(value)
+	for i, index := range indexes {
+		if index < 0 || index >= n || i > 0 && index <= indexes[i-1] {
+			return fmt.Errorf("invalid %s index %d of value %q", name, index, value)
+		}
+	}
+
+	return nil
+}
+
+type binaryJSON struct {
+	Type     string   `json:"type"`
+	Pos      int      `json:"pos"`
+	Field    exprJSON `json:"field"`
+	Operator string   `json:"operator,omitempty"` // empty for a value without field
+	Value    exprJSON `json:"value"`
+	HasNot   bool     `json:"not"`
+}
+
+// MarshalJSON encodes the binary expression as a JSON object of the type "binary".
+func (e *BinaryExpr) MarshalJSON() ([]byte, error) {
+	v := binaryJSON{
+		Type:   JSONTypeBinary,
+		Pos:    e.pos,
+		Field:  exprJSON{e.Field},
+		Value:  exprJSON{e.Value},
+		HasNot: e.HasNot,
+	}
+
+	if e.Operator.IsOperator() {
+		v.Operator = e.Operator.String()
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the binary expression from a JSON object of the type "binary".
+func (e *BinaryExpr) UnmarshalJSON(data []byte) error {
+	var v binaryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeBinary); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "value", v.Value); err != nil {
+		return err
+	}
+
+	var operator token.Kind
+	if v.Operator != "" {
+		if operator = token.ToOperator(v.Operator); operator == token.TokenKindIllegal {
+			return fmt.Errorf("unknown operator %q", v.Operator)
+		}
+	} else if v.Field.Expr != nil {
+		return fmt.Errorf("missing operator of expression type %q with field", v.Type)
+	}
+
+	*e = *NewBinaryExpr(v.Pos, v.Field.Expr, operator, v.Value.Expr, v.HasNot)
+
+	return nil
+}
+
+type combineJSON struct {
+	Type      string   `json:"type"`
+	LeftExpr  exprJSON `json:"left"`
+	Keyword   string   `json:"keyword"`
+	RightExpr exprJSON `json:"right"`
+}
+
+// MarshalJSON encodes the combination expression as a JSON object of the type "combine".
+func (e *CombineExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(combineJSON{
+		Type:      JSONTypeCombine,
+		LeftExpr:  exprJSON{e.LeftExpr},
+		Keyword:   e.Keyword.String(),
+		RightExpr: exprJSON{e.RightExpr},
+	})
+}
+
+// UnmarshalJSON decodes the combination expression from a JSON object of the type "combine".
+func (e *CombineExpr) UnmarshalJSON(data []byte) error {
+	var v combineJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeCombine); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "left", v.LeftExpr); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "right", v.RightExpr); err != nil {
+		return err
+	}
+
+	keyword := token.ToKeyword(v.Keyword)
+	if keyword == token.TokenKindIllegal {
+		return fmt.Errorf("unknown keyword %q", v.Keyword)
+	}
+
+	*e = *NewCombineExpr(v.LeftExpr.Expr, keyword, v.RightExpr.Expr)
+
+	return nil
+}
+
+type parenJSON struct {
+	Type string   `json:"type"`
+	L    int      `json:"pos"`
+	R    int      `json:"end"`
+	Expr exprJSON `json:"expr"`
+}
+
+// MarshalJSON encodes the parenthesis expression as a JSON object of the type "paren".
+func (e *ParenExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(parenJSON{Type: JSONTypeParen, L: e.L, R: e.R, Expr: exprJSON{e.Expr}})
+}
+
+// UnmarshalJSON decodes the parenthesis expression from a JSON object of the type "paren".
+func (e *ParenExpr) UnmarshalJSON(data []byte) error {
+	var v parenJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeParen); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "expr", v.Expr); err != nil {
+		return err
+	}
+
+	*e = *NewParenExpr(v.L, v.R, v.Expr.Expr)
+
+	return nil
+}
+
+type nestedJSON struct {
+	Type   string   `json:"type"`
+	Pos    int      `json:"pos"`
+	Path   exprJSON `json:"path"`
+	L      int      `json:"lbrace"`
+	R      int      `json:"end"`
+	Expr   exprJSON `json:"expr"`
+	HasNot bool     `json:"not"`
+}
+
+// MarshalJSON encodes the nested field query expression as a JSON object of the type "nested".
+func (e *NestedExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nestedJSON{
+		Type:   JSONTypeNested,
+		Pos:    e.pos,
+		Path:   exprJSON{e.Path},
+		L:      e.L,
+		R:      e.R,
+		Expr:   exprJSON{e.Expr},
+		HasNot: e.HasNot,
+	})
+}
+
+// UnmarshalJSON decodes the nested field query expression from a JSON object of the type "nested".
+func (e *NestedExpr) UnmarshalJSON(data []byte) error {
+	var v nestedJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeNested); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "path", v.Path); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "expr", v.Expr); err != nil {
+		return err
+	}
+
+	*e = *NewNestedExpr(v.Pos, v.Path.Expr, v.L, v.R, v.Expr.Expr, v.HasNot)
+
+	return nil
+}
+
+type valueListJSON struct {
+	Type     string   `json:"type"`
+	Pos      int      `json:"pos"`
+	Field    exprJSON `json:"field"`
+	Operator string   `json:"operator"`
+	L        int      `json:"lparen"`
+	R        int      `json:"end"`
+	Values   exprJSON `json:"values"`
+	HasNot   bool     `json:"not"`
+}
+
+// MarshalJSON encodes the value list expression as a JSON object of the type "value_list".
+func (e *ValueListExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(valueListJSON{
+		Type:     JSONTypeValueList,
+		Pos:      e.pos,
+		Field:    exprJSON{e.Field},
+		Operator: e.Operator.String(),
+		L:        e.L,
+		R:        e.R,
+		Values:   exprJSON{e.Values},
+		HasNot:   e.HasNot,
+	})
+}
+
+// UnmarshalJSON decodes the value list expression from a JSON object of the type "value_list".
+func (e *ValueListExpr) UnmarshalJSON(data []byte) error {
+	var v valueListJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeValueList); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "field", v.Field); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "values", v.Values); err != nil {
+		return err
+	}
+
+	operator := token.ToOperator(v.Operator)
+	if operator == token.TokenKindIllegal {
+		return fmt.Errorf("unknown operator %q", v.Operator)
+	}
+
+	*e = *NewValueListExpr(v.Pos, v.Field.Expr, operator, v.L, v.R, v.Values.Expr, v.HasNot)
+
+	return nil
+}
+
+type existsJSON struct {
+	Type   string   `json:"type"`
+	Pos    int      `json:"pos"`
+	End    int      `json:"end"`
+	Field  exprJSON `json:"field"`
+	HasNot bool     `json:"not"`
+}
+
+// MarshalJSON encodes the field existence expression as a JSON object of the type "exists".
+func (e *ExistsExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(existsJSON{
+		Type:   JSONTypeExists,
+		Pos:    e.pos,
+		End:    e.end,
+		Field:  exprJSON{e.Field},
+		HasNot: e.HasNot,
+	})
+}
+
+// UnmarshalJSON decodes the field existence expression from a JSON object of the type "exists".
+func (e *ExistsExpr) UnmarshalJSON(data []byte) error {
+	var v existsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeExists); err != nil {
+		return err
+	}
+
+	if err := checkExpr(v.Type, "field", v.Field); err != nil {
+		return err
+	}
+
+	*e = *NewExistsExpr(v.Pos, v.End, v.Field.Expr, v.HasNot)
+
+	return nil
+}
+
+type literalJSON struct {
+	Type            string `json:"type"`
+	Pos             int    `json:"pos"`
+	End             int    `json:"end"`
+	Kind            string `json:"kind"`
+	Value           string `json:"value"`
+	WithDoubleQuote bool   `json:"quoted"`
+	EscapeIndexes   []int  `json:"escapes,omitempty"`
+	Indexes         []int  `json:"wildcards,omitempty"` // only for the wildcard expressions
+}
+
+func (e *Literal) toJSON(typ string) literalJSON {
+	v := literalJSON{
+		Type:            typ,
+		Pos:             e.pos,
+		End:             e.end,
+		Kind:            strings.ToLower(e.Kind.String()),
+		Value:           e.Value,
+		WithDoubleQuote: e.WithDoubleQuote,
+		EscapeIndexes:   e.escapeIndexes,
+	}
+
+	return v
+}
+
+func (v literalJSON) literal() (*Literal, error) {
+	kind, ok := literalKinds[v.Kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown literal kind %q", v.Kind)
+	}
+
+	if err := checkIndexes("escape", v.EscapeIndexes, v.Value); err != nil {
+		return nil, err
+	}
+
+	lit := NewLiteral(v.Pos, v.End, kind, v.Value, v.EscapeIndexes)
+	lit.WithDoubleQuote = v.WithDoubleQuote
+
+	return lit, nil
+}
+
+// MarshalJSON encodes the literal value as a JSON object of the type "literal".
+func (e *Literal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.toJSON(JSONTypeLiteral))
+}
+
+// UnmarshalJSON decodes the literal value from a JSON object of the type "literal".
+func (e *Literal) UnmarshalJSON(data []byte) error {
+	var v literalJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeLiteral); err != nil {
+		return err
+	}
+
+	lit, err := v.literal()
+	if err != nil {
+		return err
+	}
+
+	*e = *lit
+
+	return nil
+}
+
+// MarshalJSON encodes the wildcard expression as a JSON object of the type "wildcard",
+// which has the members of a literal value plus the wildcard indexes.
+func (e *WildcardExpr) MarshalJSON() ([]byte, error) {
+	v := e.Literal.toJSON(JSONTypeWildcard)
+	v.Indexes = e.Indexes
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the wildcard expression from a JSON object of the type "wildcard".
+func (e *WildcardExpr) UnmarshalJSON(data []byte) error {
+	var v literalJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeWildcard); err != nil {
+		return err
+	}
+
+	lit, err := v.literal()
+	if err != nil {
+		return err
+	}
+
+	if err := checkIndexes("wildcard", v.Indexes, lit.String()); err != nil { // the indexes in the quoted value
+		return err
+	}
+
+	*e = *NewWildcardExpr(lit, v.Indexes)
+
+	return nil
+}
+
+type dateJSON struct {
+	literalJSON
+	Anchor   string           `json:"anchor"`
+	Offsets  []dateOffsetJSON `json:"offsets,omitempty"`
+	Rounding string           `json:"rounding,omitempty"`
+}
+
+type dateOffsetJSON struct {
+	Value int    `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+// MarshalJSON encodes the date expression as a JSON object of the type "date",
+// which has the members of a literal value plus the anchor, the offsets and the rounding.
+func (e *DateExpr) MarshalJSON() ([]byte, error) {
+	v := dateJSON{literalJSON: e.Literal.toJSON(JSONTypeDate), Anchor: e.Anchor}
+	for _, offset := range e.Offsets {
+		v.Offsets = append(v.Offsets, dateOffsetJSON{Value: offset.Value, Unit: offset.Unit.String()})
+	}
+
+	if e.Rounding > 0 {
+		v.Rounding = e.Rounding.String()
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the date expression from a JSON object of the type "date".
+func (e *DateExpr) UnmarshalJSON(data []byte) error {
+	var v dateJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeDate); err != nil {
+		return err
+	}
+
+	lit, err := v.literal()
+	if err != nil {
+		return err
+	}
+
+	var offsets []DateOffset
+
+	for _, offset := range v.Offsets {
+		unit, err := ParseDateUnit(offset.Unit)
+		if err != nil {
+			return err
+		}
+
+		offsets = append(offsets, DateOffset{Value: offset.Value, Unit: unit})
+	}
+
+	var rounding DateUnit
+	if v.Rounding != "" {
+		if rounding, err = ParseDateUnit(v.Rounding); err != nil {
+			return err
+		}
+	}
+
+	*e = *NewDateExpr(lit, v.Anchor, offsets, rounding)
+
+	return nil
+}
+
+type badJSON struct {
+	Type  string `json:"type"`
+	Pos   int    `json:"pos"`
+	End   int    `json:"end"`
+	Value string `json:"value"`
+}
+
+// MarshalJSON encodes the bad expression as a JSON object of the type "bad".
+func (e *BadExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(badJSON{Type: JSONTypeBad, Pos: e.pos, End: e.end, Value: e.Value})
+}
+
+// UnmarshalJSON decodes the bad expression from a JSON object of the type "bad".
+func (e *BadExpr) UnmarshalJSON(data []byte) error {
+	var v badJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := checkType(v.Type, JSONTypeBad); err != nil {
+		return err
+	}
+
+	*e = *NewBadExpr(v.Pos, v.End, v.Value)
+
+	return nil
+}
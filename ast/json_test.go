@@ -0,0 +1,192 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+func TestUnmarshalExpr(t *testing.T) {
+	inputs := []string{
+		`a\:b: "x \"y\""`,
+		`NOT f: * AND labels.*: * AND f*: a*b\*c AND g: "*x*" AND h: 5*0`,
+		`a: (1 OR -2.5) AND items: { NOT a: 1 AND b <= 2 }`,
+		`NOT f*: (a AND NOT (b OR "c*"))`,
+		`((a)) OR a NOT (b NOT c) AND NOT (d OR e)`,
+		`日志: "错误" AND 🔥`,
+		`\and: \or`,
+		`@timestamp >= now-7d/d AND t < "2024-01-01||+1M-2h" AND t > "2024-01-02"`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			expr, err := parser.New(input).Stmt()
+			require.NoError(t, err)
+
+			data, err := json.Marshal(expr)
+			require.NoError(t, err)
+
+			got, err := ast.UnmarshalExpr(data)
+			require.NoError(t, err)
+			assert.Equal(t, expr.String(), got.String())
+			assert.True(t, ast.Equal(expr, got, ast.EqualOptions{}))
+		})
+	}
+}
+
+func TestUnmarshalExpr_Recovery(t *testing.T) {
+	expr, err := parser.New(`level: AND status: 200)`, parser.WithRecovery(true)).Stmt()
+	require.Error(t, err)
+
+	data, err := json.Marshal(expr)
+	require.NoError(t, err)
+
+	got, err := ast.UnmarshalExpr(data)
+	require.NoError(t, err)
+	assert.True(t, ast.Equal(expr, got, ast.EqualOptions{}))
+}
+
+func TestMarshalJSON(t *testing.T) {
+	expr, err := parser.New(`NOT a: "b*" OR c`).Stmt()
+	require.NoError(t, err)
+
+	data, err := json.Marshal(expr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "combine",
+		"left": {
+			"type": "binary",
+			"pos": 0,
+			"field": {"type": "literal", "pos": 4, "end": 5, "kind": "ident", "value": "a", "quoted": false},
+			"operator": ":",
+			"value": {"type": "wildcard", "pos": 7, "end": 11, "kind": "string", "value": "b*", "quoted": true,
+				"wildcards": [2]},
+			"not": true
+		},
+		"keyword": "OR",
+		"right": {
+			"type": "binary",
+			"pos": 15,
+			"field": null,
+			"value": {"type": "literal", "pos": 15, "end": 16, "kind": "ident", "value": "c", "quoted": false},
+			"not": false
+		}
+	}`, string(data))
+
+	// a node decodes into its own type
+	var lit ast.Literal
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "literal", "pos": 1, "end": 5, "kind": "ident",
+		"value": "a:b", "quoted": false, "escapes": [1]}`), &lit))
+	assert.Equal(t, `a\:b`, lit.String())
+	assert.Equal(t, 5, lit.End())
+
+	expr, err = parser.New(`f: (a)`).Stmt()
+	require.NoError(t, err)
+
+	data, err = json.Marshal(expr)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "value_list",
+		"pos": 0,
+		"field": {"type": "literal", "pos": 0, "end": 1, "kind": "ident", "value": "f", "quoted": false},
+		"operator": ":",
+		"lparen": 3,
+		"end": 6,
+		"values": {
+			"type": "binary",
+			"pos": 4,
+			"field": null,
+			"value": {"type": "literal", "pos": 4, "end": 5, "kind": "ident", "value": "a", "quoted": false},
+			"not": false
+		},
+		"not": false
+	}`, string(data))
+
+	expr, err = parser.New(`t > now-7d/d`).Stmt()
+	require.NoError(t, err)
+
+	data, err = json.Marshal(expr.(*ast.BinaryExpr).Value)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "date", "pos": 4, "end": 12, "kind": "ident", "value": "now-7d/d", "quoted": false,
+		"anchor": "now", "offsets": [{"value": -7, "unit": "d"}], "rounding": "d"}`, string(data))
+}
+
+func TestUnmarshalExpr_Error(t *testing.T) {
+	cases := []struct {
+		data string
+		want string
+	}{
+		{data: `{"type": "foo"}`, want: `unknown expression type "foo"`},
+		{data: `[]`, want: "json: cannot unmarshal array"},
+		{data: `{"type": "binary", "operator": "=", "field": null, "value": {"type": "literal", "kind": "ident", "value": "a"}}`, want: `unknown operator "="`},
+		{data: `{"type": "combine", "keyword": "XOR", "left": {"type": "literal", "kind": "ident", "value": "a"}, "right": {"type": "literal", "kind": "ident", "value": "a"}}`,
+			want: `unknown keyword "XOR"`},
+		{data: `{"type": "literal", "kind": "bool", "value": "true"}`, want: `unknown literal kind "bool"`},
+		{data: `{"type": "paren", "expr": {"type": "bar"}}`, want: `unknown expression type "bar"`},
+		{data: `{"type": "date", "kind": "ident", "offsets": [{"value": 1, "unit": "x"}]}`, want: `unknown date unit "x"`},
+		{data: `{"type": "date", "kind": "ident", "rounding": "D"}`, want: `unknown date unit "D"`},
+		{data: `{"type": "value_list", "operator": "", "field": {"type": "literal", "kind": "ident", "value": "a"}, "values": {"type": "literal", "kind": "ident", "value": "a"}}`,
+			want: `unknown operator ""`},
+		{data: `{"type": "paren", "pos": 0, "end": 1, "expr": null}`, want: `missing expr of expression type "paren"`},
+		{data: `{"type": "combine", "keyword": "AND", "left": null, "right": null}`,
+			want: `missing left of expression type "combine"`},
+		{data: `{"type": "combine", "keyword": "AND", "left": {"type": "literal", "kind": "ident", "value": "a"}}`,
+			want: `missing right of expression type "combine"`},
+		{data: `{"type": "binary", "field": null, "value": null}`, want: `missing value of expression type "binary"`},
+		{data: `{"type": "binary", "field": {"type": "literal", "kind": "ident", "value": "a"}, "value": {"type": "literal", "kind": "ident", "value": "a"}}`,
+			want: `missing operator of expression type "binary" with field`},
+		{data: `{"type": "nested", "path": {"type": "literal", "kind": "ident", "value": "a"}, "expr": null}`, want: `missing expr of expression type "nested"`},
+		{data: `{"type": "value_list", "operator": ":", "field": {"type": "literal", "kind": "ident", "value": "a"}, "values": null}`,
+			want: `missing values of expression type "value_list"`},
+		{data: `{"type": "exists", "field": null}`, want: `missing field of expression type "exists"`},
+		{data: `{"type": "literal", "kind": "ident", "value": "a:b", "escapes": [99]}`,
+			want: `invalid escape index 99 of value "a:b"`},
+		{data: `{"type": "literal", "kind": "ident", "value": "a:b:c", "escapes": [3, 1]}`,
+			want: `invalid escape index 1 of value "a:b:c"`},
+		{data: `{"type": "wildcard", "kind": "string", "quoted": true, "value": "a*", "wildcards": [-1]}`,
+			want: `invalid wildcard index -1 of value "\"a*\""`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.data, func(t *testing.T) {
+			_, err := ast.UnmarshalExpr([]byte(c.data))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.want)
+		})
+	}
+
+	var lit ast.Literal
+	assert.EqualError(t, json.Unmarshal([]byte(`{"type": "bad"}`), &lit), `expected expression type "literal", but got "bad"`)
+
+	expr, err := ast.UnmarshalExpr([]byte(" null "))
+	assert.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+func TestJSONSchema(t *testing.T) {
+	data, err := os.ReadFile("expr.schema.json")
+	require.NoError(t, err)
+
+	var schema struct {
+		Defs map[string]struct {
+			Properties map[string]struct {
+				Const string `json:"const"`
+			} `json:"properties"`
+		} `json:"$defs"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	for _, typ := range []string{
+		ast.JSONTypeBinary, ast.JSONTypeCombine, ast.JSONTypeParen, ast.JSONTypeNested, ast.JSONTypeValueList,
+		ast.JSONTypeExists, ast.JSONTypeLiteral, ast.JSONTypeWildcard, ast.JSONTypeDate, ast.JSONTypeBad,
+	} {
+		assert.Equal(t, typ, schema.Defs[typ].Properties["type"].Const, typ)
+	}
+}
@@ -1,6 +1,16 @@
 package ast
 
-import "github.com/laojianzi/kql-go/token"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laojianzi/kql-go/token"
+)
 
 // Literal is a literal(int, float, string or identifier) value.
 type Literal struct {
@@ -11,6 +21,11 @@ type Literal struct {
 	Kind            token.Kind // int, float, string or identifier
 	Value           string
 	WithDoubleQuote bool
+
+	// Coerced is the typed value(e.g. an int64 of a long field) attached by the parser when the type of the field
+	// is known(see parser.WithTypeResolver), it is nil otherwise. It is derived from Value,
+	// so it is ignored by Equal and not encoded to JSON.
+	Coerced interface{}
 }
 
 // NewLiteral creates a new literal value.
@@ -61,3 +76,110 @@ func (e *Literal) String() string {
 
 	return value
 }
+
+// timeLayouts are the layouts of Time when no layouts are given.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02", "2006-01", "2006"}
+
+// Unescaped returns the value without the escapes and the double quotes, e.g. `a*b` of `"a\*b"`.
+func (e *Literal) Unescaped() string {
+	return e.Value
+}
+
+// Int64 returns the value as a base 10 integer, e.g. 42 of `42` or `"42"`.
+func (e *Literal) Int64() (int64, error) {
+	v, err := strconv.ParseInt(e.Value, 10, 64)
+	if err != nil {
+		return 0, e.valueError("int64", numError(err))
+	}
+
+	return v, nil
+}
+
+// Float64 returns the value as a finite floating-point number, e.g. 1.5 of `1.5` or `"1.5"`.
+func (e *Literal) Float64() (float64, error) {
+	v, err := strconv.ParseFloat(e.Value, 64)
+	if err != nil {
+		return 0, e.valueError("float64", numError(err))
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, e.valueError("float64", strconv.ErrSyntax)
+	}
+
+	return v, nil
+}
+
+// Bool returns the value as a boolean, which is true or false in any case.
+func (e *Literal) Bool() (bool, error) {
+	switch {
+	case strings.EqualFold(e.Value, "true"):
+		return true, nil
+	case strings.EqualFold(e.Value, "false"):
+		return false, nil
+	}
+
+	return false, e.valueError("bool", errors.New("expected true or false"))
+}
+
+// Time returns the value as a time in the first of layouts that matches it,
+// a time without time zone is in UTC.
+//
+// Without layouts, the value is an RFC 3339 time(e.g. `"2024-01-02T03:04:05Z"`), a time without time zone
+// (e.g. `"2024-01-02T03:04:05"`), a date(e.g. `"2024-01-02"`, `2024-01` or `2024`) or milliseconds since
+// the Unix epoch(e.g. `1704164645000`), like the default format(strict_date_optional_time||epoch_millis)
+// of the date fields of Elasticsearch, which tries the dates first, e.g. `2024` is the year 2024.
+func (e *Literal) Time(layouts ...string) (time.Time, error) {
+	epoch := len(layouts) == 0
+	if epoch {
+		layouts = timeLayouts
+	}
+
+	t, err := parseTime(e.Value, layouts, time.UTC)
+	if err == nil {
+		return t, nil
+	}
+
+	if epoch {
+		if ms, err := strconv.ParseInt(e.Value, 10, 64); err == nil {
+			return time.Unix(ms/1e3, ms%1e3*1e6).UTC(), nil
+		}
+	}
+
+	return time.Time{}, e.valueError("time", err)
+}
+
+// parseTime returns s as a time in the first of layouts that matches it, a time without time zone is in loc.
+func parseTime(s string, layouts []string, loc *time.Location) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("expected layouts %q", layouts)
+}
+
+// IP returns the value as an IPv4 or IPv6 address, e.g. `10.0.0.1` or `"::1"`.
+func (e *Literal) IP() (net.IP, error) {
+	ip := net.ParseIP(e.Value)
+	if ip == nil {
+		return nil, e.valueError("IP", errors.New("expected an IPv4 or IPv6 address"))
+	}
+
+	return ip, nil
+}
+
+// valueError returns the error of the value which can not be converted into typ because of err.
+func (e *Literal) valueError(typ string, err error) error {
+	return fmt.Errorf("invalid %s value %s: %w", typ, e, err)
+}
+
+// numError returns the reason(e.g. strconv.ErrSyntax) of err returned by the strconv package.
+func numError(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+
+	return err
+}
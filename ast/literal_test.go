@@ -1,9 +1,13 @@
 package ast_test
 
 import (
+	"net"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/laojianzi/kql-go/ast"
 	"github.com/laojianzi/kql-go/token"
@@ -81,3 +85,105 @@ func TestLiteral(t *testing.T) {
 		})
 	}
 }
+
+func TestLiteral_Values(t *testing.T) {
+	lit := func(kind token.Kind, value string) *ast.Literal {
+		return ast.NewLiteral(0, 0, kind, value, nil)
+	}
+
+	t.Run("Int64", func(t *testing.T) {
+		v, err := lit(token.TokenKindInt, "-42").Int64()
+		require.NoError(t, err)
+		assert.Equal(t, int64(-42), v)
+
+		v, err = lit(token.TokenKindString, "42").Int64()
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), v)
+
+		_, err = lit(token.TokenKindFloat, "4.2").Int64()
+		assert.EqualError(t, err, "invalid int64 value 4.2: invalid syntax")
+		assert.ErrorIs(t, err, strconv.ErrSyntax)
+
+		_, err = lit(token.TokenKindInt, "99999999999999999999").Int64()
+		assert.EqualError(t, err, "invalid int64 value 99999999999999999999: value out of range")
+		assert.ErrorIs(t, err, strconv.ErrRange)
+	})
+
+	t.Run("Float64", func(t *testing.T) {
+		v, err := lit(token.TokenKindFloat, "1.5").Float64()
+		require.NoError(t, err)
+		assert.Equal(t, 1.5, v)
+
+		v, err = lit(token.TokenKindInt, "2").Float64()
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, v)
+
+		_, err = lit(token.TokenKindIdent, "fast").Float64()
+		assert.EqualError(t, err, "invalid float64 value fast: invalid syntax")
+
+		_, err = lit(token.TokenKindIdent, "NaN").Float64()
+		assert.EqualError(t, err, "invalid float64 value NaN: invalid syntax")
+
+		_, err = lit(token.TokenKindString, "Inf").Float64()
+		assert.EqualError(t, err, `invalid float64 value "Inf": invalid syntax`)
+	})
+
+	t.Run("Bool", func(t *testing.T) {
+		v, err := lit(token.TokenKindIdent, "TRUE").Bool()
+		require.NoError(t, err)
+		assert.True(t, v)
+
+		v, err = lit(token.TokenKindString, "false").Bool()
+		require.NoError(t, err)
+		assert.False(t, v)
+
+		_, err = lit(token.TokenKindInt, "1").Bool()
+		assert.EqualError(t, err, "invalid bool value 1: expected true or false")
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		cases := map[string]time.Time{
+			"2024-01-02T03:04:05.5+08:00": time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.FixedZone("", 8*3600)),
+			"2024-01-02T03:04:05":         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			"2024-01-02":                  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			"2024-03":                     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			"2024":                        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			"1704164645123":               time.Date(2024, 1, 2, 3, 4, 5, 123e6, time.UTC),
+			"-1":                          time.Date(1969, 12, 31, 23, 59, 59, 999e6, time.UTC),
+		}
+
+		for value, want := range cases {
+			got, err := lit(token.TokenKindString, value).Time()
+			require.NoError(t, err)
+			assert.True(t, want.Equal(got), "%s: %s", value, got)
+		}
+
+		got, err := lit(token.TokenKindString, "02/01/2024").Time("2006-01-02", "02/01/2006")
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), got)
+
+		_, err = lit(token.TokenKindString, "1704164645123").Time("2006-01-02")
+		assert.EqualError(t, err, `invalid time value "1704164645123": expected layouts ["2006-01-02"]`)
+
+		_, err = lit(token.TokenKindIdent, "yesterday").Time()
+		assert.EqualError(t, err, `invalid time value yesterday: expected layouts `+
+			`["2006-01-02T15:04:05.999999999Z07:00" "2006-01-02T15:04:05.999999999" "2006-01-02" "2006-01" "2006"]`)
+	})
+
+	t.Run("IP", func(t *testing.T) {
+		v, err := lit(token.TokenKindFloat, "10.0.0.1").IP()
+		require.NoError(t, err)
+		assert.Equal(t, net.IPv4(10, 0, 0, 1), v)
+
+		v, err = lit(token.TokenKindString, "::1").IP()
+		require.NoError(t, err)
+		assert.Equal(t, net.IPv6loopback, v)
+
+		_, err = lit(token.TokenKindString, "10.0.0.256").IP()
+		assert.EqualError(t, err, `invalid IP value "10.0.0.256": expected an IPv4 or IPv6 address`)
+	})
+
+	t.Run("Unescaped", func(t *testing.T) {
+		assert.Equal(t, `a*"b`, ast.NewLiteral(0, 7, token.TokenKindString, `a*"b`, []int{1, 2}).Unescaped())
+	})
+}
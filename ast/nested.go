@@ -0,0 +1,59 @@
+package ast
+
+import "strings"
+
+// NestedExpr is a nested field query expression, the fields of the inner expression are relative to the path.
+//
+// Example:
+//
+//	`items: { name: "x" AND qty > 2 }`
+type NestedExpr struct {
+	pos    int
+	Path   Expr // *Literal
+	L, R   int  // left and right position of the brace
+	Expr   Expr
+	HasNot bool
+}
+
+// NewNestedExpr creates a new nested field query expression.
+func NewNestedExpr(pos int, path Expr, L, R int, expr Expr, hasNot bool) *NestedExpr {
+	return &NestedExpr{
+		pos:    pos,
+		Path:   path,
+		L:      L,
+		R:      R,
+		Expr:   expr,
+		HasNot: hasNot,
+	}
+}
+
+// Pos returns the position of the nested field query expression.
+func (e *NestedExpr) Pos() int {
+	return e.pos
+}
+
+// End returns the end position of the nested field query expression.
+func (e *NestedExpr) End() int {
+	return e.R
+}
+
+// PathName returns the unescaped name of the path.
+func (e *NestedExpr) PathName() string {
+	return fieldName(e.Path)
+}
+
+// String returns the string representation of the nested field query expression.
+func (e *NestedExpr) String() string {
+	var buf strings.Builder
+
+	if e.HasNot {
+		buf.WriteString("NOT ")
+	}
+
+	buf.WriteString(e.Path.String())
+	buf.WriteString(": { ")
+	buf.WriteString(e.Expr.String())
+	buf.WriteString(" }")
+
+	return buf.String()
+}
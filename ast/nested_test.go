@@ -0,0 +1,65 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestNestedExpr(t *testing.T) {
+	type args struct {
+		pos    int
+		path   ast.Expr
+		l, r   int
+		expr   ast.Expr
+		hasNot bool
+	}
+
+	cases := []struct {
+		name       string
+		args       args
+		wantPos    int
+		wantEnd    int
+		wantString string
+	}{
+		{
+			name: `items: { name: "x" }`,
+			args: args{
+				path: ast.NewLiteral(0, 5, token.TokenKindIdent, "items", nil),
+				l:    7,
+				r:    20,
+				expr: ast.NewBinaryExpr(9, ast.NewLiteral(9, 13, token.TokenKindIdent, "name", nil), token.TokenKindOperatorEql, ast.NewLiteral(15, 18, token.TokenKindString, "x", nil), false),
+			},
+			wantEnd:    20,
+			wantString: `items: { name: "x" }`,
+		},
+		{
+			name: `NOT items: { name: "x" AND qty > 2 }`,
+			args: args{
+				path: ast.NewLiteral(4, 9, token.TokenKindIdent, "items", nil),
+				l:    11,
+				r:    36,
+				expr: ast.NewCombineExpr(
+					ast.NewBinaryExpr(13, ast.NewLiteral(13, 17, token.TokenKindIdent, "name", nil), token.TokenKindOperatorEql, ast.NewLiteral(19, 22, token.TokenKindString, "x", nil), false),
+					token.TokenKindKeywordAnd,
+					ast.NewBinaryExpr(27, ast.NewLiteral(27, 30, token.TokenKindIdent, "qty", nil), token.TokenKindOperatorGtr, ast.NewLiteral(33, 34, token.TokenKindInt, "2", nil), false),
+				),
+				hasNot: true,
+			},
+			wantEnd:    36,
+			wantString: `NOT items: { name: "x" AND qty > 2 }`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr := ast.NewNestedExpr(c.args.pos, c.args.path, c.args.l, c.args.r, c.args.expr, c.args.hasNot)
+			assert.Equal(t, c.wantPos, expr.Pos())
+			assert.Equal(t, c.wantEnd, expr.End())
+			assert.Equal(t, c.wantString, expr.String())
+		})
+	}
+}
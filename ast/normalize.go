@@ -0,0 +1,191 @@
+package ast
+
+import (
+	"crypto/sha256"
+	"sort"
+	"unicode"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Normalize returns the canonical form of expr, so the queries that differ only in the writing
+// (e.g. `level:error and service:api` and `service: api AND (level: "error")`) have the same form:
+//
+//   - the nested combination expressions of the same AND/OR keyword are flattened and
+//     their operands are sorted by the string representation, e.g. `b AND (c AND a)` is `a AND b AND c`
+//   - the parentheses that are not required by the precedence are dropped, e.g. `((a)) OR (b AND c)` is `a OR b AND c`
+//     and `NOT (f: v)` is `NOT f: v`
+//   - the string and identifier values of a single term are unquoted if they can be written as identifiers and
+//     quoted otherwise, e.g. `f: "v"` is `f: v` and `f: \or` is `f: "or"`, the other values keep their quotes
+//     as a phrase(e.g. `f: "foo-bar"`) is not the same query as its terms(e.g. `f: foo-bar`)
+//
+// The keywords are not kept in the expression, print the canonical form with NormalizedString,
+// which writes them in lower case. The order of the operands of `a NOT b` is kept as it is not commutative.
+//
+// Normalize does not modify expr, the returned expression shares the unchanged leaves with it
+// and the positions of its nodes are the positions in the original query.
+func Normalize(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return normalizeBinary(e)
+	case *CombineExpr:
+		return normalizeCombine(e)
+	case *ParenExpr:
+		return Normalize(e.Expr)
+	case *NestedExpr:
+		return NewNestedExpr(e.pos, e.Path, e.L, e.R, Normalize(e.Expr), e.HasNot)
+	case *ValueListExpr:
+		return normalizeValueList(e)
+	case *Literal:
+		return normalizeLiteral(e)
+	}
+
+	return expr
+}
+
+// NormalizedString returns the string representation of the canonical form(see Normalize) of expr
+// with lower-case keywords.
+func NormalizedString(expr Expr) string {
+	if expr == nil {
+		return ""
+	}
+
+	return Format(Normalize(expr), FormatOptions{KeywordCase: KeywordLower})
+}
+
+// Fingerprint returns the SHA-256 hash of NormalizedString of expr, the semantically identical queries
+// that Normalize brings to the same form have the same fingerprint, e.g. as the key of a result cache.
+func Fingerprint(expr Expr) [32]byte {
+	return sha256.Sum256([]byte(NormalizedString(expr)))
+}
+
+func normalizeBinary(e *BinaryExpr) Expr {
+	paren, ok := e.Value.(*ParenExpr)
+	if !ok {
+		return NewBinaryExpr(e.pos, e.Field, e.Operator, Normalize(e.Value), e.HasNot)
+	}
+
+	if e.Field != nil { // a list of values that is not parsed as *ValueListExpr, e.g. built by hand
+		return normalizeValueList(NewValueListExpr(e.pos, e.Field, e.Operator, paren.L, paren.R, paren.Expr, e.HasNot))
+	}
+
+	inner := Normalize(paren.Expr)
+
+	if !e.HasNot { // a group of clauses, e.g. `(a OR b)`
+		return inner
+	}
+
+	switch v := inner.(type) {
+	case *BinaryExpr:
+		if !v.HasNot {
+			return NewBinaryExpr(e.pos, v.Field, v.Operator, v.Value, true)
+		}
+	case *NestedExpr:
+		if !v.HasNot {
+			return NewNestedExpr(e.pos, v.Path, v.L, v.R, v.Expr, true)
+		}
+	case *ValueListExpr:
+		if !v.HasNot {
+			return NewValueListExpr(e.pos, v.Field, v.Operator, v.L, v.R, v.Values, true)
+		}
+	case *ExistsExpr:
+		if !v.HasNot {
+			return NewExistsExpr(e.pos, v.end, v.Field, true)
+		}
+	}
+
+	return NewBinaryExpr(e.pos, nil, e.Operator, NewParenExpr(paren.L, paren.R, inner), true)
+}
+
+// normalizeValueList normalizes the values of e, a single value without NOT is a plain clause,
+// e.g. `f: ("a")` is `f: a`.
+func normalizeValueList(e *ValueListExpr) Expr {
+	inner := Normalize(e.Values)
+
+	if isAtomValue(inner) {
+		if binary, ok := inner.(*BinaryExpr); ok {
+			inner = binary.Value
+		}
+
+		return NewBinaryExpr(e.pos, e.Field, e.Operator, inner, e.HasNot)
+	}
+
+	return NewValueListExpr(e.pos, e.Field, e.Operator, e.L, e.R, inner, e.HasNot)
+}
+
+func normalizeCombine(e *CombineExpr) Expr {
+	if e.Keyword != token.TokenKindKeywordAnd && e.Keyword != token.TokenKindKeywordOr {
+		return NewCombineExpr(Normalize(e.LeftExpr), e.Keyword, Normalize(e.RightExpr))
+	}
+
+	var operands []Expr
+
+	for _, operand := range []Expr{e.LeftExpr, e.RightExpr} {
+		if operand != nil {
+			operands = appendOperands(operands, Normalize(operand), e.Keyword)
+		}
+	}
+
+	if len(operands) == 0 {
+		return nil
+	}
+
+	keys := make(map[Expr]string, len(operands))
+	for _, operand := range operands {
+		keys[operand] = operand.String()
+	}
+
+	sort.SliceStable(operands, func(i, j int) bool {
+		return keys[operands[i]] < keys[operands[j]]
+	})
+
+	result := operands[0]
+	for _, operand := range operands[1:] {
+		result = NewCombineExpr(result, e.Keyword, operand)
+	}
+
+	return result
+}
+
+// appendOperands appends the operands of the clauses of expr joined by keyword, e.g. `a`, `b` and `c` of
+// `a AND b AND c`, to list.
+func appendOperands(list []Expr, expr Expr, keyword token.Kind) []Expr {
+	combine, ok := expr.(*CombineExpr)
+	if !ok || combine.Keyword != keyword {
+		return append(list, expr)
+	}
+
+	list = appendOperands(list, combine.LeftExpr, keyword)
+
+	return appendOperands(list, combine.RightExpr, keyword)
+}
+
+// normalizeLiteral quotes or unquotes a string or identifier value that is a single term, the numbers and
+// the other values are kept as they are, since a quoted value is a phrase(e.g. `"foo-bar"`) that is not the same
+// query as the terms of the unquoted value(e.g. `foo-bar`).
+func normalizeLiteral(lit *Literal) Expr {
+	switch {
+	case lit.Kind != token.TokenKindIdent && lit.Kind != token.TokenKindString || !isSingleTerm(lit.Value):
+		return lit
+	case isIdent(lit.Value):
+		return NewLiteral(lit.pos, lit.end, token.TokenKindIdent, lit.Value, nil)
+	}
+
+	return NewLiteral(lit.pos, lit.end, token.TokenKindString, lit.Value, stringEscapes(lit.Value))
+}
+
+// isSingleTerm reports whether s is analyzed into a single term by the standard analyzer of Elasticsearch,
+// which is made of letters, digits and underscores, except the ideographs and hiragana that are a term each.
+func isSingleTerm(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) || unicode.In(r, unicode.Han, unicode.Hiragana) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,107 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: `level:error and service:api`, want: "level: error and service: api"},
+		{input: `service: api AND (level: ("error"))`, want: "level: error and service: api"},
+		{input: `b AND (c AND a)`, want: "a and b and c"},
+		{input: `c OR (b OR a) OR d AND e`, want: "a or b or c or d and e"},
+		{input: `((a)) OR (b AND c)`, want: "a or b and c"},
+		{input: `(b OR a) AND c`, want: "(a or b) and c"},
+		{input: `NOT (f: v) AND NOT (items: { a: 1 }) AND NOT (g: *)`, want: "not f: v and not g: * and not items: { a: 1 }"},
+		{input: `NOT (b OR a)`, want: "not (a or b)"},
+		{input: `NOT (NOT a)`, want: "not (not a)"},
+		{input: `f: (c OR "a" OR b)`, want: "f: (a or b or c)"},
+		{input: `f: ((a)) AND g: (NOT a)`, want: "f: a and g: (not a)"},
+		{input: `NOT (f: (b OR a)) AND g: (b AND (a AND c))`, want: "not f: (a or b) and g: (a and b and c)"},
+		{input: `c OR b NOT a`, want: "b not a or c"},
+		{input: `x: { b: 1 AND a: 2 }`, want: "x: { a: 2 and b: 1 }"},
+		{input: `f: a\:b AND g: a\*b AND h: "x*" AND i: \or AND j: "1" AND k >= 1.5 AND l: "a_1" AND m: "a b"`,
+			want: `f: a\:b and g: a\*b and h: "x*" and i: "or" and j: "1" and k >= 1.5 and l: a_1 and m: "a b"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			expr, err := parser.New(c.input).Stmt()
+			require.NoError(t, err)
+
+			original := expr.String()
+			normalized := ast.NormalizedString(expr)
+			assert.Equal(t, c.want, normalized)
+			assert.Equal(t, original, expr.String(), "the expression is not modified")
+
+			reparsed, err := parser.New(normalized).Stmt()
+			require.NoError(t, err)
+			assert.Equal(t, normalized, ast.NormalizedString(reparsed), "the normalization is stable")
+			assert.Equal(t, ast.Fingerprint(expr), ast.Fingerprint(reparsed))
+		})
+	}
+}
+
+func TestNormalize_ValueList(t *testing.T) {
+	field := ast.NewLiteral(0, 1, token.TokenKindIdent, "f", nil)
+	values := ast.NewCombineExpr(
+		ast.NewBinaryExpr(4, nil, 0, ast.NewLiteral(4, 5, token.TokenKindIdent, "b", nil), false),
+		token.TokenKindKeywordOr,
+		ast.NewBinaryExpr(9, nil, 0, ast.NewLiteral(9, 10, token.TokenKindIdent, "a", nil), false),
+	)
+
+	// a value list that is not parsed as *ast.ValueListExpr, e.g. built by hand
+	got := ast.Normalize(ast.NewBinaryExpr(0, field, token.TokenKindOperatorEql, ast.NewParenExpr(3, 11, values), false))
+	assert.IsType(t, &ast.ValueListExpr{}, got)
+
+	want, err := parser.New(`f: (a OR b)`).Stmt()
+	require.NoError(t, err)
+	assert.Equal(t, ast.NormalizedString(want), ast.NormalizedString(got))
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(query string) [32]byte {
+		expr, err := parser.New(query).Stmt()
+		require.NoError(t, err)
+
+		return ast.Fingerprint(expr)
+	}
+
+	want := fingerprint(`level:error and service:api`)
+	for _, query := range []string{
+		`service: api AND level: "error"`,
+		`(service: api) and ((level: error))`,
+		`level: ("error") AND service: api`,
+	} {
+		assert.Equal(t, want, fingerprint(query), query)
+	}
+
+	for _, query := range []string{
+		`level:error or service:api`,
+		`level:error and service:"api*"`,
+		`level:error and NOT service:api`,
+		`level:error and service:api and service:api`,
+	} {
+		assert.NotEqual(t, want, fingerprint(query), query)
+	}
+
+	assert.Equal(t, fingerprint(`a NOT b`), fingerprint(`(a) NOT "b"`))
+	assert.NotEqual(t, fingerprint(`a NOT b`), fingerprint(`b NOT a`))
+	assert.Equal(t, fingerprint(`n: 1`), fingerprint(`n: (1)`))
+	assert.NotEqual(t, fingerprint(`n: 1`), fingerprint(`n: "1"`))
+
+	// a phrase is not the same query as its terms
+	assert.NotEqual(t, fingerprint(`msg: foo-bar`), fingerprint(`msg: "foo-bar"`))
+	assert.NotEqual(t, fingerprint(`msg: 错误`), fingerprint(`msg: "错误"`))
+	assert.Equal(t, fingerprint(`msg: "foo-bar"`), fingerprint(`msg: ("foo-bar")`))
+}
@@ -26,7 +26,7 @@ func TestParenExpr(t *testing.T) {
 			name: `(f1: "v1")`,
 			args: args{
 				R:    10,
-				Expr: ast.NewBinaryExpr(1, "f1", token.TokenKindOperatorEql, ast.NewLiteral(5, 9, token.TokenKindString, "v1", nil), false),
+				Expr: ast.NewBinaryExpr(1, ast.NewLiteral(1, 3, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(5, 9, token.TokenKindString, "v1", nil), false),
 			},
 			wantEnd:    10,
 			wantString: `(f1: "v1")`,
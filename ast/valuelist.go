@@ -0,0 +1,139 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+// ValueListExpr is a field followed by a list of values in parentheses, the field and the operator apply
+// to each value and the values are combined with AND, OR and NOT.
+//
+// Example:
+//
+//	`level: (error OR warn)`
+//	`NOT tags: (prod AND NOT "eu-west")`
+type ValueListExpr struct {
+	pos      int
+	Field    Expr       // *Literal or *WildcardExpr(e.g. `machine.os*`)
+	Operator token.Kind // token.TokenKindOperatorEql, the range operators do not take a list of values
+	L, R     int        // left and right position of the parenthesis
+
+	// Values is the expression inside the parentheses. Like a value without field, a value(*Literal or
+	// *WildcardExpr) or a group of values in parentheses(*ParenExpr) is the Value of a *BinaryExpr without field,
+	// which has the NOT of it, and the values are combined with *CombineExpr.
+	Values Expr
+	HasNot bool
+}
+
+// NewValueListExpr creates a new value list expression.
+func NewValueListExpr(pos int, field Expr, operator token.Kind, L, R int, values Expr, hasNot bool) *ValueListExpr {
+	return &ValueListExpr{
+		pos:      pos,
+		Field:    field,
+		Operator: operator,
+		L:        L,
+		R:        R,
+		Values:   values,
+		HasNot:   hasNot,
+	}
+}
+
+// Pos returns the position of the value list expression.
+func (e *ValueListExpr) Pos() int {
+	return e.pos
+}
+
+// End returns the end position of the value list expression.
+func (e *ValueListExpr) End() int {
+	return e.R
+}
+
+// FieldName returns the unescaped name of the field.
+func (e *ValueListExpr) FieldName() string {
+	return fieldName(e.Field)
+}
+
+// String returns the string representation of the value list expression.
+func (e *ValueListExpr) String() string {
+	var buf strings.Builder
+
+	if e.HasNot {
+		buf.WriteString("NOT ")
+	}
+
+	buf.WriteString(e.Field.String())
+
+	if e.Operator != token.TokenKindOperatorEql {
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString(e.Operator.String())
+	buf.WriteString(" (")
+	buf.WriteString(e.Values.String())
+	buf.WriteByte(')')
+
+	return buf.String()
+}
+
+// Distribute returns the plain field clauses of the value list, the field and the operator are distributed
+// over the values, e.g. `level: (error OR NOT warn)` is `(level: error OR NOT level: warn)`.
+//
+// Several values are a group of clauses in parentheses that keeps the NOT of the value list,
+// a single value is a single clause, e.g. `NOT level: (error)` is `NOT level: error`.
+// A bare wildcard value is a field existence expression, e.g. `f: (*)` is `f: *`.
+// The clauses share the field and the values with e.
+func (e *ValueListExpr) Distribute() Expr {
+	clauses := e.distribute(e.Values)
+
+	if _, ok := clauses.(*CombineExpr); ok {
+		return NewBinaryExpr(e.pos, nil, 0, NewParenExpr(e.L, e.R, clauses), e.HasNot)
+	}
+
+	if e.HasNot {
+		return negate(clauses)
+	}
+
+	return clauses
+}
+
+// distribute returns the clauses of the values of expr, which is inside the parentheses of e.
+func (e *ValueListExpr) distribute(expr Expr) Expr {
+	switch v := expr.(type) {
+	case *CombineExpr:
+		return NewCombineExpr(e.distribute(v.LeftExpr), v.Keyword, e.distribute(v.RightExpr))
+	case *ParenExpr:
+		return NewParenExpr(v.L, v.R, e.distribute(v.Expr))
+	case *BinaryExpr:
+		if v.Field != nil {
+			return v
+		}
+
+		if paren, ok := v.Value.(*ParenExpr); ok { // a group of values
+			return NewBinaryExpr(v.pos, nil, 0, NewParenExpr(paren.L, paren.R, e.distribute(paren.Expr)), v.HasNot)
+		}
+
+		if wildcard, ok := v.Value.(*WildcardExpr); ok && e.Operator == token.TokenKindOperatorEql &&
+			wildcard.Kind == token.TokenKindIdent && wildcard.Value == token.TokenKindWildcard.String() {
+			return NewExistsExpr(v.pos, v.End(), e.Field, v.HasNot)
+		}
+
+		return NewBinaryExpr(v.pos, e.Field, e.Operator, v.Value, v.HasNot)
+	}
+
+	return expr
+}
+
+// DistributeValueLists rewrites every value list in expr into plain field clauses with ValueListExpr.Distribute,
+// e.g. for a backend that does not support value lists.
+//
+// DistributeValueLists modifies the expressions in place like Apply.
+func DistributeValueLists(expr Expr) Expr {
+	return Apply(expr, func(c *Cursor) bool {
+		if e, ok := c.Expr().(*ValueListExpr); ok {
+			c.Replace(e.Distribute())
+		}
+
+		return true
+	}, nil)
+}
@@ -0,0 +1,126 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestValueListExpr(t *testing.T) {
+	type args struct {
+		pos      int
+		field    ast.Expr
+		operator token.Kind
+		l, r     int
+		values   ast.Expr
+		hasNot   bool
+	}
+
+	cases := []struct {
+		name       string
+		args       args
+		wantPos    int
+		wantEnd    int
+		wantString string
+	}{
+		{
+			name: `level: (error OR "warn")`,
+			args: args{
+				field:    ast.NewLiteral(0, 5, token.TokenKindIdent, "level", nil),
+				operator: token.TokenKindOperatorEql,
+				l:        7,
+				r:        24,
+				values: ast.NewCombineExpr(
+					ast.NewBinaryExpr(8, nil, 0, ast.NewLiteral(8, 13, token.TokenKindIdent, "error", nil), false),
+					token.TokenKindKeywordOr,
+					ast.NewBinaryExpr(17, nil, 0, ast.NewLiteral(17, 23, token.TokenKindString, "warn", nil), false),
+				),
+			},
+			wantEnd:    24,
+			wantString: `level: (error OR "warn")`,
+		},
+		{
+			name: `NOT tags: (NOT prod)`,
+			args: args{
+				pos:      0,
+				field:    ast.NewLiteral(4, 8, token.TokenKindIdent, "tags", nil),
+				operator: token.TokenKindOperatorEql,
+				l:        10,
+				r:        20,
+				values:   ast.NewBinaryExpr(11, nil, 0, ast.NewLiteral(15, 19, token.TokenKindIdent, "prod", nil), true),
+				hasNot:   true,
+			},
+			wantEnd:    20,
+			wantString: `NOT tags: (NOT prod)`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr := ast.NewValueListExpr(c.args.pos, c.args.field, c.args.operator, c.args.l, c.args.r, c.args.values,
+				c.args.hasNot)
+			assert.Equal(t, c.wantPos, expr.Pos())
+			assert.Equal(t, c.wantEnd, expr.End())
+			assert.Equal(t, c.wantString, expr.String())
+			assert.Equal(t, c.args.field.(*ast.Literal).Value, expr.FieldName())
+
+			got, err := parser.New(c.name).Stmt()
+			require.NoError(t, err)
+			assert.True(t, ast.Equal(expr, got, ast.EqualOptions{}), "%#v", got)
+		})
+	}
+}
+
+func TestValueListExpr_Distribute(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{input: `level: (error OR warn)`, want: `(level: error OR level: warn)`},
+		{input: `NOT level: (error OR NOT "warn")`, want: `NOT (level: error OR NOT level: "warn")`},
+		{input: `level: (error OR (warn AND NOT w*))`, want: `(level: error OR (level: warn AND NOT level: w*))`},
+		{input: `level: (error)`, want: `level: error`},
+		{input: `NOT level: (NOT error)`, want: `level: error`},
+		{input: `level: ((error OR warn))`, want: `(level: error OR level: warn)`},
+		{input: `l*: (*)`, want: `l*: *`},
+		{input: `level: ("*")`, want: `level: "*"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			expr, err := parser.New(c.input).Stmt()
+			require.NoError(t, err)
+
+			list, ok := expr.(*ast.ValueListExpr)
+			require.True(t, ok, "%T", expr)
+
+			got := list.Distribute()
+			assert.Equal(t, c.want, got.String())
+			assert.Equal(t, c.input, list.String(), "the value list is not modified")
+
+			want, err := parser.New(c.want).Stmt()
+			require.NoError(t, err)
+			assert.True(t, ast.Equal(want, got, ast.EqualOptions{IgnorePositions: true}), "%#v", got)
+		})
+	}
+}
+
+func TestDistributeValueLists(t *testing.T) {
+	expr, err := parser.New(`a: (1 OR 2) AND NOT items: { b: (x AND y) } OR c: 3`).Stmt()
+	require.NoError(t, err)
+
+	got := ast.DistributeValueLists(expr)
+	assert.Equal(t, `(a: 1 OR a: 2) AND NOT items: { (b: x AND b: y) } OR c: 3`, got.String())
+
+	ast.Inspect(got, func(e ast.Expr) bool {
+		_, ok := e.(*ast.ValueListExpr)
+		assert.False(t, ok, "%s", e)
+
+		return e != nil
+	})
+}
@@ -0,0 +1,91 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each expression encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of expr with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(expr Expr) (w Visitor)
+}
+
+// Walk traverses an expression(AST) in depth-first order: It starts by calling v.Visit(expr);
+// expr must not be nil. If the visitor w returned by v.Visit(expr) is not nil,
+// Walk is invoked recursively with visitor w for each of the non-nil children of expr,
+// followed by a call of w.Visit(nil).
+func Walk(v Visitor, expr Expr) {
+	if v = v.Visit(expr); v == nil {
+		return
+	}
+
+	walkChildren(expr, func(child Expr) {
+		Walk(v, child)
+	})
+
+	v.Visit(nil)
+}
+
+type inspector func(Expr) bool
+
+func (f inspector) Visit(expr Expr) Visitor {
+	if f(expr) {
+		return f
+	}
+
+	return nil
+}
+
+// Inspect traverses an expression(AST) in depth-first order: It starts by calling f(expr);
+// expr must not be nil. If f returns true, Inspect invokes f recursively for each of the non-nil children of expr,
+// followed by a call of f(nil).
+func Inspect(expr Expr, f func(Expr) bool) {
+	Walk(inspector(f), expr)
+}
+
+// Traverse traverses an expression(AST) in depth-first order, calling pre(expr) before the children of expr
+// are visited and post(expr) after. Either of pre and post may be nil.
+//
+// If pre returns false, the children of expr are skipped and post is not called for expr.
+func Traverse(expr Expr, pre func(Expr) bool, post func(Expr)) {
+	if pre != nil && !pre(expr) {
+		return
+	}
+
+	walkChildren(expr, func(child Expr) {
+		Traverse(child, pre, post)
+	})
+
+	if post != nil {
+		post(expr)
+	}
+}
+
+// walkChildren calls f for each of the non-nil children of expr in source order.
+func walkChildren(expr Expr, f func(Expr)) {
+	var children []Expr
+
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		children = []Expr{e.Field, e.Value}
+	case *CombineExpr:
+		children = []Expr{e.LeftExpr, e.RightExpr}
+	case *ParenExpr:
+		children = []Expr{e.Expr}
+	case *NestedExpr:
+		children = []Expr{e.Path, e.Expr}
+	case *ValueListExpr:
+		children = []Expr{e.Field, e.Values}
+	case *ExistsExpr:
+		children = []Expr{e.Field}
+	case *Literal, *WildcardExpr, *DateExpr, *BadExpr:
+		// nothing to do
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected expression type %T", e))
+	}
+
+	for _, child := range children {
+		if child != nil {
+			f(child)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package ast_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// f1: ("v1" OR v*) AND NOT items: { qty > 2 }
+func newWalkTestExpr() ast.Expr {
+	return ast.NewCombineExpr(
+		ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewParenExpr(4, 16, ast.NewCombineExpr(
+			ast.NewBinaryExpr(5, nil, 0, ast.NewLiteral(5, 9, token.TokenKindString, "v1", nil), false),
+			token.TokenKindKeywordOr,
+			ast.NewBinaryExpr(13, nil, 0, ast.NewWildcardExpr(ast.NewLiteral(13, 15, token.TokenKindIdent, "v*", nil), []int{1}), false),
+		)), false),
+		token.TokenKindKeywordAnd,
+		ast.NewNestedExpr(21, ast.NewLiteral(25, 30, token.TokenKindIdent, "items", nil), 32, 43, ast.NewBinaryExpr(
+			34, ast.NewLiteral(34, 37, token.TokenKindIdent, "qty", nil), token.TokenKindOperatorGtr, ast.NewLiteral(40, 41, token.TokenKindInt, "2", nil), false,
+		), true),
+	)
+}
+
+type recorder struct {
+	events *[]string
+}
+
+func (r recorder) Visit(expr ast.Expr) ast.Visitor {
+	if expr == nil {
+		*r.events = append(*r.events, "end")
+
+		return nil
+	}
+
+	*r.events = append(*r.events, fmt.Sprintf("%T", expr))
+
+	if _, ok := expr.(*ast.ParenExpr); ok { // skip the children of parenthesis
+		return nil
+	}
+
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	var events []string
+
+	ast.Walk(recorder{events: &events}, newWalkTestExpr())
+	assert.Equal(t, []string{
+		"*ast.CombineExpr",
+		"*ast.BinaryExpr",
+		"*ast.Literal",
+		"end",
+		"*ast.ParenExpr",
+		"end",
+		"*ast.NestedExpr",
+		"*ast.Literal",
+		"end",
+		"*ast.BinaryExpr",
+		"*ast.Literal",
+		"end",
+		"*ast.Literal",
+		"end",
+		"end",
+		"end",
+		"end",
+	}, events)
+}
+
+func TestInspect(t *testing.T) {
+	var values []string
+
+	ast.Inspect(newWalkTestExpr(), func(expr ast.Expr) bool {
+		switch e := expr.(type) {
+		case *ast.Literal:
+			values = append(values, e.String())
+		case *ast.WildcardExpr:
+			values = append(values, e.String())
+		case *ast.NestedExpr:
+			return false
+		}
+
+		return true
+	})
+	assert.Equal(t, []string{"f1", `"v1"`, "v*"}, values)
+}
+
+func TestTraverse(t *testing.T) {
+	var events []string
+
+	ast.Traverse(newWalkTestExpr(), func(expr ast.Expr) bool {
+		events = append(events, fmt.Sprintf("pre %T", expr))
+
+		_, isParen := expr.(*ast.ParenExpr)
+
+		return !isParen
+	}, func(expr ast.Expr) {
+		events = append(events, fmt.Sprintf("post %T", expr))
+	})
+	assert.Equal(t, []string{
+		"pre *ast.CombineExpr",
+		"pre *ast.BinaryExpr",
+		"pre *ast.Literal",
+		"post *ast.Literal",
+		"pre *ast.ParenExpr",
+		"post *ast.BinaryExpr",
+		"pre *ast.NestedExpr",
+		"pre *ast.Literal",
+		"post *ast.Literal",
+		"pre *ast.BinaryExpr",
+		"pre *ast.Literal",
+		"post *ast.Literal",
+		"pre *ast.Literal",
+		"post *ast.Literal",
+		"post *ast.BinaryExpr",
+		"post *ast.NestedExpr",
+		"post *ast.CombineExpr",
+	}, events)
+}
+
+func TestWalk_UnexpectedExpr(t *testing.T) {
+	assert.Panics(t, func() {
+		ast.Inspect(unknownExpr{}, func(ast.Expr) bool { return true })
+	})
+}
+
+type unknownExpr struct{}
+
+func (unknownExpr) Pos() int       { return 0 }
+func (unknownExpr) End() int       { return 0 }
+func (unknownExpr) String() string { return "" }
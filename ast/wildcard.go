@@ -1,5 +1,7 @@
 package ast
 
+import "strings"
+
 // WildcardExpr is a wildcard expression.
 //
 // Example:
@@ -36,3 +38,57 @@ func (e *WildcardExpr) End() int {
 func (e *WildcardExpr) String() string {
 	return e.Literal.String()
 }
+
+// Segments splits the unescaped value around every wildcard that is not escaped.
+//
+// e.g. `a*b\*c*` is split into "a", "b*c" and "", `*` is split into "" and "".
+func (e *WildcardExpr) Segments() []string {
+	escaped := make(map[int]bool, len(e.escapeIndexes))
+	for _, i := range e.escapeIndexes {
+		escaped[i] = true
+	}
+
+	var (
+		segments []string
+		buf      strings.Builder
+	)
+
+	for i, r := range []rune(e.Value) {
+		if r == '*' && !escaped[i] {
+			segments = append(segments, buf.String())
+			buf.Reset()
+
+			continue
+		}
+
+		buf.WriteRune(r)
+	}
+
+	return append(segments, buf.String())
+}
+
+// Match reports whether s matches the wildcard expression, every wildcard matches any sequence of characters.
+func (e *WildcardExpr) Match(s string) bool {
+	segments := e.Segments()
+	if len(segments) == 1 {
+		return s == segments[0]
+	}
+
+	if !strings.HasPrefix(s, segments[0]) {
+		return false
+	}
+
+	s = s[len(segments[0]):]
+
+	last := segments[len(segments)-1]
+	for _, segment := range segments[1 : len(segments)-1] {
+		i := strings.Index(s, segment)
+		if i < 0 {
+			return false
+		}
+
+		s = s[i+len(segment):]
+	}
+
+	return strings.HasSuffix(s, last)
+}
@@ -153,3 +153,51 @@ func TestWildcard(t *testing.T) {
 		})
 	}
 }
+
+func TestWildcardExpr_Segments(t *testing.T) {
+	cases := []struct {
+		name          string
+		value         string
+		escapeIndexes []int
+		want          []string
+	}{
+		{name: "only wildcard", value: "*", want: []string{"", ""}},
+		{name: "wildcard in the middle", value: "f*o", want: []string{"f", "o"}},
+		{name: "multi-wildcard", value: "*o*", want: []string{"", "o", ""}},
+		{name: "escaped wildcard", value: "a*b*c*", escapeIndexes: []int{3}, want: []string{"a", "b*c", ""}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr := ast.NewWildcardExpr(ast.NewLiteral(0, 0, token.TokenKindIdent, c.value, c.escapeIndexes), nil)
+			assert.Equal(t, c.want, expr.Segments())
+		})
+	}
+}
+
+func TestWildcardExpr_Match(t *testing.T) {
+	cases := []struct {
+		value         string
+		escapeIndexes []int
+		s             string
+		want          bool
+	}{
+		{value: "*", s: "", want: true},
+		{value: "*", s: "anything", want: true},
+		{value: "f*o", s: "foo", want: true},
+		{value: "f*o", s: "fo", want: true},
+		{value: "f*o", s: "foa", want: false},
+		{value: "*o*o*", s: "foo", want: true},
+		{value: "*o*o*", s: "fo", want: false},
+		{value: "a*b*", escapeIndexes: []int{1}, s: "a*bc", want: true},
+		{value: "a*b*", escapeIndexes: []int{1}, s: "axbc", want: false},
+		{value: "ab*ab", s: "ab", want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value+" "+c.s, func(t *testing.T) {
+			expr := ast.NewWildcardExpr(ast.NewLiteral(0, 0, token.TokenKindIdent, c.value, c.escapeIndexes), nil)
+			assert.Equal(t, c.want, expr.Match(c.s))
+		})
+	}
+}
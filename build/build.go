@@ -0,0 +1,411 @@
+// Package build provides a fluent builder of KQL(kibana query language) expressions(AST), e.g.
+//
+//	q := build.Field("status").Eq("active").And(build.Field("age").Gte(18)).Not(build.Field("user").Eq("bot"))
+//	// q.String() == `status: "active" AND age >= 18 AND NOT user: "bot"`
+//
+// The fields and values are escaped like the parser expects(see token.RequireEscape), so the String method
+// of a built expression always parses back to an equal(regardless of the positions, see ast.Equal) expression.
+// The nodes of a built expression have no source, their positions are 0.
+package build
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Query is a query under construction. It carries the first error of its construction(e.g. an unsupported value),
+// which is returned by Build and passed on to the queries combined with it.
+//
+// The zero value is an empty query, which is skipped by And and Or, e.g. for an optional user filter.
+type Query struct {
+	expr ast.Expr
+	err  error
+}
+
+// From returns a query of expr, e.g. a filter parsed from the user input. The expression is shared, not copied.
+func From(expr ast.Expr) Query {
+	return Query{expr: expr}
+}
+
+// Value returns a query of a value without field(free text search), e.g. `"error"`.
+func Value(v interface{}) Query {
+	lit, err := literal(v)
+	if err != nil {
+		return Query{err: err}
+	}
+
+	return Query{expr: ast.NewBinaryExpr(0, nil, 0, lit, false)}
+}
+
+// And combines the non-empty queries with AND.
+func And(queries ...Query) Query {
+	return combine(token.TokenKindKeywordAnd, queries)
+}
+
+// Or combines the non-empty queries with OR.
+func Or(queries ...Query) Query {
+	return combine(token.TokenKindKeywordOr, queries)
+}
+
+// Not negates q, e.g. `NOT f: v` and `NOT (a OR b)`, an empty query stays empty.
+func Not(q Query) Query {
+	if q.err != nil || q.expr == nil {
+		return q
+	}
+
+	// the negated copy of a clause, which is printed with a leading NOT
+	switch e := ast.Clone(q.expr).(type) {
+	case *ast.BinaryExpr:
+		if !e.HasNot {
+			e.HasNot = true
+
+			return Query{expr: e}
+		}
+	case *ast.NestedExpr:
+		if !e.HasNot {
+			e.HasNot = true
+
+			return Query{expr: e}
+		}
+	case *ast.ValueListExpr:
+		if !e.HasNot {
+			e.HasNot = true
+
+			return Query{expr: e}
+		}
+	case *ast.ExistsExpr:
+		if !e.HasNot {
+			e.HasNot = true
+
+			return Query{expr: e}
+		}
+	}
+
+	return Query{expr: ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, q.expr), true)}
+}
+
+// And combines q and the other non-empty queries with AND.
+func (q Query) And(others ...Query) Query {
+	return And(append([]Query{q}, others...)...)
+}
+
+// Or combines q and the other non-empty queries with OR.
+func (q Query) Or(others ...Query) Query {
+	return Or(append([]Query{q}, others...)...)
+}
+
+// Not combines q and the negation of other with AND, e.g. `q AND NOT other`.
+func (q Query) Not(other Query) Query {
+	return And(q, Not(other))
+}
+
+// Build returns the expression of q, it is nil for an empty query.
+func (q Query) Build() (ast.Expr, error) {
+	return q.expr, q.err
+}
+
+// Err returns the first error of the construction of q.
+func (q Query) Err() error {
+	return q.err
+}
+
+// String returns the KQL(kibana query language) query of q, it is empty for an empty query or a query with error.
+func (q Query) String() string {
+	if q.err != nil || q.expr == nil {
+		return ""
+	}
+
+	return q.expr.String()
+}
+
+// FieldBuilder builds the clauses of a field.
+type FieldBuilder struct {
+	field *ast.Literal
+	err   error
+}
+
+// Field returns the builder of the clauses of the field name, the special characters and keywords of the name
+// are escaped and a `*` is not a wildcard. A name can not be empty, contain spaces, start with a sign or digit
+// or be invalid UTF-8, which can not be written in KQL.
+func Field(name string) FieldBuilder {
+	r, _ := utf8.DecodeRuneInString(name)
+	if name == "" || r == '+' || r == '-' || unicode.IsDigit(r) || !utf8.ValidString(name) {
+		return FieldBuilder{err: fmt.Errorf("invalid field name %q", name)}
+	}
+
+	var indexes []int
+
+	for i, r := range []rune(name) {
+		if unicode.IsSpace(r) {
+			return FieldBuilder{err: fmt.Errorf("invalid field name %q", name)}
+		}
+
+		if r == '*' || token.RequireEscape(string(r), token.TokenKindIdent) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	if token.IsKeyword(name) { // e.g. `\and`
+		indexes = []int{0}
+	}
+
+	return FieldBuilder{field: ast.NewLiteral(0, 0, token.TokenKindIdent, name, indexes)}
+}
+
+// Eq returns the clause `field: value`, see Value for the values.
+func (f FieldBuilder) Eq(value interface{}) Query {
+	return f.clause(token.TokenKindOperatorEql, value)
+}
+
+// Lt returns the clause `field < value`, value must be a number, a time.Time or a date string(e.g. "now-7d/d").
+func (f FieldBuilder) Lt(value interface{}) Query {
+	return f.clause(token.TokenKindOperatorLss, value)
+}
+
+// Lte returns the clause `field <= value`, value must be a number, a time.Time or a date string(e.g. "now-7d/d").
+func (f FieldBuilder) Lte(value interface{}) Query {
+	return f.clause(token.TokenKindOperatorLeq, value)
+}
+
+// Gt returns the clause `field > value`, value must be a number, a time.Time or a date string(e.g. "now-7d/d").
+func (f FieldBuilder) Gt(value interface{}) Query {
+	return f.clause(token.TokenKindOperatorGtr, value)
+}
+
+// Gte returns the clause `field >= value`, value must be a number, a time.Time or a date string(e.g. "now-7d/d").
+func (f FieldBuilder) Gte(value interface{}) Query {
+	return f.clause(token.TokenKindOperatorGeq, value)
+}
+
+// In returns the value list(*ast.ValueListExpr) `field: (value1 OR value2 ...)` of at least one value.
+func (f FieldBuilder) In(values ...interface{}) Query {
+	if f.err != nil {
+		return Query{err: f.err}
+	}
+
+	if len(values) == 0 {
+		return Query{err: fmt.Errorf("no values of field %q", f.field.Value)}
+	}
+
+	var list ast.Expr
+
+	for _, v := range values {
+		lit, err := literal(v)
+		if err != nil {
+			return Query{err: err}
+		}
+
+		value := ast.NewBinaryExpr(0, nil, 0, lit, false)
+		if list == nil {
+			list = value
+		} else {
+			list = ast.NewCombineExpr(list, token.TokenKindKeywordOr, value)
+		}
+	}
+
+	return Query{expr: ast.NewValueListExpr(0, f.field, token.TokenKindOperatorEql, 0, 0, list, false)}
+}
+
+// Exists returns the clause `field: *`.
+func (f FieldBuilder) Exists() Query {
+	if f.err != nil {
+		return Query{err: f.err}
+	}
+
+	return Query{expr: ast.NewExistsExpr(0, 0, f.field, false)}
+}
+
+// Wildcard returns the clause `field: "pattern"`, every `*` of the pattern matches any sequence of characters.
+func (f FieldBuilder) Wildcard(pattern string) Query {
+	if f.err != nil {
+		return Query{err: f.err}
+	}
+
+	if !utf8.ValidString(pattern) {
+		return Query{err: fmt.Errorf("invalid UTF-8 value %q", pattern)}
+	}
+
+	var escapes, wildcards []int
+
+	offset := 1 // the indexes of the wildcards are the offsets in the quoted string with escapes
+
+	for i, r := range []rune(pattern) {
+		switch {
+		case r == '*':
+			wildcards = append(wildcards, offset+i)
+		case token.RequireEscape(string(r), token.TokenKindString):
+			escapes = append(escapes, i)
+			offset++
+		}
+	}
+
+	lit := ast.NewLiteral(0, 0, token.TokenKindString, pattern, escapes)
+	if len(wildcards) == 0 {
+		return Query{expr: ast.NewBinaryExpr(0, f.field, token.TokenKindOperatorEql, lit, false)}
+	}
+
+	value := ast.NewWildcardExpr(lit, wildcards)
+
+	return Query{expr: ast.NewBinaryExpr(0, f.field, token.TokenKindOperatorEql, value, false)}
+}
+
+// Nested returns the nested field query `field: { q }`, the fields of q are relative to the field.
+func (f FieldBuilder) Nested(q Query) Query {
+	switch {
+	case f.err != nil:
+		return Query{err: f.err}
+	case q.err != nil:
+		return q
+	case q.expr == nil:
+		return Query{err: fmt.Errorf("empty nested field query of field %q", f.field.Value)}
+	}
+
+	return Query{expr: ast.NewNestedExpr(0, f.field, 0, 0, q.expr, false)}
+}
+
+func (f FieldBuilder) clause(op token.Kind, v interface{}) Query {
+	if f.err != nil {
+		return Query{err: f.err}
+	}
+
+	lit, err := literal(v)
+	if err != nil {
+		return Query{err: err}
+	}
+
+	if op != token.TokenKindOperatorEql && lit.Kind != token.TokenKindInt && lit.Kind != token.TokenKindFloat {
+		date, err := ast.ParseDateExpr(lit)
+		if err != nil {
+			return Query{err: fmt.Errorf("range value of field %q must be a number or a date, but got %v(%T)",
+				f.field.Value, v, v)}
+		}
+
+		return Query{expr: ast.NewBinaryExpr(0, f.field, op, date, false)}
+	}
+
+	return Query{expr: ast.NewBinaryExpr(0, f.field, op, lit, false)}
+}
+
+// combine joins the non-empty queries with keyword from left to right, the operands joined by the same keyword
+// are flattened, e.g. `a AND (b AND c)` is `a AND b AND c`, and the other ones that bind looser
+// are put into parentheses like the parser does.
+func combine(keyword token.Kind, queries []Query) Query {
+	var operands []ast.Expr
+
+	for _, q := range queries {
+		if q.err != nil {
+			return q
+		}
+
+		operands = appendOperands(operands, q.expr, keyword)
+	}
+
+	if len(operands) == 0 {
+		return Query{}
+	}
+
+	result := group(operands[0], keyword, false)
+	for _, operand := range operands[1:] {
+		result = ast.NewCombineExpr(result, keyword, group(operand, keyword, true))
+	}
+
+	return Query{expr: result}
+}
+
+func appendOperands(list []ast.Expr, expr ast.Expr, keyword token.Kind) []ast.Expr {
+	if expr == nil {
+		return list
+	}
+
+	c, ok := expr.(*ast.CombineExpr)
+	if !ok || c.Keyword != keyword {
+		return append(list, expr)
+	}
+
+	return appendOperands(appendOperands(list, c.LeftExpr, keyword), c.RightExpr, keyword)
+}
+
+// group puts an operand of a combination expression of keyword into parentheses if it binds looser
+// or it is on the right of the same precedence.
+func group(operand ast.Expr, keyword token.Kind, right bool) ast.Expr {
+	c, ok := operand.(*ast.CombineExpr)
+	if !ok {
+		return operand
+	}
+
+	precedence, parent := precedenceOf(c.Keyword), precedenceOf(keyword)
+	if precedence > parent || (precedence == parent && !right) {
+		return operand
+	}
+
+	return ast.NewBinaryExpr(0, nil, 0, ast.NewParenExpr(0, 0, operand), false)
+}
+
+// precedenceOf returns the binding power of keyword, the higher binds tighter.
+func precedenceOf(keyword token.Kind) int {
+	if keyword == token.TokenKindKeywordOr {
+		return 1
+	}
+
+	return 2
+}
+
+// literal returns the literal of a value: a string(quoted with the special characters escaped, a `*` is not
+// a wildcard), a number, a bool, a time.Time(quoted in RFC 3339) or a fmt.Stringer(quoted).
+func literal(v interface{}) (*ast.Literal, error) {
+	switch v := v.(type) {
+	case string:
+		return stringLiteral(v)
+	case bool:
+		return ast.NewLiteral(0, 0, token.TokenKindIdent, strconv.FormatBool(v), nil), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return ast.NewLiteral(0, 0, token.TokenKindInt, fmt.Sprint(v), nil), nil
+	case float32:
+		return floatLiteral(float64(v), 32)
+	case float64:
+		return floatLiteral(v, 64)
+	case time.Time:
+		return stringLiteral(v.Format(time.RFC3339Nano))
+	case fmt.Stringer:
+		return stringLiteral(v.String())
+	}
+
+	return nil, fmt.Errorf("unsupported value %v(%T)", v, v)
+}
+
+func stringLiteral(s string) (*ast.Literal, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("invalid UTF-8 value %q", s)
+	}
+
+	var indexes []int
+
+	for i, r := range []rune(s) {
+		if r == '*' || token.RequireEscape(string(r), token.TokenKindString) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return ast.NewLiteral(0, 0, token.TokenKindString, s, indexes), nil
+}
+
+// floatLiteral returns the literal of a float, which is written in decimal with a fraction, e.g. `1.0`.
+func floatLiteral(f float64, bitSize int) (*ast.Literal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("unsupported value %v", f)
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, bitSize)
+	if _, frac := math.Modf(f); frac == 0 {
+		s += ".0"
+	}
+
+	return ast.NewLiteral(0, 0, token.TokenKindFloat, s, nil), nil
+}
@@ -0,0 +1,161 @@
+package build_test
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go"
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/build"
+	"github.com/laojianzi/kql-go/parser"
+)
+
+func TestQuery(t *testing.T) {
+	cases := []struct {
+		name  string
+		query build.Query
+		want  string
+	}{
+		{
+			name:  "chain",
+			query: build.Field("status").Eq("active").And(build.Field("age").Gte(18)).Not(build.Field("user").Eq("bot")),
+			want:  `status: "active" AND age >= 18 AND NOT user: "bot"`,
+		},
+		{
+			name:  "escaped values",
+			query: build.Field("msg").Eq(`say "hi" \ *`).Or(build.Value("a*b"), build.Value(`"`)),
+			want:  `msg: "say \"hi\" \\ \*" OR "a\*b" OR "\""`,
+		},
+		{
+			name: "escaped fields",
+			query: build.And(
+				build.Field("a:b").Eq(1), build.Field("and").Eq(2), build.Field("x*").Exists(),
+				build.Field(`{"}(<)>\`).Lt(3), build.Field("日志.level").Eq("错误"),
+			),
+			want: `a\:b: 1 AND \and: 2 AND x\*: * AND \{\"\}\(\<\)\>\\ < 3 AND 日志.level: "错误"`,
+		},
+		{
+			name: "values",
+			query: build.And(
+				build.Field("a").Eq(true), build.Field("b").Eq(-5), build.Field("c").Lte(uint8(7)),
+				build.Field("d").Gt(1.0), build.Field("e").Lt(float32(-0.25)), build.Field("f").Eq(""),
+				build.Field("g").Eq(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)), build.Field("h").Eq(net.IPv4(10, 0, 0, 1)),
+			),
+			want: `a: true AND b: -5 AND c <= 7 AND d > 1.0 AND e < -0.25 AND f: "" AND g: "2024-01-02T03:04:05Z" ` +
+				`AND h: "10.0.0.1"`,
+		},
+		{
+			name: "precedence",
+			query: build.Or(
+				build.Field("a").Eq(1).And(build.Field("b").Eq(2)),
+				build.Field("c").Eq(3).Or(build.Field("d").Eq(4)),
+			).And(build.Field("e").Eq(5)),
+			want: `(a: 1 AND b: 2 OR c: 3 OR d: 4) AND e: 5`,
+		},
+		{
+			name: "dates",
+			query: build.And(
+				build.Field("@timestamp").Gte("now-7d/d"), build.Field("@timestamp").Lt(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+				build.Field("t").Gt("2024-01-01||+1M"),
+			),
+			want: `@timestamp >= "now-7d/d" AND @timestamp < "2024-01-02T00:00:00Z" AND t > "2024-01-01||+1M"`,
+		},
+		{
+			name:  "flattened",
+			query: build.Field("a").Eq(1).And(build.Field("b").Eq(2).And(build.Field("c").Eq(3))),
+			want:  `a: 1 AND b: 2 AND c: 3`,
+		},
+		{
+			name:  "negation",
+			query: build.And(build.Not(build.Or(build.Value("a"), build.Value("b"))), build.Not(build.Not(build.Value("c")))),
+			want:  `NOT ("a" OR "b") AND NOT (NOT "c")`,
+		},
+		{
+			name: "in, wildcard and nested",
+			query: build.And(
+				build.Field("level").In("error", "warn"),
+				build.Field("name").Wildcard(`jo*n "x"*`),
+				build.Field("name").Wildcard("plain"),
+				build.Field("items").Nested(build.Field("qty").Gt(2).Or(build.Not(build.Field("sku").Exists()))),
+				build.Not(build.Field("items").Nested(build.Field("qty").Eq(0))),
+			),
+			want: `level: ("error" OR "warn") AND name: "jo*n \"x\"*" AND name: "plain" ` +
+				`AND items: { qty > 2 OR NOT sku: * } AND NOT items: { qty: 0 }`,
+		},
+		{
+			name:  "empty queries are skipped",
+			query: build.And(build.Query{}, build.Field("a").Eq(1), build.Or(), build.Not(build.Query{})),
+			want:  `a: 1`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr, err := c.query.Build()
+			require.NoError(t, err)
+			assert.Equal(t, c.want, c.query.String())
+
+			parsed, err := parser.New(c.query.String()).Stmt()
+			require.NoError(t, err)
+			assert.True(t, ast.Equal(expr, parsed, ast.EqualOptions{IgnorePositions: true}), "the query parses back to the tree")
+		})
+	}
+}
+
+func TestFrom(t *testing.T) {
+	user := kql.MustParse(`level: error OR NOT level: warn`)
+	q := build.Field("tenant").Eq("t1").And(build.From(user))
+	assert.Equal(t, `tenant: "t1" AND (level: error OR NOT level: warn)`, q.String())
+
+	negated := build.Not(build.From(kql.MustParse(`NOT level: warn`)))
+	assert.Equal(t, `NOT (NOT level: warn)`, negated.String())
+	assert.Equal(t, `level: error OR NOT level: warn`, user.String(), "the expression is not modified")
+
+	for _, q := range []build.Query{q, negated} {
+		expr, err := q.Build()
+		require.NoError(t, err)
+		assert.True(t, ast.Equal(expr, kql.MustParse(q.String()), ast.EqualOptions{IgnorePositions: true}))
+	}
+
+	expr, err := build.And(build.Query{}, build.Query{}).Build()
+	assert.NoError(t, err)
+	assert.Nil(t, expr)
+}
+
+func TestQuery_Error(t *testing.T) {
+	cases := []struct {
+		query build.Query
+		want  string
+	}{
+		{query: build.Field("").Eq(1), want: `invalid field name ""`},
+		{query: build.Field("a b").Exists(), want: `invalid field name "a b"`},
+		{query: build.Field("2fa").In(1), want: `invalid field name "2fa"`},
+		{query: build.Field("-a").Wildcard("x*"), want: `invalid field name "-a"`},
+		{query: build.Field("\xff").Exists(), want: `invalid field name "\xff"`},
+		{query: build.Field("a").Eq("\xff"), want: `invalid UTF-8 value "\xff"`},
+		{query: build.Field("a").Wildcard("*\xff"), want: `invalid UTF-8 value "*\xff"`},
+		{query: build.Field("a").In(), want: `no values of field "a"`},
+		{query: build.Field("a").Gt("x"), want: `range value of field "a" must be a number or a date, but got x(string)`},
+		{query: build.Field("a").Eq(math.NaN()), want: "unsupported value NaN"},
+		{query: build.Field("a").In(1, []int{2}), want: "unsupported value [2]([]int)"},
+		{query: build.Field("a").Nested(build.Query{}), want: `empty nested field query of field "a"`},
+		{query: build.Field("a").Nested(build.Value(nil)), want: "unsupported value <nil>(<nil>)"},
+		{query: build.Field("a").Eq(1).And(build.Value(struct{}{})).Or(build.Value("x")), want: "unsupported value {}(struct {})"},
+		{query: build.Not(build.Field("b").Lt(true)), want: `range value of field "b" must be a number or a date, but got true(bool)`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.want, func(t *testing.T) {
+			expr, err := c.query.Build()
+			assert.Nil(t, expr)
+			assert.EqualError(t, err, c.want)
+			assert.Equal(t, err, c.query.Err())
+			assert.Empty(t, c.query.String())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+//go:build go1.18
+// +build go1.18
+
+package build_test
+
+import (
+	"testing"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/build"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzField(f *testing.F) {
+	seeds := []struct{ field, value string }{
+		{"status", "active"},
+		{"a:b", `say "hi"`},
+		{"and", `\`},
+		{"x*", "a*b"},
+		{"日志", "错误 (x)"},
+		{`{"}(<)>\`, "NOT"},
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed.field, seed.value)
+	}
+
+	f.Fuzz(func(t *testing.T, field, value string) {
+		for _, q := range []build.Query{
+			build.Field(field).Eq(value),
+			build.Field(field).Wildcard(value),
+			build.Field(field).Exists().Or(build.Value(value)),
+		} {
+			expr, err := q.Build()
+			if err != nil {
+				continue
+			}
+
+			parsed, err := parser.New(q.String()).Stmt()
+			require.NoError(t, err, q.String())
+			assert.True(t, ast.Equal(expr, parsed, ast.EqualOptions{IgnorePositions: true}), q.String())
+		}
+	})
+}
@@ -0,0 +1,276 @@
+// Package cst provides a lossless concrete syntax tree of a KQL(kibana query language) query,
+// which re-emits the query as it was typed(whitespace, keyword spelling and quote style) after AST-level edits.
+package cst
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Tree is a lossless concrete syntax tree: the expression(AST) of a query together with its source text.
+//
+// The expression can be edited in place or with Apply, String then re-emits the source text
+// with only the spans of the edited expressions changed.
+type Tree struct {
+	// Expr is the root of the expression, it may be replaced.
+	Expr ast.Expr
+
+	source []rune
+	tokens []parser.Token
+	root   span
+	nodes  map[ast.Expr]node
+}
+
+// span is a range of rune positions in the source.
+type span struct {
+	pos, end int
+}
+
+// node is the state of an expression when it was parsed.
+type node struct {
+	span
+	children []span
+	attrs    string
+}
+
+// Parse parses the source into a tree, the options are passed to the parser.
+//
+// In recovery mode(see parser.WithRecovery) the tree is returned together with the errors,
+// the source of an *ast.BadExpr is kept as it is.
+func Parse(source string, opts ...parser.Option) (*Tree, error) {
+	expr, err := parser.New(source, opts...).Stmt()
+	if expr == nil {
+		return nil, err
+	}
+
+	// the tokens always cover the whole source, no matter whether there are errors
+	tokens, _ := parser.Tokenize(source, parser.WithTrivia(true), parser.WithRecovery(true))
+
+	t := &Tree{
+		Expr:   expr,
+		source: []rune(source),
+		tokens: tokens,
+		root:   span{expr.Pos(), expr.End()},
+		nodes:  make(map[ast.Expr]node),
+	}
+
+	ast.Inspect(expr, func(e ast.Expr) bool {
+		if e == nil {
+			return false
+		}
+
+		n := node{span: span{e.Pos(), e.End()}, attrs: attrs(e)}
+		for _, child := range children(e) {
+			n.children = append(n.children, span{child.Pos(), child.End()})
+		}
+
+		t.nodes[e] = n
+
+		return true
+	})
+
+	return t, err
+}
+
+// Tokens returns the tokens of the source including the whitespace trivia(token.TokenKindWhitespace),
+// which cover the whole source. The span of a string token includes its double quotes, see parser.Token.
+func (t *Tree) Tokens() []parser.Token {
+	return t.tokens
+}
+
+// Text returns the source text of expr as it was typed, it is empty if expr is not parsed from the source.
+func (t *Tree) Text(expr ast.Expr) string {
+	n, ok := t.nodes[expr]
+	if !ok {
+		return ""
+	}
+
+	return string(t.source[n.pos:n.end])
+}
+
+// Apply edits the expression with ast.Apply and returns the new root.
+func (t *Tree) Apply(pre, post ast.ApplyFunc) ast.Expr {
+	t.Expr = ast.Apply(t.Expr, pre, post)
+
+	return t.Expr
+}
+
+// String re-emits the query. The expressions that are not edited keep their source text, an edited expression
+// keeps the text between its children(e.g. the spaces around `anD`) if only its children are edited,
+// and a new expression or an expression with edited attributes(e.g. the keyword) is printed like its String method.
+func (t *Tree) String() string {
+	if t.Expr == nil {
+		return ""
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(string(t.source[:t.root.pos]))
+	t.print(&buf, t.Expr)
+	buf.WriteString(string(t.source[t.root.end:]))
+
+	return buf.String()
+}
+
+// print writes expr, which keeps its source text if it is not edited.
+func (t *Tree) print(buf *strings.Builder, expr ast.Expr) {
+	n, ok := t.nodes[expr]
+
+	list := children(expr)
+	if !ok || n.attrs != attrs(expr) || len(list) != len(n.children) {
+		t.printFresh(buf, expr)
+
+		return
+	}
+
+	combine, _ := expr.(*ast.CombineExpr)
+
+	last := n.pos
+	for i, child := range list {
+		buf.WriteString(string(t.source[last:n.children[i].pos]))
+
+		if combine != nil {
+			t.printOperand(buf, child, combine.Keyword, i == 1, n.children[i])
+		} else {
+			t.print(buf, child)
+		}
+
+		last = n.children[i].end
+	}
+
+	buf.WriteString(string(t.source[last:n.end]))
+}
+
+// printFresh writes expr like its String method, the children are written with print.
+func (t *Tree) printFresh(buf *strings.Builder, expr ast.Expr) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		if e.HasNot {
+			buf.WriteString("NOT ")
+		}
+
+		if e.Field != nil {
+			t.print(buf, e.Field)
+
+			if e.Operator != token.TokenKindOperatorEql {
+				buf.WriteByte(' ')
+			}
+
+			buf.WriteString(e.Operator.String())
+			buf.WriteByte(' ')
+		}
+
+		if e.Value != nil {
+			t.print(buf, e.Value)
+		}
+	case *ast.CombineExpr:
+		if e.LeftExpr != nil {
+			t.printOperand(buf, e.LeftExpr, e.Keyword, false, span{-1, -1})
+		}
+
+		if e.RightExpr != nil {
+			buf.WriteString(" " + e.Keyword.String() + " ")
+			t.printOperand(buf, e.RightExpr, e.Keyword, true, span{-1, -1})
+		}
+	case *ast.ParenExpr:
+		buf.WriteByte('(')
+		t.print(buf, e.Expr)
+		buf.WriteByte(')')
+	case *ast.NestedExpr:
+		if e.HasNot {
+			buf.WriteString("NOT ")
+		}
+
+		t.print(buf, e.Path)
+		buf.WriteString(": { ")
+		t.print(buf, e.Expr)
+		buf.WriteString(" }")
+	case *ast.ValueListExpr:
+		if e.HasNot {
+			buf.WriteString("NOT ")
+		}
+
+		t.print(buf, e.Field)
+
+		if e.Operator != token.TokenKindOperatorEql {
+			buf.WriteByte(' ')
+		}
+
+		buf.WriteString(e.Operator.String() + " (")
+		t.print(buf, e.Values)
+		buf.WriteByte(')')
+	case *ast.ExistsExpr:
+		if e.HasNot {
+			buf.WriteString("NOT ")
+		}
+
+		t.print(buf, e.Field)
+		buf.WriteString(": *")
+	default:
+		buf.WriteString(expr.String())
+	}
+}
+
+// printOperand writes an operand of a combination expression of keyword, at is the source span of the operand.
+// A combination expression that is not parsed at the place is wrapped with parentheses
+// if it binds looser than keyword.
+func (t *Tree) printOperand(buf *strings.Builder, operand ast.Expr, keyword token.Kind, right bool, at span) {
+	combine, ok := operand.(*ast.CombineExpr)
+	if n, parsed := t.nodes[operand]; !ok || parsed && n.span == at {
+		t.print(buf, operand)
+
+		return
+	}
+
+	precedence, parent := ast.KeywordPrecedence(combine.Keyword), ast.KeywordPrecedence(keyword)
+	if precedence > parent || (precedence == parent && !right) {
+		t.print(buf, combine)
+
+		return
+	}
+
+	buf.WriteByte('(')
+	t.print(buf, combine)
+	buf.WriteByte(')')
+}
+
+// children returns the children of expr in source order.
+func children(expr ast.Expr) []ast.Expr {
+	var list []ast.Expr
+
+	ast.Inspect(expr, func(e ast.Expr) bool {
+		if e == nil || e == expr {
+			return e == expr
+		}
+
+		list = append(list, e)
+
+		return false
+	})
+
+	return list
+}
+
+// attrs returns the attributes of expr besides its children, e.g. the keyword of a combination expression.
+func attrs(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		return fmt.Sprintf("%T %s %t %t", e, e.Operator, e.HasNot, e.Field == nil)
+	case *ast.CombineExpr:
+		return fmt.Sprintf("%T %s", e, e.Keyword)
+	case *ast.NestedExpr:
+		return fmt.Sprintf("%T %t", e, e.HasNot)
+	case *ast.ValueListExpr:
+		return fmt.Sprintf("%T %s %t", e, e.Operator, e.HasNot)
+	case *ast.ExistsExpr:
+		return fmt.Sprintf("%T %t", e, e.HasNot)
+	case *ast.ParenExpr:
+		return fmt.Sprintf("%T", e)
+	}
+
+	return fmt.Sprintf("%T %s", expr, expr) // the leaves
+}
@@ -0,0 +1,209 @@
+package cst_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go"
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/cst"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// replaceValue returns an edit that replaces the value literals of field with value.
+func replaceValue(field, value string) ast.ApplyFunc {
+	return func(c *ast.Cursor) bool {
+		if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == field {
+			e.Value = ast.NewLiteral(0, 0, token.TokenKindString, value, nil)
+		}
+
+		return true
+	}
+}
+
+func TestTree_String(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		edit  func(tree *cst.Tree)
+		want  string
+	}{
+		{
+			name:  "no edits",
+			input: "  status:\"active\"   anD (  level :error or NOT items : {  a:1 }) \n",
+			edit:  func(tree *cst.Tree) {},
+			want:  "  status:\"active\"   anD (  level :error or NOT items : {  a:1 }) \n",
+		},
+		{
+			name:  "edit a value",
+			input: "status:\"active\"   anD (  level :error or msg: 'x' )",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(replaceValue("level", "warn"), nil)
+			},
+			want: "status:\"active\"   anD (  level :\"warn\" or msg: 'x' )",
+		},
+		{
+			name:  "edit the attribute of a clause",
+			input: "a:1   and   b :  2",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(func(c *ast.Cursor) bool {
+					if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == "b" {
+						e.HasNot = true
+					}
+
+					return true
+				}, nil)
+			},
+			want: "a:1   and   NOT b: 2",
+		},
+		{
+			name:  "edit a value of a value list",
+			input: "level :(  error   OR warn )  and b:1",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(func(c *ast.Cursor) bool {
+					if e, ok := c.Expr().(*ast.Literal); ok && e.Value == "warn" {
+						c.Replace(ast.NewLiteral(0, 0, token.TokenKindIdent, "info", nil))
+					}
+
+					return true
+				}, nil)
+			},
+			want: "level :(  error   OR info )  and b:1",
+		},
+		{
+			name:  "edit the attribute of a value list",
+			input: "level :(  error   OR warn )  and b:1",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(func(c *ast.Cursor) bool {
+					if e, ok := c.Expr().(*ast.ValueListExpr); ok {
+						e.HasNot = true
+					}
+
+					return true
+				}, nil)
+			},
+			want: "NOT level: (error   OR warn)  and b:1",
+		},
+		{
+			name:  "delete a clause",
+			input: "a:1   and   b :  2 or  c:3",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(func(c *ast.Cursor) bool {
+					if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == "b" {
+						c.Delete()
+					}
+
+					return true
+				}, nil)
+			},
+			want: "a:1 or  c:3",
+		},
+		{
+			name:  "insert a looser clause",
+			input: "a:1   and   b :  2",
+			edit: func(tree *cst.Tree) {
+				tree.Apply(func(c *ast.Cursor) bool {
+					if e, ok := c.Expr().(*ast.BinaryExpr); ok && e.FieldName() == "b" {
+						c.Replace(ast.NewCombineExpr(e, token.TokenKindKeywordOr, kql.MustParse("c: 3")))
+
+						return false
+					}
+
+					return true
+				}, nil)
+			},
+			want: "a:1   and   (b :  2 OR c: 3)",
+		},
+		{
+			name:  "replace the root",
+			input: " a:1   and   b :  2 ",
+			edit: func(tree *cst.Tree) {
+				tree.Expr = ast.NewParenExpr(0, 0, tree.Expr)
+			},
+			want: " (a:1   and   b :  2) ",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tree, err := cst.Parse(c.input)
+			require.NoError(t, err)
+
+			c.edit(tree)
+			assert.Equal(t, c.want, tree.String())
+		})
+	}
+}
+
+func TestTree_Lossless(t *testing.T) {
+	inputs := []string{
+		`a\:b: "x \"y\""`,
+		"日志 :  \"错误\"\r\n AND  🔥",
+		`NOT  f: *`,
+		`f*: a*b`,
+		`"just value"`,
+		`a: (1   OR 2)`,
+		`NOT a :( NOT (1 and  2) )`,
+		`items:{a:1}`,
+		`NOT items : { NOT a: 1 }`,
+		`a >= -1.5 and b<2`,
+		`\and: \or`,
+		`  ( ( a ) ) `,
+		`a NOT b`,
+		`a: AND )  b c`,
+		`(a: "x`,
+		`items: { a: 1`,
+	}
+
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			tree, err := cst.Parse(input, parser.WithRecovery(true))
+			require.NotNil(t, tree, err)
+			assert.Equal(t, input, tree.String())
+		})
+	}
+}
+
+func TestTree_Text(t *testing.T) {
+	tree, err := cst.Parse(`status:"active"   anD NOT level: error`)
+	require.NoError(t, err)
+
+	combine, ok := tree.Expr.(*ast.CombineExpr)
+	require.True(t, ok)
+	assert.Equal(t, `status:"active"   anD NOT level: error`, tree.Text(combine))
+	assert.Equal(t, `status:"active"`, tree.Text(combine.LeftExpr))
+	assert.Equal(t, "NOT level: error", tree.Text(combine.RightExpr))
+	assert.Equal(t, "", tree.Text(ast.NewLiteral(0, 0, token.TokenKindIdent, "x", nil)))
+}
+
+func TestTree_Tokens(t *testing.T) {
+	input := " a: \"x\"\tanD b "
+
+	tree, err := cst.Parse(input)
+	require.NoError(t, err)
+
+	var buf strings.Builder
+
+	for _, tok := range tree.Tokens() {
+		buf.WriteString(input[tok.Offset:tok.EndOffset])
+	}
+
+	assert.Equal(t, input, buf.String())
+}
+
+func TestParse_Recovery(t *testing.T) {
+	tree, err := cst.Parse("a: AND  b:1", parser.WithRecovery(true))
+	require.Error(t, err)
+	require.NotNil(t, tree)
+
+	tree.Apply(replaceValue("b", "2"), nil)
+	assert.Equal(t, `a: AND  b:"2"`, tree.String())
+
+	tree, err = cst.Parse("a: AND b")
+	assert.Error(t, err)
+	assert.Nil(t, tree)
+}
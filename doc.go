@@ -14,12 +14,19 @@ Basic Usage:
 	    log.Fatal(err)
 	}
 
+Options such as WithMaxLength, WithMaxDepth, WithPrecedence and WithStrict can be passed to Parse:
+
+	expr, err := kql.Parse(query, kql.WithMaxLength(1024), kql.WithMaxDepth(8))
+
+MustParse is like Parse but panics if the query can not be parsed.
+
 Features:
   - Escaped character handling
   - Wildcard patterns
   - Parentheses grouping
   - AND/OR/NOT operators
   - Field:value pairs
+  - Nested field queries
   - String literals with quotes
 
 Thread Safety:
@@ -29,6 +36,7 @@ making it safe to use across multiple goroutines.
 Error Handling:
 The parser provides detailed error messages with position information,
 making it easy to identify and fix syntax errors in queries.
+With WithRecovery, Parse reports all the errors of a query as an ErrorList in one pass.
 
 For more information about KQL syntax, visit:
 https://www.elastic.co/guide/en/kibana/current/kuery-query.html
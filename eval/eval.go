@@ -0,0 +1,632 @@
+// Package eval matches documents against a KQL(kibana query language) expression(AST) in memory.
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Matcher reports whether a document matches a compiled KQL(kibana query language) expression.
+//
+// A document is a tree of map[string]interface{}, []interface{} and scalar values,
+// e.g. the result of json.Unmarshal into map[string]interface{}.
+type Matcher interface {
+	Match(doc map[string]interface{}) bool
+}
+
+// MatcherFunc is a function that implements Matcher.
+type MatcherFunc func(doc map[string]interface{}) bool
+
+// Match calls f(doc).
+func (f MatcherFunc) Match(doc map[string]interface{}) bool {
+	return f(doc)
+}
+
+// Compile compiles a KQL(kibana query language) expression(AST) into a Matcher,
+// the Matcher is safe for concurrent use.
+//
+//   - a field is a dotted path(e.g. `a.b.c`) into nested objects, a key that contains dots is matched as well
+//   - a field that holds an array matches if any element matches
+//   - `field: value` compares the whole value, numbers are compared numerically
+//   - `field: va*ue` matches the string value with the wildcard pattern and `field: *` matches an existing field
+//   - `field > value`, `field >= value`, `field < value` and `field <= value` compare numbers,
+//     or times(a time.Time, a date string or milliseconds since the Unix epoch) with a date or a date math value
+//     (e.g. `now-7d/d`), which is resolved with the resolver of WithDateResolver. A value relative to `now`
+//     is resolved with the clock of the resolver whenever it is compared, so a long-lived Matcher does not
+//     keep the time when it is compiled. The other values are compared in parser.RangeModeKibana
+//     (see WithRangeMode) as IPs(e.g. `10.0.0.0`), versions(e.g. `"1.2.3"`) or, otherwise, strings
+//   - a value without field matches if any field of the document matches
+//   - a wildcard field(e.g. `machine.os*`) matches if any field whose dotted path matches the pattern matches
+//   - `path: { ... }` matches if any object at path matches the inner expression
+func Compile(expr ast.Expr, opts ...Option) (Matcher, error) {
+	c := &compiler{}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	m, err := c.compile(expr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return MatcherFunc(m), nil
+}
+
+// Option configures the compilation.
+type Option func(*options)
+
+type options struct {
+	dateResolver ast.DateResolver
+	rangeMode    parser.RangeMode
+}
+
+// WithDateResolver resolves the dates and date math values(e.g. `now-7d/d`) of the range operators with r,
+// which sets the clock of `now` and the time zone. The default is the zero ast.DateResolver, the current time in UTC.
+func WithDateResolver(r ast.DateResolver) Option {
+	return func(o *options) {
+		o.dateResolver = r
+	}
+}
+
+// WithRangeMode compares the range values like the parser of mode(see parser.WithRangeMode), which should be
+// the mode the expression is parsed in. The default is parser.RangeModeNumeric, in which a range value that is
+// neither a number nor a date is an error.
+func WithRangeMode(mode parser.RangeMode) Option {
+	return func(o *options) {
+		o.rangeMode = mode
+	}
+}
+
+type compiler struct {
+	opts options
+}
+
+type matcher func(doc map[string]interface{}) bool
+
+// valueMatcher reports whether a single(non-array) value of a field matches.
+type valueMatcher func(v interface{}) bool
+
+// compile compiles expr, field is the field of the value list(e.g. `f: (v1 OR v2)`) expr belongs to.
+func (c *compiler) compile(expr ast.Expr, field ast.Expr) (matcher, error) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		return c.compileBinary(e, field)
+	case *ast.CombineExpr:
+		return c.compileCombine(e, field)
+	case *ast.ParenExpr:
+		return c.compile(e.Expr, field)
+	case *ast.NestedExpr:
+		return c.compileNested(e)
+	case *ast.ValueListExpr:
+		return c.compileValueList(e)
+	case *ast.ExistsExpr:
+		return compileExists(e), nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T: %s", expr, expr)
+}
+
+func (c *compiler) compileValueList(e *ast.ValueListExpr) (matcher, error) {
+	m, err := c.compile(e.Values, e.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.HasNot {
+		return not(m), nil
+	}
+
+	return m, nil
+}
+
+func (c *compiler) compileBinary(e *ast.BinaryExpr, field ast.Expr) (matcher, error) {
+	m, err := c.compileClause(e, field)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.HasNot {
+		return not(m), nil
+	}
+
+	return m, nil
+}
+
+func (c *compiler) compileClause(e *ast.BinaryExpr, field ast.Expr) (matcher, error) {
+	op := e.Operator
+	if e.Field != nil {
+		field = e.Field
+	} else {
+		op = token.TokenKindOperatorEql
+	}
+
+	if paren, ok := e.Value.(*ast.ParenExpr); ok {
+		return c.compile(paren.Expr, field)
+	}
+
+	vm, err := c.compileValue(op, e.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return fieldMatcher(field, vm), nil
+}
+
+// fieldMatcher returns a matcher that reports whether any value of field matches vm.
+func fieldMatcher(field ast.Expr, vm valueMatcher) matcher {
+	switch f := field.(type) {
+	case nil:
+		return func(doc map[string]interface{}) bool {
+			return anyLeaf(doc, "", func(_ string, v interface{}) bool {
+				return vm(v)
+			})
+		}
+	case *ast.WildcardExpr:
+		return func(doc map[string]interface{}) bool {
+			return anyLeaf(doc, "", func(path string, v interface{}) bool {
+				return f.Match(path) && vm(v)
+			})
+		}
+	}
+
+	path := strings.Split(fieldPath(field), ".")
+
+	return func(doc map[string]interface{}) bool {
+		return lookup(doc, path, vm)
+	}
+}
+
+func (c *compiler) compileValue(op token.Kind, value ast.Expr) (valueMatcher, error) {
+	if op != token.TokenKindOperatorEql {
+		return c.compileRange(op, value)
+	}
+
+	switch v := value.(type) {
+	case *ast.WildcardExpr:
+		return func(value interface{}) bool {
+			s, ok := toString(value)
+
+			return ok && v.Match(s)
+		}, nil
+	case *ast.Literal:
+		want, err := v.Float64()
+		isNumber := err == nil && (v.Kind == token.TokenKindInt || v.Kind == token.TokenKindFloat)
+
+		return func(value interface{}) bool {
+			if isNumber {
+				if got, ok := toFloat(value); ok {
+					return got == want
+				}
+			}
+
+			s, ok := toString(value)
+
+			return ok && s == v.Value
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported value %T: %s", value, value)
+}
+
+func (c *compiler) compileRange(op token.Kind, value ast.Expr) (valueMatcher, error) {
+	if date, ok := value.(*ast.DateExpr); ok {
+		return c.compileDateRange(op, date)
+	}
+
+	lit, ok := value.(*ast.Literal)
+	if !ok {
+		return nil, fmt.Errorf("unsupported range value %T: %s", value, value)
+	}
+
+	if _, err := lit.Float64(); err != nil && c.opts.rangeMode != parser.RangeModeKibana {
+		return nil, fmt.Errorf("expected number, but got %q", lit.Value)
+	}
+
+	cmp, err := ordered(op)
+	if err != nil {
+		return nil, err
+	}
+
+	compare := comparator(lit)
+
+	return func(value interface{}) bool {
+		result, ok := compare(value)
+
+		return ok && cmp(result)
+	}, nil
+}
+
+// comparator returns the function that compares a value with lit, which returns -1, 0 or +1 like strings.Compare
+// and false if the value can not be compared with lit. The value is compared as a number, an IP or a version
+// (e.g. `1.2.10`) if lit is one of them, and as a string otherwise.
+func comparator(lit *ast.Literal) func(v interface{}) (int, bool) {
+	if want, err := lit.Float64(); err == nil {
+		return func(v interface{}) (int, bool) {
+			got, ok := toFloat(v)
+
+			return compareFloat(got, want), ok
+		}
+	}
+
+	if want, err := lit.IP(); err == nil {
+		return func(v interface{}) (int, bool) {
+			got, ok := toIP(v)
+
+			return bytes.Compare(got.To16(), want.To16()), ok
+		}
+	}
+
+	if want, ok := parseVersion(lit.Value); ok {
+		return func(v interface{}) (int, bool) {
+			s, ok := v.(string)
+			if !ok {
+				return 0, false
+			}
+
+			got, ok := parseVersion(s)
+
+			return compareVersion(got, want), ok
+		}
+	}
+
+	return func(v interface{}) (int, bool) {
+		got, ok := toString(v)
+
+		return strings.Compare(got, lit.Value), ok
+	}
+}
+
+// compileDateRange compares the times(see toTime) with a date or a date math value, which is resolved when
+// compiled, or whenever it is compared if it is relative to `now`.
+func (c *compiler) compileDateRange(op token.Kind, e *ast.DateExpr) (valueMatcher, error) {
+	r := c.opts.dateResolver
+
+	want, err := r.Resolve(e, op)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp, err := ordered(op)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value interface{}) bool {
+		got, ok := toTime(value)
+		if !ok {
+			return false
+		}
+
+		if e.Anchor != ast.DateNow {
+			return cmp(compareTime(got, want))
+		}
+
+		now, _ := r.Resolve(e, op) // the same expression is resolved without error when compiled
+
+		return cmp(compareTime(got, now))
+	}, nil
+}
+
+// ordered returns the function that reports whether the result of a comparison(-1, 0 or +1) satisfies op.
+func ordered(op token.Kind) (func(c int) bool, error) {
+	switch op {
+	case token.TokenKindOperatorLss:
+		return func(c int) bool { return c < 0 }, nil
+	case token.TokenKindOperatorLeq:
+		return func(c int) bool { return c <= 0 }, nil
+	case token.TokenKindOperatorGtr:
+		return func(c int) bool { return c > 0 }, nil
+	case token.TokenKindOperatorGeq:
+		return func(c int) bool { return c >= 0 }, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+
+	return 0
+}
+
+// parseVersion parses s as a version of two or more dot separated numbers, e.g. 1.2.3.
+func parseVersion(s string) ([]uint64, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	version := make([]uint64, len(parts))
+
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		version[i] = n
+	}
+
+	return version, true
+}
+
+// compareVersion compares the versions part by part, a missing part is 0, e.g. 1.2 equals 1.2.0.
+func compareVersion(a, b []uint64) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		if i < len(a) {
+			x = a[i]
+		}
+
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			return compareFloat(float64(x), float64(y))
+		}
+	}
+
+	return 0
+}
+
+func (c *compiler) compileCombine(e *ast.CombineExpr, field ast.Expr) (matcher, error) {
+	left, err := c.compile(e.LeftExpr, field)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := c.compile(e.RightExpr, field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Keyword {
+	case token.TokenKindKeywordOr:
+		return func(doc map[string]interface{}) bool {
+			return left(doc) || right(doc)
+		}, nil
+	case token.TokenKindKeywordNot: // `a NOT b` means `a AND NOT b`
+		return func(doc map[string]interface{}) bool {
+			return left(doc) && !right(doc)
+		}, nil
+	default:
+		return func(doc map[string]interface{}) bool {
+			return left(doc) && right(doc)
+		}, nil
+	}
+}
+
+func compileExists(e *ast.ExistsExpr) matcher {
+	m := fieldMatcher(e.Field, func(v interface{}) bool {
+		return v != nil
+	})
+
+	if e.HasNot {
+		return not(m)
+	}
+
+	return m
+}
+
+func (c *compiler) compileNested(e *ast.NestedExpr) (matcher, error) {
+	inner, err := c.compile(e.Expr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.Split(e.PathName(), ".")
+
+	m := func(doc map[string]interface{}) bool {
+		found := false
+
+		lookup(doc, path, func(v interface{}) bool {
+			obj, ok := v.(map[string]interface{})
+			found = ok && inner(obj)
+
+			return found
+		})
+
+		return found
+	}
+
+	if e.HasNot {
+		return not(m), nil
+	}
+
+	return m, nil
+}
+
+func not(m matcher) matcher {
+	return func(doc map[string]interface{}) bool {
+		return !m(doc)
+	}
+}
+
+// lookup calls f with every value at path in v until f returns true, arrays are expanded into their elements.
+//
+// The keys of an object may contain dots, e.g. both {"a": {"b": 1}} and {"a.b": 1} have the value 1 at `a.b`.
+func lookup(v interface{}, path []string, f func(v interface{}) bool) bool {
+	switch value := v.(type) {
+	case []interface{}:
+		for _, elem := range value {
+			if lookup(elem, path, f) {
+				return true
+			}
+		}
+
+		return false
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return f(value)
+		}
+
+		for i := len(path); i > 0; i-- {
+			child, ok := value[strings.Join(path[:i], ".")]
+			if ok && lookup(child, path[i:], f) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return len(path) == 0 && f(v)
+}
+
+// anyLeaf reports whether any scalar value in v matches, path is the dotted path of v.
+func anyLeaf(v interface{}, path string, f func(path string, v interface{}) bool) bool {
+	switch value := v.(type) {
+	case []interface{}:
+		for _, elem := range value {
+			if anyLeaf(elem, path, f) {
+				return true
+			}
+		}
+
+		return false
+	case map[string]interface{}:
+		for key, child := range value {
+			if path != "" {
+				key = path + "." + key
+			}
+
+			if anyLeaf(child, key, f) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return f(path, v)
+}
+
+// toFloat converts a number or a numeric string into float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+
+		return f, err == nil
+	case string:
+		if !token.IsNumber(n) {
+			return 0, false
+		}
+
+		f, err := strconv.ParseFloat(n, 64)
+
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// toTime converts a time.Time, a date string(see ast.Literal.Time) or a number of milliseconds
+// since the Unix epoch into time.Time.
+func toTime(v interface{}) (time.Time, bool) {
+	if s, ok := v.(string); ok {
+		t, err := ast.NewLiteral(0, 0, token.TokenKindString, s, nil).Time()
+
+		return t, err == nil
+	}
+
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+
+	f, ok := toFloat(v)
+	ms := int64(f)
+
+	return time.Unix(ms/1e3, ms%1e3*1e6).UTC(), ok
+}
+
+// toIP converts a net.IP or an IP string into net.IP.
+func toIP(v interface{}) (net.IP, bool) {
+	switch ip := v.(type) {
+	case net.IP:
+		return ip, ip != nil
+	case string:
+		parsed := net.ParseIP(ip)
+
+		return parsed, parsed != nil
+	}
+
+	return nil, false
+}
+
+// toString converts a scalar value into its string representation.
+func toString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return "", false
+	case string:
+		return s, true
+	case bool:
+		return strconv.FormatBool(s), true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case json.Number:
+		return s.String(), true
+	}
+
+	return fmt.Sprint(v), true
+}
+
+// fieldPath returns the unescaped name of a field.
+func fieldPath(field ast.Expr) string {
+	if lit, ok := field.(*ast.Literal); ok {
+		return lit.Value
+	}
+
+	return field.String()
+}
@@ -0,0 +1,225 @@
+package eval_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/eval"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+const document = `{
+	"level": "error",
+	"status": 503,
+	"latency": 1.5,
+	"success": false,
+	"message": "connection refused",
+	"service": {"name": "api", "version": "1.2.0"},
+	"kubernetes.pod": "api-7d9f",
+	"tags": ["prod", "eu-west"],
+	"items": [
+		{"name": "apple", "qty": 1},
+		{"name": "banana", "qty": 5}
+	],
+	"empty": null,
+	"@timestamp": "2024-03-14T15:09:26Z",
+	"created": 1704067200000,
+	"host": {"ip": "10.0.0.12", "ipv6": "fe80::1"}
+}`
+
+func TestCompile(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(document), &doc))
+
+	cases := []struct {
+		query string
+		want  bool
+	}{
+		{query: `level: error`, want: true},
+		{query: `level: "error"`, want: true},
+		{query: `level: warn`, want: false},
+		{query: `status: 503`, want: true},
+		{query: `status: 503.0`, want: true},
+		{query: `latency: 1.5`, want: true},
+		{query: `success: false`, want: true},
+		{query: `service.name: api`, want: true},
+		{query: `kubernetes.pod: api*`, want: true},
+		{query: `tags: eu-west`, want: true},
+		{query: `tags: us*`, want: false},
+		{query: `items.name: banana`, want: true},
+		{query: `message: *refused`, want: true},
+		{query: `message: "connection"`, want: false},
+		{query: `status >= 500 AND status < 600`, want: true},
+		{query: `latency > 2`, want: false},
+		{query: `items.qty > 4`, want: true},
+		{query: `service: *`, want: true},
+		{query: `empty: *`, want: false},
+		{query: `missing: *`, want: false},
+		{query: `NOT missing: *`, want: true},
+		{query: `"connection refused"`, want: true},
+		{query: `apple`, want: true},
+		{query: `cherry`, want: false},
+		{query: `level: (warn OR error)`, want: true},
+		{query: `level: (warn OR info)`, want: false},
+		{query: `NOT level: (warn OR info)`, want: true},
+		{query: `level: (NOT warn AND NOT (info OR debug))`, want: true},
+		{query: `level: warn OR status: 503 AND success: false`, want: true},
+		{query: `level: error NOT status: 503`, want: false},
+		{query: `NOT (level: error AND status: 503)`, want: false},
+		{query: `items: { name: apple AND qty: 1 }`, want: true},
+		{query: `items: { name: apple AND qty: 5 }`, want: false},
+		{query: `NOT items: { name: cherry }`, want: true},
+		{query: `service.*: api`, want: true},
+		{query: `service.*: "1.2.0"`, want: true},
+		{query: `service.*: error`, want: false},
+		{query: `kube*: api*`, want: true},
+		{query: `items.*: banana`, want: true},
+		{query: `service.v*: *`, want: true},
+		{query: `NOT e*: *`, want: true},
+		{query: `@timestamp >= "2024-03-14"`, want: true},
+		{query: `@timestamp > "2024-03-14||/d"`, want: false},
+		{query: `@timestamp <= "2024-03-14||/d"`, want: true},
+		{query: `@timestamp < now`, want: true},
+		{query: `created >= "2024-01-01" AND created < "2024-01-01||+1d"`, want: true},
+		{query: `level > now-1d`, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			stmt, err := parser.New(c.query).Stmt()
+			require.NoError(t, err)
+
+			m, err := eval.Compile(stmt)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, m.Match(doc))
+		})
+	}
+}
+
+func TestCompile_RangeModeKibana(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(document), &doc))
+
+	cases := []struct {
+		query string
+		want  bool
+	}{
+		{query: `status >= 500 AND status < 600`, want: true},
+		{query: `@timestamp >= "2024-03-14"`, want: true},
+		{query: `host.ip >= 10.0.0.0 AND host.ip <= 10.0.0.255`, want: true},
+		{query: `host.ip > 10.0.0.2`, want: true},
+		{query: `host.ip < "9.255.255.255"`, want: false},
+		{query: `host.ipv6 > "fe80::"`, want: true},
+		{query: `host.ipv6 > 10.0.0.0`, want: true},
+		{query: `service.version >= "1.1.9" AND service.version < 1.10.0`, want: true},
+		{query: `service.version > "1.2.0"`, want: false},
+		{query: `level > debug AND level < fatal`, want: true},
+		{query: `level >= warn`, want: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			stmt, err := parser.New(c.query, parser.WithRangeMode(parser.RangeModeKibana)).Stmt()
+			require.NoError(t, err)
+
+			m, err := eval.Compile(stmt, eval.WithRangeMode(parser.RangeModeKibana))
+			require.NoError(t, err)
+			assert.Equal(t, c.want, m.Match(doc))
+		})
+	}
+}
+
+func TestCompile_DateResolver(t *testing.T) {
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(document), &doc))
+
+	now := time.Date(2024, 3, 14, 15, 20, 0, 0, time.UTC)
+	r := ast.DateResolver{Clock: func() time.Time { return now }}
+
+	stmt, err := parser.New(`@timestamp >= now-15m`).Stmt()
+	require.NoError(t, err)
+
+	m, err := eval.Compile(stmt, eval.WithDateResolver(r))
+	require.NoError(t, err)
+	assert.True(t, m.Match(doc))
+
+	now = now.Add(time.Hour) // `now` is not kept from the compilation
+	assert.False(t, m.Match(doc))
+
+	stmt, err = parser.New(`@timestamp < "2024-03-14T20:00:00"`).Stmt()
+	require.NoError(t, err)
+
+	m, err = eval.Compile(stmt)
+	require.NoError(t, err)
+	assert.True(t, m.Match(doc))
+
+	m, err = eval.Compile(stmt, eval.WithDateResolver(ast.DateResolver{Location: time.FixedZone("", 8*3600)}))
+	require.NoError(t, err)
+	assert.False(t, m.Match(doc), "2024-03-14T20:00:00+08:00 is before the document")
+}
+
+func TestCompile_Error(t *testing.T) {
+	cases := []struct {
+		name string
+		expr ast.Expr
+	}{
+		{
+			name: "wildcard in range",
+			expr: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "age", nil), token.TokenKindOperatorGtr, ast.NewWildcardExpr(
+				ast.NewLiteral(6, 8, token.TokenKindIdent, "1*", nil), []int{1},
+			), false),
+		},
+		{
+			name: "non-numeric range value",
+			expr: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "age", nil), token.TokenKindOperatorGtr, ast.NewLiteral(6, 9, token.TokenKindIdent, "abc", nil), false),
+		},
+		{
+			name: "unsupported range operator",
+			expr: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "age", nil), token.TokenKindKeywordAnd, ast.NewLiteral(6, 7, token.TokenKindInt, "1", nil), false),
+		},
+		{
+			name: "nil expression",
+			expr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := eval.Compile(c.expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMatcher_Concurrent(t *testing.T) {
+	stmt, err := parser.New(`level: error AND items: { qty > 2 }`).Stmt()
+	require.NoError(t, err)
+
+	m, err := eval.Compile(stmt)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			doc := map[string]interface{}{
+				"level": "error",
+				"items": []interface{}{map[string]interface{}{"qty": i}},
+			}
+			assert.Equal(t, i > 2, m.Match(doc))
+		}(i)
+	}
+
+	wg.Wait()
+}
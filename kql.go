@@ -1,85 +1,134 @@
 package kql
 
 import (
-	"errors"
-	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
 	"github.com/laojianzi/kql-go/token"
 )
 
 // Parser is an interface capability that needs to be provided externally
 // when implementing a KQL(kibana query language) parser.
-type Parser interface {
-	// Stmt parses a KQL(kibana query language) expression(AST).
-	Stmt() (ast.Expr, error)
-}
+type Parser = parser.Parser
 
 // Error is an error that occurs when parsing a KQL(kibana query language) expression, which carries the context.
-type Error struct {
-	s              string
-	lastTokenKind  token.Kind
-	lastTokenValue string
-	pos            int
-	err            error
-}
+type Error = parser.Error
 
 // NewError creates a new kql error.
 func NewError(s string, lastTokenKind token.Kind, lastTokenValue string, pos int, err error) error {
-	if err == nil {
-		return nil
-	}
+	return parser.NewError(s, lastTokenKind, lastTokenValue, pos, err)
+}
 
-	e := &Error{}
-	if errors.As(err, &e) {
-		return err
-	}
+// ErrorList is the list of the errors of a query, it is returned by Parse in recovery mode(see WithRecovery).
+type ErrorList = parser.ErrorList
+
+// ErrorCode identifies the kind of a parse error, errors.Is(err, code) reports whether err has the code.
+type ErrorCode = parser.ErrorCode
+
+const (
+	ErrorCodeUnknown              = parser.ErrorCodeUnknown
+	ErrorCodeEmptyQuery           = parser.ErrorCodeEmptyQuery
+	ErrorCodeUnexpectedToken      = parser.ErrorCodeUnexpectedToken
+	ErrorCodeUnclosedString       = parser.ErrorCodeUnclosedString
+	ErrorCodeBadEscape            = parser.ErrorCodeBadEscape
+	ErrorCodeBadNumber            = parser.ErrorCodeBadNumber
+	ErrorCodeUnmatchedParen       = parser.ErrorCodeUnmatchedParen
+	ErrorCodeUnmatchedBrace       = parser.ErrorCodeUnmatchedBrace
+	ErrorCodeNonNumericRangeValue = parser.ErrorCodeNonNumericRangeValue
+	ErrorCodeWildcardNestedPath   = parser.ErrorCodeWildcardNestedPath
+	ErrorCodeTooLong              = parser.ErrorCodeTooLong
+	ErrorCodeTooDeep              = parser.ErrorCodeTooDeep
+	ErrorCodeStrict               = parser.ErrorCodeStrict
+	ErrorCodeBadDateMath          = parser.ErrorCodeBadDateMath
+)
+
+// Option configures Parse and MustParse.
+type Option = parser.Option
 
-	return &Error{s, lastTokenKind, lastTokenValue, pos, err}
+// Precedence decides how the AND/OR keywords of a query are grouped.
+type Precedence = parser.Precedence
+
+const (
+	// PrecedenceKibana binds NOT tighter than AND and AND tighter than OR, which is how Kibana evaluates KQL.
+	PrecedenceKibana = parser.PrecedenceKibana
+	// PrecedenceLeftToRight combines keywords strictly from left to right, which is the legacy behavior.
+	PrecedenceLeftToRight = parser.PrecedenceLeftToRight
+)
+
+// WithPrecedence sets how the AND/OR keywords are grouped, the default is PrecedenceKibana.
+func WithPrecedence(precedence Precedence) Option {
+	return parser.WithPrecedence(precedence)
 }
 
-// Error returns the error message.
-func (e *Error) Error() string {
-	var (
-		lineNo, column int
-		buf            strings.Builder
-	)
+// WithMaxLength limits the length(in characters) of the query, the default is 0, which means no limit.
+func WithMaxLength(n int) Option {
+	return parser.WithMaxLength(n)
+}
 
-	if e.pos > len(e.s) {
-		return e.err.Error()
-	}
+// WithMaxDepth limits the nesting depth of the parentheses and braces of the query,
+// the default is 0, which means no limit.
+func WithMaxDepth(n int) Option {
+	return parser.WithMaxDepth(n)
+}
 
-	for i := 0; i < e.pos; i++ {
-		if e.s[i] == '\n' {
-			lineNo++
-			column = 0
-		} else {
-			column++
-		}
-	}
+// WithStrict rejects the forms that Kibana accepts leniently(e.g. `a NOT b` and `age > 1*`), the default is false.
+func WithStrict(strict bool) Option {
+	return parser.WithStrict(strict)
+}
 
-	buf.WriteString(fmt.Sprintf("line %d:%d %s\n", lineNo, column, e.err.Error()))
+// WithRecovery makes Parse report all the errors of the query in one pass, it returns the partial expression(AST),
+// in which the source that can not be parsed is an *ast.BadExpr, together with an ErrorList. The default is false.
+func WithRecovery(recovery bool) Option {
+	return parser.WithRecovery(recovery)
+}
 
-	lines := strings.Split(e.s, "\n")
-	for i, line := range lines {
-		if i == lineNo {
-			buf.WriteString(line)
-			buf.WriteByte('\n')
+// RangeMode decides the values that the range operators(>, >=, <, <=) accept.
+type RangeMode = parser.RangeMode
 
-			for j := 0; j < column; j++ {
-				buf.WriteByte(' ')
-			}
+const (
+	// RangeModeNumeric accepts numbers, dates and date math values.
+	RangeModeNumeric = parser.RangeModeNumeric
+	// RangeModeKibana accepts any value like Kibana, e.g. `host.ip > 10.0.0.0`, see schema.Validate.
+	RangeModeKibana = parser.RangeModeKibana
+)
 
-			if e.lastTokenKind > 0 {
-				buf.WriteString(strings.Repeat("^", len(e.lastTokenValue)))
-			} else {
-				buf.WriteString("^")
-			}
+// WithRangeMode sets the values that the range operators accept, the default is RangeModeNumeric.
+func WithRangeMode(mode RangeMode) Option {
+	return parser.WithRangeMode(mode)
+}
+
+// TypeResolver coerces the values of the fields whose types it knows, e.g. a schema.Schema.
+type TypeResolver = parser.TypeResolver
+
+// WithTypeResolver makes Parse attach the values coerced by types to the values of the fields as ast.Literal.Coerced,
+// the default is nil, which means no coercion.
+func WithTypeResolver(types TypeResolver) Option {
+	return parser.WithTypeResolver(types)
+}
+
+// Parse parses a KQL(kibana query language) query into an expression(AST), the error is an *Error.
+//
+// It is a shortcut of parser.New(query, opts...).Stmt() and is safe for concurrent use.
+func Parse(query string, opts ...Option) (ast.Expr, error) {
+	return parser.New(query, opts...).Stmt()
+}
+
+// MustParse is like Parse but panics if the query can not be parsed.
+// It simplifies the initialization of global variables holding queries.
+func MustParse(query string, opts ...Option) ast.Expr {
+	expr, err := Parse(query, opts...)
+	if err != nil {
+		panic(`kql: Parse(` + quote(query) + `): ` + err.Error())
+	}
+
+	return expr
+}
 
-			buf.WriteByte('\n')
-		}
+func quote(s string) string {
+	if strconv.CanBackquote(s) {
+		return "`" + s + "`"
 	}
 
-	return buf.String()
+	return strconv.Quote(s)
 }
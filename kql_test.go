@@ -1,11 +1,13 @@
 package kql_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/laojianzi/kql-go"
 	"github.com/laojianzi/kql-go/token"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestError(t *testing.T) {
@@ -14,3 +16,33 @@ func TestError(t *testing.T) {
 	t.Logf("\n\n%v", err)
 	assert.EqualError(t, err, "line 0:4 expected keyword OR|AND|NOT, but got \"bar\"\nfoo bar\n    ^\n")
 }
+
+func TestParse(t *testing.T) {
+	expr, err := kql.Parse(`a: 1 OR b: 2 AND c: 3`)
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1 OR b: 2 AND c: 3", expr.String())
+
+	expr, err = kql.Parse(`a: 1 OR b: 2 AND c: 3`, kql.WithPrecedence(kql.PrecedenceLeftToRight))
+	require.NoError(t, err)
+	assert.Equal(t, "(a: 1 OR b: 2) AND c: 3", expr.String())
+
+	_, err = kql.Parse(`a NOT b`, kql.WithStrict(true), kql.WithMaxLength(10), kql.WithMaxDepth(1))
+	require.Error(t, err)
+
+	var kqlErr *kql.Error
+	assert.True(t, errors.As(err, &kqlErr))
+
+	expr, err = kql.Parse(`a: AND b c`, kql.WithRecovery(true))
+	assert.Equal(t, "a: AND b c", expr.String())
+
+	var list kql.ErrorList
+	require.True(t, errors.As(err, &list))
+	assert.Len(t, list, 2)
+}
+
+func TestMustParse(t *testing.T) {
+	assert.Equal(t, "a: 1", kql.MustParse("a: 1").String())
+	assert.PanicsWithValue(t, "kql: Parse(`a: 1)`): line 0:4 expected <EOF>, but got \")\"\na: 1)\n    ^\n", func() {
+		kql.MustParse("a: 1)")
+	})
+}
@@ -76,7 +76,7 @@ func BenchmarkLexer(b *testing.B) {
 			b.ReportAllocs()
 
 			for i := 0; i < b.N; i++ {
-				l := newLexer(bq.query)
+				l := newLexer(bq.query, options{})
 
 				for {
 					if l.nextToken(); l.eof() {
@@ -116,7 +116,7 @@ func BenchmarkEscapeSequence(b *testing.B) {
 			b.ReportAllocs()
 
 			for i := 0; i < b.N; i++ {
-				l := newLexer(tt.input)
+				l := newLexer(tt.input, options{})
 
 				for {
 					if l.nextToken(); l.eof() {
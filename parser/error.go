@@ -0,0 +1,408 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// ErrorCode identifies the kind of a parse error, it is stable and suitable for localizing the message.
+//
+// ErrorCode implements error, so errors.Is(err, ErrorCodeUnclosedString) reports whether err is a parse error
+// with the code.
+type ErrorCode int
+
+const (
+	ErrorCodeUnknown              ErrorCode = iota // unknown error, e.g. an error not created by the parser
+	ErrorCodeEmptyQuery                            // the query is empty
+	ErrorCodeUnexpectedToken                       // a token that is not expected at the position
+	ErrorCodeUnclosedString                        // a double quoted string without closing double quote
+	ErrorCodeBadEscape                             // an escape sequence that is not allowed
+	ErrorCodeBadNumber                             // a malformed number, e.g. `1.` or `1a`
+	ErrorCodeUnmatchedParen                        // a parenthesis without its counterpart
+	ErrorCodeUnmatchedBrace                        // a brace without its counterpart
+	ErrorCodeNonNumericRangeValue                  // a range(>, >=, <, <=) value that is neither a number nor a date
+	ErrorCodeWildcardNestedPath                    // a nested field query path with wildcard
+	ErrorCodeTooLong                               // the query exceeds the maximum length, see WithMaxLength
+	ErrorCodeTooDeep                               // the query exceeds the maximum nesting depth, see WithMaxDepth
+	ErrorCodeStrict                                // a lenient form that is rejected in strict mode, see WithStrict
+	ErrorCodeBadDateMath                           // a malformed date math range value, e.g. `now-1x`
+)
+
+var errorCodes = [...]string{
+	ErrorCodeUnknown:              "unknown",
+	ErrorCodeEmptyQuery:           "empty_query",
+	ErrorCodeUnexpectedToken:      "unexpected_token",
+	ErrorCodeUnclosedString:       "unclosed_string",
+	ErrorCodeBadEscape:            "bad_escape",
+	ErrorCodeBadNumber:            "bad_number",
+	ErrorCodeUnmatchedParen:       "unmatched_paren",
+	ErrorCodeUnmatchedBrace:       "unmatched_brace",
+	ErrorCodeNonNumericRangeValue: "non_numeric_range_value",
+	ErrorCodeWildcardNestedPath:   "wildcard_nested_path",
+	ErrorCodeTooLong:              "too_long",
+	ErrorCodeTooDeep:              "too_deep",
+	ErrorCodeStrict:               "strict",
+	ErrorCodeBadDateMath:          "bad_date_math",
+}
+
+// String returns the name of the error code, e.g. "unexpected_token".
+func (c ErrorCode) String() string {
+	if c >= 0 && int(c) < len(errorCodes) {
+		return errorCodes[c]
+	}
+
+	return fmt.Sprintf("ErrorCode(%d)", c)
+}
+
+// Error returns the name of the error code.
+func (c ErrorCode) Error() string {
+	return c.String()
+}
+
+// MarshalText encodes the error code as its name.
+func (c ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// codeError is an error of the lexer or the parser, which carries the code and the expected tokens.
+type codeError struct {
+	code     ErrorCode
+	expected []token.Kind
+	err      error
+}
+
+func (e *codeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codeError) Unwrap() error {
+	return e.err
+}
+
+// errorf formats an error with code.
+func errorf(code ErrorCode, format string, args ...interface{}) error {
+	return &codeError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// expectError annotates err with code and the expected tokens.
+func expectError(code ErrorCode, err error, expected ...token.Kind) error {
+	return &codeError{code: code, expected: expected, err: err}
+}
+
+// keywordKinds are the expected tokens of token.KeywordsExpected.
+var keywordKinds = []token.Kind{token.TokenKindKeywordOr, token.TokenKindKeywordAnd, token.TokenKindKeywordNot}
+
+// Error is an error that occurs when parsing a KQL(kibana query language) expression, which carries the context.
+//
+// Offsets, lines and columns start from 0, lines are separated by '\n'.
+type Error struct {
+	s        string
+	pos, end int // span of the token or the expression that causes the error
+	err      error
+}
+
+// NewError creates a new KQL(kibana query language) parse error at pos, its span is lastTokenValue
+// unless lastTokenKind is unknown(0).
+func NewError(s string, lastTokenKind token.Kind, lastTokenValue string, pos int, err error) error {
+	end := pos
+	if lastTokenKind > 0 {
+		end += utf8.RuneCountInString(lastTokenValue)
+	}
+
+	return spanError(s, pos, end, err)
+}
+
+// spanError creates a new parse error of the span [pos, end) of s, err is returned as it is if it is an *Error.
+func spanError(s string, pos, end int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &Error{}
+	if errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{s: s, pos: pos, end: end, err: err}
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() ErrorCode {
+	var ce *codeError
+	if errors.As(e.err, &ce) {
+		return ce.code
+	}
+
+	return ErrorCodeUnknown
+}
+
+// Expected returns the tokens that are expected at the position, it is nil if they are unknown.
+func (e *Error) Expected() []token.Kind {
+	var ce *codeError
+	if errors.As(e.err, &ce) {
+		return append([]token.Kind(nil), ce.expected...)
+	}
+
+	return nil
+}
+
+// Message returns the error message without the position and the query.
+func (e *Error) Message() string {
+	return e.err.Error()
+}
+
+// Offset returns the byte offset of the error in the query.
+func (e *Error) Offset() int {
+	return ast.ByteOffset(e.s, e.pos)
+}
+
+// RuneOffset returns the rune(character) offset of the error in the query.
+func (e *Error) RuneOffset() int {
+	return e.pos
+}
+
+// Line returns the line of the error.
+func (e *Error) Line() int {
+	line, _ := e.lineColumn()
+
+	return line
+}
+
+// Column returns the column(in runes) of the error in its line.
+func (e *Error) Column() int {
+	_, column := e.lineColumn()
+
+	return column
+}
+
+// Len returns the length(in runes) of the span of the error, it is 0 for an error at the end of the query.
+func (e *Error) Len() int {
+	return maxInt(e.end-e.pos, 0)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error has the code target, e.g. errors.Is(err, ErrorCodeUnclosedString).
+func (e *Error) Is(target error) bool {
+	code, ok := target.(ErrorCode)
+
+	return ok && code == e.Code()
+}
+
+// MarshalJSON encodes the error as a JSON object with the message, code, position and expected tokens.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	var expected []string
+	for _, kind := range e.Expected() {
+		expected = append(expected, kind.String())
+	}
+
+	return json.Marshal(struct {
+		Message    string    `json:"message"`
+		Code       ErrorCode `json:"code"`
+		Offset     int       `json:"offset"`
+		RuneOffset int       `json:"rune_offset"`
+		Line       int       `json:"line"`
+		Column     int       `json:"column"`
+		Len        int       `json:"len"`
+		Expected   []string  `json:"expected,omitempty"`
+	}{
+		Message:    e.Message(),
+		Code:       e.Code(),
+		Offset:     e.Offset(),
+		RuneOffset: e.RuneOffset(),
+		Line:       e.Line(),
+		Column:     e.Column(),
+		Len:        e.Len(),
+		Expected:   expected,
+	})
+}
+
+// ErrorList is the list of the errors of a query in the order of their positions,
+// it is returned by the parser in recovery mode(see WithRecovery).
+type ErrorList []*Error
+
+// Error returns the messages of all the errors.
+func (l ErrorList) Error() string {
+	if len(l) == 0 {
+		return "no errors"
+	}
+
+	var buf strings.Builder
+	for _, e := range l {
+		buf.WriteString(e.Error())
+	}
+
+	return buf.String()
+}
+
+// Err returns an error equivalent to the list, it is nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Is reports whether any of the errors has the code target, e.g. errors.Is(err, ErrorCodeUnclosedString).
+func (l ErrorList) Is(target error) bool {
+	for _, e := range l {
+		if e.Is(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Error) lineColumn() (line, column int) {
+	for i, r := range []rune(e.s) {
+		if i >= e.pos {
+			break
+		}
+
+		if r == '\n' {
+			line++
+			column = 0
+		} else {
+			column++
+		}
+	}
+
+	return line, column
+}
+
+// Error returns the error message.
+//
+// The message is followed by the line of the error and the carets under the span of the error, the carets are
+// aligned by the display width of the characters, e.g. a CJK character takes 2 columns and a combining mark none.
+func (e *Error) Error() string {
+	runes := []rune(e.s)
+	if e.pos > len(runes) {
+		return e.err.Error()
+	}
+
+	lineNo, column := e.lineColumn()
+
+	start, end := e.pos-column, e.pos
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+
+	line := runes[start:end]
+	if n := len(line); n > 0 && line[n-1] == '\r' { // CRLF line ending
+		line = line[:n-1]
+	}
+
+	var buf strings.Builder
+
+	buf.WriteString(fmt.Sprintf("line %d:%d %s\n", lineNo, column, e.err.Error()))
+	buf.WriteString(string(line))
+	buf.WriteByte('\n')
+
+	for _, r := range line[:minInt(column, len(line))] {
+		if r == '\t' { // keep the tabs to align with the line
+			buf.WriteByte('\t')
+
+			continue
+		}
+
+		buf.WriteString(strings.Repeat(" ", runeWidth(r)))
+	}
+
+	width := 0
+	for _, r := range line[minInt(column, len(line)):minInt(column+e.Len(), len(line))] {
+		width += runeWidth(r)
+	}
+
+	buf.WriteString(strings.Repeat("^", maxInt(width, 1)))
+	buf.WriteByte('\n')
+
+	return buf.String()
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// wideRunes are the East Asian wide and fullwidth characters and the emoji, which take 2 columns in terminals.
+var wideRunes = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115f, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232a, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23ec, Stride: 1},
+		{Lo: 0x25fd, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x26aa, Hi: 0x26ab, Stride: 1},
+		{Lo: 0x26bd, Hi: 0x26be, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270a, Hi: 0x270b, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274c, Hi: 0x274c, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2e80, Hi: 0x303e, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33ff, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0x9fff, Stride: 1},
+		{Lo: 0xa000, Hi: 0xa4cf, Stride: 1},
+		{Lo: 0xa960, Hi: 0xa97f, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7a3, Stride: 1},
+		{Lo: 0xf900, Hi: 0xfaff, Stride: 1},
+		{Lo: 0xfe10, Hi: 0xfe19, Stride: 1},
+		{Lo: 0xfe30, Hi: 0xfe6f, Stride: 1},
+		{Lo: 0xff00, Hi: 0xff60, Stride: 1},
+		{Lo: 0xffe0, Hi: 0xffe6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16fe0, Hi: 0x18cff, Stride: 1},
+		{Lo: 0x1b000, Hi: 0x1b2ff, Stride: 1},
+		{Lo: 0x1f004, Hi: 0x1f004, Stride: 1},
+		{Lo: 0x1f18e, Hi: 0x1f18e, Stride: 1},
+		{Lo: 0x1f191, Hi: 0x1f19a, Stride: 1},
+		{Lo: 0x1f200, Hi: 0x1f251, Stride: 1},
+		{Lo: 0x1f300, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f680, Hi: 0x1f6ff, Stride: 1},
+		{Lo: 0x1f7e0, Hi: 0x1f7eb, Stride: 1},
+		{Lo: 0x1f90c, Hi: 0x1f9ff, Stride: 1},
+		{Lo: 0x1fa70, Hi: 0x1faff, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2fffd, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3fffd, Stride: 1},
+	},
+}
+
+// runeWidth returns the number of columns that r takes in terminals.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || r == '\u200b':
+		return 0
+	case unicode.Is(wideRunes, r):
+		return 2
+	}
+
+	return 1
+}
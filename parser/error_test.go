@@ -0,0 +1,192 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestError_Code(t *testing.T) {
+	cases := []struct {
+		input        string
+		opts         []parser.Option
+		wantCode     parser.ErrorCode
+		wantExpected []token.Kind
+		wantOffset   int
+		wantLen      int
+	}{
+		{input: " ", wantCode: parser.ErrorCodeEmptyQuery},
+		{input: "foo bar", wantCode: parser.ErrorCodeUnexpectedToken, wantExpected: []token.Kind{
+			token.TokenKindKeywordOr, token.TokenKindKeywordAnd, token.TokenKindKeywordNot,
+		}, wantOffset: 4, wantLen: 3},
+		{input: "foo: ", wantCode: parser.ErrorCodeUnexpectedToken, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat, token.TokenKindString, token.TokenKindIdent, token.TokenKindLparen,
+		}, wantOffset: 5},
+		{input: `:a`, wantCode: parser.ErrorCodeUnexpectedToken, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat, token.TokenKindString, token.TokenKindIdent, token.TokenKindLparen,
+		}, wantLen: 1},
+		{input: `)`, wantCode: parser.ErrorCodeUnexpectedToken, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat, token.TokenKindString, token.TokenKindIdent, token.TokenKindLparen,
+		}, wantLen: 1},
+		{input: `foo: "bar`, wantCode: parser.ErrorCodeUnclosedString, wantOffset: 5, wantLen: 4},
+		{input: `foo: b\ar`, wantCode: parser.ErrorCodeBadEscape, wantOffset: 5, wantLen: 4},
+		{input: `foo: 1.`, wantCode: parser.ErrorCodeBadNumber, wantOffset: 5, wantLen: 2},
+		{input: `(foo: bar`, wantCode: parser.ErrorCodeUnmatchedParen, wantExpected: []token.Kind{token.TokenKindRparen}, wantOffset: 9},
+		{input: `foo: bar)`, wantCode: parser.ErrorCodeUnmatchedParen, wantExpected: []token.Kind{token.TokenKindEof}, wantOffset: 8, wantLen: 1},
+		{input: `items: { foo: bar`, wantCode: parser.ErrorCodeUnmatchedBrace, wantExpected: []token.Kind{token.TokenKindRbrace}, wantOffset: 17},
+		{input: `foo > bar`, wantCode: parser.ErrorCodeNonNumericRangeValue, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat,
+		}, wantOffset: 6, wantLen: 3},
+		{input: `foo > bar AND baz: 1`, wantCode: parser.ErrorCodeNonNumericRangeValue, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat,
+		}, wantOffset: 6, wantLen: 3},
+		{input: `foo > "bar"`, wantCode: parser.ErrorCodeNonNumericRangeValue, wantExpected: []token.Kind{
+			token.TokenKindInt, token.TokenKindFloat,
+		}, wantOffset: 6, wantLen: 5},
+		{input: `item*: { foo: bar }`, wantCode: parser.ErrorCodeWildcardNestedPath, wantOffset: 7, wantLen: 1},
+		{input: `foo: bar`, opts: []parser.Option{parser.WithMaxLength(3)}, wantCode: parser.ErrorCodeTooLong, wantOffset: 3},
+		{input: `((foo: bar))`, opts: []parser.Option{parser.WithMaxDepth(1)}, wantCode: parser.ErrorCodeTooDeep, wantOffset: 1, wantLen: 1},
+		{input: `a NOT b`, opts: []parser.Option{parser.WithStrict(true)}, wantCode: parser.ErrorCodeStrict, wantExpected: []token.Kind{
+			token.TokenKindKeywordAnd, token.TokenKindKeywordOr,
+		}, wantOffset: 2, wantLen: 3},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			_, err := parser.New(c.input, c.opts...).Stmt()
+			require.Error(t, err)
+
+			var e *parser.Error
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, c.wantCode, e.Code())
+			assert.Equal(t, c.wantExpected, e.Expected())
+			assert.Equal(t, c.wantOffset, e.Offset())
+			assert.Equal(t, c.wantOffset, e.RuneOffset())
+			assert.Equal(t, c.wantLen, e.Len())
+			assert.True(t, errors.Is(err, c.wantCode))
+			assert.False(t, errors.Is(err, parser.ErrorCodeUnknown))
+		})
+	}
+}
+
+func TestError_Position(t *testing.T) {
+	err := parser.NewError("foo: bar\nAND 数据: \"x\" baz", token.TokenKindString, "baz", 19, errors.New("boom"))
+
+	var e *parser.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, 1, e.Line())
+	assert.Equal(t, 10, e.Column())
+	assert.Equal(t, 19, e.RuneOffset())
+	assert.Equal(t, 23, e.Offset())
+	assert.Equal(t, 3, e.Len())
+	assert.Equal(t, "boom", e.Message())
+	assert.Equal(t, parser.ErrorCodeUnknown, e.Code())
+	assert.Nil(t, e.Expected())
+	assert.Equal(t, "boom", errors.Unwrap(err).Error())
+}
+
+func TestError_MarshalJSON(t *testing.T) {
+	_, err := parser.New("foo bar").Stmt()
+	require.Error(t, err)
+
+	data, err := json.Marshal(err)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"message": "expected keyword OR|AND|NOT, but got \"Ident\"",
+		"code": "unexpected_token",
+		"offset": 4,
+		"rune_offset": 4,
+		"line": 0,
+		"column": 4,
+		"len": 3,
+		"expected": ["OR", "AND", "NOT"]
+	}`, string(data))
+}
+
+func TestErrorCode_String(t *testing.T) {
+	assert.Equal(t, "unclosed_string", parser.ErrorCodeUnclosedString.String())
+	assert.Equal(t, "unclosed_string", parser.ErrorCodeUnclosedString.Error())
+	assert.Equal(t, "ErrorCode(-1)", parser.ErrorCode(-1).String())
+}
+
+func TestError_MultiByte(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		wantOffset int
+		wantRune   int
+		wantLine   int
+		wantColumn int
+		wantError  string
+	}{
+		{
+			name:       "CJK",
+			input:      `日志: "错误" 数据`,
+			wantOffset: 17,
+			wantRune:   9,
+			wantColumn: 9,
+			wantError:  "line 0:9 expected keyword OR|AND|NOT, but got \"Ident\"\n日志: \"错误\" 数据\n             ^^^^\n",
+		},
+		{
+			name:       "emoji",
+			input:      `msg: 🔥 x`,
+			wantOffset: 10,
+			wantRune:   7,
+			wantColumn: 7,
+			wantError:  "line 0:7 expected keyword OR|AND|NOT, but got \"Ident\"\nmsg: 🔥 x\n        ^\n",
+		},
+		{
+			name:       "combining characters",
+			input:      "name: cafe\u0301 bar",
+			wantOffset: 13,
+			wantRune:   12,
+			wantColumn: 12,
+			wantError:  "line 0:12 expected keyword OR|AND|NOT, but got \"Ident\"\nname: cafe\u0301 bar\n           ^^^\n",
+		},
+		{
+			name:       "CRLF",
+			input:      "a: 1 AND\r\nb: 2\r\nc d",
+			wantOffset: 16,
+			wantRune:   16,
+			wantLine:   2,
+			wantError:  "line 2:0 expected keyword OR|AND|NOT, but got \"Ident\"\nc d\n^\n",
+		},
+		{
+			name:       "CRLF at the end of line",
+			input:      "a: \"x\r\n",
+			wantOffset: 3,
+			wantRune:   3,
+			wantColumn: 3,
+			wantError:  "line 0:3 expected double quote closed\na: \"x\n   ^^\n",
+		},
+		{
+			name:       "tab",
+			input:      "a: 1\tb",
+			wantOffset: 5,
+			wantRune:   5,
+			wantColumn: 5,
+			wantError:  "line 0:5 expected keyword OR|AND|NOT, but got \"Ident\"\na: 1\tb\n    \t^\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parser.New(c.input).Stmt()
+			require.Error(t, err)
+
+			var e *parser.Error
+			require.True(t, errors.As(err, &e))
+			assert.Equal(t, c.wantOffset, e.Offset())
+			assert.Equal(t, c.wantRune, e.RuneOffset())
+			assert.Equal(t, c.wantLine, e.Line())
+			assert.Equal(t, c.wantColumn, e.Column())
+			assert.Equal(t, c.wantError, e.Error())
+		})
+	}
+}
@@ -2,7 +2,6 @@ package parser
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
 
 	"github.com/laojianzi/kql-go/token"
@@ -111,7 +110,7 @@ func (l *defaultLexer) handleEscapeSequence(pos int, k token.Kind) (bool, error)
 
 	// If it's not a keyword or operator, check if it's a valid special character
 	if !token.IsSpecialChar(string(ch)) {
-		return false, errors.New("unexpected escapes")
+		return false, errorf(ErrorCodeBadEscape, "unexpected escapes")
 	}
 
 	return true, nil
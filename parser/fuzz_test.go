@@ -4,6 +4,7 @@
 package parser_test
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -26,6 +27,8 @@ func FuzzParser(f *testing.F) {
 		"field >= 10",
 		"field < 10",
 		"field <= 10",
+		"field >= now-7d/d",
+		`field < "2024-01-01||+1M/d"`,
 		"field: true",
 		"field: false",
 		"field: null",
@@ -39,6 +42,7 @@ func FuzzParser(f *testing.F) {
 		`field: "value with \n newline"`,
 		"field1: value1 AND field2: value2 OR field3: value3",
 		"field1: (value1 OR value2) AND field2: value3",
+		"items: { name: value AND qty > 2 }",
 	}
 
 	for _, seed := range seeds {
@@ -67,3 +71,39 @@ func FuzzParser(f *testing.F) {
 		assert.Equal(t, stmt.String(), stmt2.String())
 	})
 }
+
+func FuzzParser_Recovery(f *testing.F) {
+	seeds := []string{
+		"field: value",
+		"field: AND other: value",
+		"a b AND c: 1",
+		`(a: 1 OR b: "x) AND c: 2`,
+		"a: 1) AND b: 2",
+		"items: { a: 1 AND b: } AND c: >",
+		`x: b\ar AND y: 1. OR z: ok`,
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		// the recovery mode must agree with the fail-fast mode:
+		// 1. the same expression(AST) if the query has no errors
+		// 2. the same first error otherwise
+		stmt, err := parser.New(query).Stmt()
+		recovered, errs := parser.New(query, parser.WithRecovery(true)).Stmt()
+
+		if err == nil {
+			assert.NoError(t, errs)
+			assert.Equal(t, stmt.String(), recovered.String())
+
+			return
+		}
+
+		var list parser.ErrorList
+		if assert.True(t, errors.As(errs, &list)) {
+			assert.Equal(t, err.Error(), list[0].Error())
+		}
+	})
+}
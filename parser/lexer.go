@@ -2,8 +2,6 @@ package parser
 
 import (
 	"bytes"
-	"errors"
-	"fmt"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -16,51 +14,121 @@ type defaultLexer struct {
 	Value []rune
 	Token Token
 
+	input         string
 	pos           int
 	lastTokenKind token.Kind
+	lastTokenEnd  int // end position of the last token
 	dotIdent      bool
+	trivia        bool // emit the spaces as whitespace tokens instead of skipping them
+	opts          options
+
+	// onError handles the errors in recovery mode, the token that can not be scanned becomes an illegal token.
+	onError func(err error)
+
+	// offsetPos and offset are the last rune position and its byte offset that offsetOf has counted to.
+	offsetPos, offset int
 }
 
-// newLexer creates a new lexer
-func newLexer(input string) *defaultLexer {
-	return &defaultLexer{Value: []rune(strings.TrimSpace(input))}
+// newLexer creates a new lexer, positions are rune offsets into input including its leading spaces.
+func newLexer(input string, opts options) *defaultLexer {
+	return &defaultLexer{Value: []rune(input), input: input, opts: opts}
 }
 
 // nextToken returns the next token from the input stream
 func (l *defaultLexer) nextToken() error {
 	l.lastTokenKind = l.Token.Kind
+	l.lastTokenEnd = l.pos
 
-	for {
-		i := l.pos
-		l.skipSpaces()
+	if maxLength := l.opts.maxLength; maxLength > 0 && len(l.Value) > maxLength {
+		l.Token = Token{Pos: maxLength, End: maxLength}
+		l.Token.Offset = l.offsetOf(maxLength)
+		l.Token.EndOffset = l.Token.Offset
 
-		if l.pos == i {
-			break
+		err := spanError(l.input, maxLength, maxLength,
+			errorf(ErrorCodeTooLong, "expected at most %d characters, but got %d", maxLength, len(l.Value)))
+		if l.onError == nil {
+			return err
 		}
+
+		l.onError(err)
+		l.Token.Kind = token.TokenKindEof // nothing more can be scanned
+
+		return nil
+	}
+
+	if l.trivia && !l.eof() && unicode.IsSpace(l.peek(0)) {
+		l.Token = Token{Kind: token.TokenKindWhitespace, Pos: l.pos}
+		l.skipSpaces()
+		l.Token.End = l.pos
+		l.Token.Value = string(l.Value[l.Token.Pos:l.Token.End])
+		l.Token.Offset = l.offsetOf(l.Token.Pos)
+		l.Token.EndOffset = l.offsetOf(l.Token.End)
+
+		return nil
 	}
 
-	l.Token = Token{Pos: l.pos}
+	l.skipSpaces()
+
+	l.Token = Token{Pos: l.pos, End: l.pos}
 	if l.eof() {
 		l.Token.Kind = token.TokenKindEof
+		l.Token.Offset = l.offsetOf(l.pos)
+		l.Token.EndOffset = l.Token.Offset
 
 		return nil
 	}
 
 	defer func() {
 		l.Token.End = l.pos
-		if l.Token.Kind == token.TokenKindString { // skip the double quote "
-			l.Token.Pos += 1
-			l.Token.End -= 1
-		}
+		l.Token.Offset = l.offsetOf(l.Token.Pos)
+		l.Token.EndOffset = l.offsetOf(l.Token.End)
 	}()
 
-	if !l.dotIdent {
-		return l.consumeToken()
+	var err error
+	if l.dotIdent {
+		l.dotIdent = false
+		err = l.consumeFieldToken()
+	} else {
+		err = l.consumeToken()
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	err = spanError(l.input, l.Token.Pos, l.illegalEnd(), err)
+	if l.onError == nil {
+		return err
+	}
+
+	l.onError(err)
+	l.skipIllegal()
+
+	return nil
+}
+
+// illegalEnd returns the end position of the source that can not be scanned from the current token, which is
+// the rest of the input for an unclosed string or the characters up to the next space, parenthesis or brace.
+func (l *defaultLexer) illegalEnd() int {
+	if l.Value[l.Token.Pos] == '"' {
+		return len(l.Value)
 	}
 
-	l.dotIdent = false
+	end := l.Token.Pos + 1
+	for end < len(l.Value) && !unicode.IsSpace(l.Value[end]) && !strings.ContainsRune("(){}", l.Value[end]) {
+		end++
+	}
+
+	return end
+}
+
+// skipIllegal turns the current token into an illegal token, which spans the source up to illegalEnd.
+func (l *defaultLexer) skipIllegal() {
+	l.pos = l.illegalEnd()
 
-	return l.consumeFieldToken()
+	l.Token.Kind = token.TokenKindIllegal
+	l.Token.Value = string(l.Value[l.Token.Pos:l.pos])
+	l.Token.EscapeIndexes = nil
 }
 
 // consumeToken consumes the next token from the input stream
@@ -68,7 +136,7 @@ func (l *defaultLexer) consumeToken() error {
 	switch l.peek(0) {
 	case ':', '<', '>': // operator
 		return l.consumeOperator()
-	case '(', ')':
+	case '(', ')', '{', '}':
 		return l.consumeParen()
 	case '+', '-': // with sign int or float
 		fallthrough // jump to number case
@@ -108,7 +176,7 @@ func (l *defaultLexer) shouldBreak(i int, isString, withEscape bool, endChar run
 	}
 
 	if !isString && !withEscape {
-		if unicode.IsSpace(ch) || ch == ')' || ch == ':' {
+		if unicode.IsSpace(ch) || ch == ')' || ch == '}' || ch == ':' {
 			return true
 		}
 	}
@@ -128,7 +196,7 @@ func (l *defaultLexer) collectNextToken(start int) string {
 
 	for j := start; l.peekOk(j + 1); j++ {
 		currentRune, nextRune := l.peek(j), l.peek(j+1)
-		if currentRune != '\\' && (unicode.IsSpace(nextRune) || nextRune == ')' || nextRune == ':') {
+		if currentRune != '\\' && (unicode.IsSpace(nextRune) || nextRune == ')' || nextRune == '}' || nextRune == ':') {
 			break
 		}
 
@@ -177,7 +245,7 @@ func (l *defaultLexer) consumeEscapedToken(kind token.Kind, endChar rune) (i int
 	}
 
 	if escape {
-		return 0, nil, errors.New("unexpected escapes")
+		return 0, nil, errorf(ErrorCodeBadEscape, "unexpected escapes")
 	}
 
 	if buf.Len() > 0 {
@@ -218,7 +286,7 @@ func (l *defaultLexer) consumeString() error {
 	}
 
 	if !l.peekOk(i) {
-		return errors.New("expected double quote closed")
+		return errorf(ErrorCodeUnclosedString, "expected double quote closed")
 	}
 
 	l.Token.Kind = token.TokenKindString
@@ -234,11 +302,11 @@ func (l *defaultLexer) consumeNumber() error {
 	var i int
 	if l.peek(0) == '+' || l.peek(0) == '-' { // skip sign
 		if !l.peekOk(i + 1) {
-			return fmt.Errorf("expected digit, but got Eof")
+			return errorf(ErrorCodeBadNumber, "expected digit, but got Eof")
 		}
 
 		if nextChar := l.peek(i + 1); !unicode.IsDigit(nextChar) {
-			return fmt.Errorf("expected digit, but got %q", string(nextChar))
+			return errorf(ErrorCodeBadNumber, "expected digit, but got %q", string(nextChar))
 		}
 
 		i++
@@ -248,7 +316,7 @@ func (l *defaultLexer) consumeNumber() error {
 
 	for l.peekOk(i) {
 		b := l.peek(i)
-		if unicode.IsSpace(rune(b)) || b == ')' {
+		if unicode.IsSpace(rune(b)) || b == ')' || b == '}' {
 			break
 		}
 
@@ -257,16 +325,20 @@ func (l *defaultLexer) consumeNumber() error {
 				return l.consumeIdent()
 			}
 
-			return fmt.Errorf("expected digit or decimal point, but got %q", string(b))
+			return errorf(ErrorCodeBadNumber, "expected digit or decimal point, but got %q", string(b))
 		}
 
 		if b == '.' {
 			if !l.peekOk(i + 1) {
-				return fmt.Errorf("expected digit, but got Eof")
+				return errorf(ErrorCodeBadNumber, "expected digit, but got Eof")
 			}
 
 			if nextChar := l.peek(i + 1); !unicode.IsDigit(nextChar) {
-				return fmt.Errorf("expected digit, but got %q", string(nextChar))
+				return errorf(ErrorCodeBadNumber, "expected digit, but got %q", string(nextChar))
+			}
+
+			if l.Token.Kind == token.TokenKindFloat { // not a number but an IP or a version, e.g. `10.0.0.1` or `1.2.3`
+				return l.consumeIdent()
 			}
 
 			l.Token.Kind = token.TokenKindFloat
@@ -297,7 +369,7 @@ func (l *defaultLexer) consumeOperator() error {
 	return nil
 }
 
-// consumeParen consumes a parenthesis token
+// consumeParen consumes a parenthesis or brace token
 func (l *defaultLexer) consumeParen() error {
 	l.Token.Value = l.slice(0, 1)
 
@@ -306,8 +378,12 @@ func (l *defaultLexer) consumeParen() error {
 		l.Token.Kind = token.TokenKindLparen
 	case ')':
 		l.Token.Kind = token.TokenKindRparen
+	case '{':
+		l.Token.Kind = token.TokenKindLbrace
+	case '}':
+		l.Token.Kind = token.TokenKindRbrace
 	default:
-		return fmt.Errorf("expected token \"(\" or \")\", but got %q", string(l.peek(0)))
+		return errorf(ErrorCodeUnexpectedToken, "expected token \"(\", \")\", \"{\" or \"}\", but got %q", string(l.peek(0)))
 	}
 
 	l.skipN(1)
@@ -317,14 +393,23 @@ func (l *defaultLexer) consumeParen() error {
 
 // skipSpaces skips whitespace characters
 func (l *defaultLexer) skipSpaces() {
-	for !l.eof() {
-		r, size := utf8.DecodeRuneInString(string(l.Value[l.pos:]))
-		if !unicode.IsSpace(r) {
-			return
-		}
+	for !l.eof() && unicode.IsSpace(l.peek(0)) {
+		l.skipN(1)
+	}
+}
 
-		l.skipN(size)
+// offsetOf returns the byte offset of the rune position pos in the input.
+func (l *defaultLexer) offsetOf(pos int) int {
+	if pos < l.offsetPos { // positions mostly grow, count again from the start otherwise
+		l.offsetPos, l.offset = 0, 0
 	}
+
+	for ; l.offsetPos < pos && l.offset < len(l.input); l.offsetPos++ {
+		_, size := utf8.DecodeRuneInString(l.input[l.offset:])
+		l.offset += size
+	}
+
+	return l.offset
 }
 
 // skipN skips n characters
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/token"
+)
+
+func Test_defaultLexer_positions(t *testing.T) {
+	type position struct {
+		Kind              token.Kind
+		Pos, End          int
+		Offset, EndOffset int
+	}
+
+	cases := []struct {
+		input string
+		want  []position
+	}{
+		{
+			input: ` 日志: "错误"`,
+			want: []position{
+				{Kind: token.TokenKindIdent, Pos: 1, End: 3, Offset: 1, EndOffset: 7},
+				{Kind: token.TokenKindOperatorEql, Pos: 3, End: 4, Offset: 7, EndOffset: 8},
+				{Kind: token.TokenKindString, Pos: 5, End: 9, Offset: 9, EndOffset: 17},
+				{Kind: token.TokenKindEof, Pos: 9, End: 9, Offset: 17, EndOffset: 17},
+			},
+		},
+		{
+			input: "cafe\u0301\r\nOR 🔥",
+			want: []position{
+				{Kind: token.TokenKindIdent, Pos: 0, End: 5, Offset: 0, EndOffset: 6},
+				{Kind: token.TokenKindKeywordOr, Pos: 7, End: 9, Offset: 8, EndOffset: 10},
+				{Kind: token.TokenKindIdent, Pos: 10, End: 11, Offset: 11, EndOffset: 15},
+				{Kind: token.TokenKindEof, Pos: 11, End: 11, Offset: 15, EndOffset: 15},
+			},
+		},
+		{
+			input: `10.0.0.1 1.5 1.2.3`,
+			want: []position{
+				{Kind: token.TokenKindIdent, Pos: 0, End: 8, Offset: 0, EndOffset: 8},
+				{Kind: token.TokenKindFloat, Pos: 9, End: 12, Offset: 9, EndOffset: 12},
+				{Kind: token.TokenKindIdent, Pos: 13, End: 18, Offset: 13, EndOffset: 18},
+				{Kind: token.TokenKindEof, Pos: 18, End: 18, Offset: 18, EndOffset: 18},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			l := newLexer(c.input, options{})
+
+			var got []position
+
+			for {
+				require.NoError(t, l.nextToken())
+
+				tok := l.Token
+				got = append(got, position{tok.Kind, tok.Pos, tok.End, tok.Offset, tok.EndOffset})
+				assert.Equal(t, string(l.Value[tok.Pos:tok.End]), c.input[tok.Offset:tok.EndOffset])
+
+				if tok.Kind == token.TokenKindEof {
+					break
+				}
+			}
+
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
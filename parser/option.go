@@ -0,0 +1,132 @@
+package parser
+
+import "github.com/laojianzi/kql-go/ast"
+
+// Precedence decides how the AND/OR keywords of a query are grouped.
+type Precedence int
+
+const (
+	// PrecedenceKibana binds NOT tighter than AND and AND tighter than OR, which is how Kibana evaluates KQL.
+	//
+	// e.g. `a OR b AND c` is parsed as `a OR (b AND c)`.
+	PrecedenceKibana Precedence = iota
+	// PrecedenceLeftToRight combines keywords strictly from left to right, which is the legacy behavior.
+	//
+	// e.g. `a OR b AND c` is parsed as `(a OR b) AND c`.
+	PrecedenceLeftToRight
+)
+
+// RangeMode decides the values that the range operators(>, >=, <, <=) accept.
+type RangeMode int
+
+const (
+	// RangeModeNumeric accepts numbers and, as *ast.DateExpr, dates and date math values,
+	// the other values are rejected with ErrorCodeNonNumericRangeValue.
+	RangeModeNumeric RangeMode = iota
+	// RangeModeKibana accepts any value like Kibana, e.g. `host.ip > 10.0.0.0` and `version >= "1.2.3"`,
+	// the dates and date math values are still parsed as *ast.DateExpr. Whether a value is valid depends on
+	// the type of the field, which is left to the schema(see schema.Validate).
+	RangeModeKibana
+)
+
+// Option configures the parser created by New.
+type Option func(*options)
+
+type options struct {
+	precedence Precedence
+	maxLength  int
+	maxDepth   int
+	strict     bool
+	recovery   bool
+	trivia     bool
+	types      TypeResolver
+	rangeMode  RangeMode
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPrecedence sets how the AND/OR keywords are grouped, the default is PrecedenceKibana.
+func WithPrecedence(precedence Precedence) Option {
+	return func(o *options) {
+		o.precedence = precedence
+	}
+}
+
+// WithMaxLength limits the length(in characters) of the query, the default is 0, which means no limit.
+func WithMaxLength(n int) Option {
+	return func(o *options) {
+		o.maxLength = n
+	}
+}
+
+// WithMaxDepth limits the nesting depth of the parentheses and braces of the query,
+// the default is 0, which means no limit.
+//
+// e.g. the depth of `a: 1 AND (b: 2 OR c: { d: 3 })` is 2.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// WithStrict rejects the forms that Kibana accepts leniently, the default is false.
+//
+//   - NOT between two clauses without AND/OR, e.g. `a NOT b` must be written as `a AND NOT b`
+//   - wildcards in range values, e.g. `age > 1*`
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// WithRecovery makes the parser report all the errors of the query in one pass instead of stopping at the first one,
+// the default is false.
+//
+// In recovery mode the parser skips to the next `)`, `}`, keyword or the end of the query after an error,
+// and Stmt returns the partial expression(AST), in which the source that can not be parsed is an *ast.BadExpr,
+// together with an ErrorList of all the errors.
+func WithRecovery(recovery bool) Option {
+	return func(o *options) {
+		o.recovery = recovery
+	}
+}
+
+// WithTrivia makes the Scanner emit the spaces between tokens as token.TokenKindWhitespace tokens,
+// the default is false. It is ignored by the parser.
+func WithTrivia(trivia bool) Option {
+	return func(o *options) {
+		o.trivia = trivia
+	}
+}
+
+// WithRangeMode sets the values that the range operators accept, the default is RangeModeNumeric.
+func WithRangeMode(mode RangeMode) Option {
+	return func(o *options) {
+		o.rangeMode = mode
+	}
+}
+
+// TypeResolver coerces the values of the fields whose types it knows, e.g. a schema.Schema.
+// It is declared here since the packages that know the types of the fields may import the parser.
+type TypeResolver interface {
+	// Coerce returns the typed value(e.g. an int64 of a long field) of the value lit of field,
+	// the fields of a nested field query are named with the path as prefix(e.g. `items.name`).
+	// It returns false if the type of field is unknown or lit can not be coerced into it.
+	Coerce(field string, lit *ast.Literal) (interface{}, bool)
+}
+
+// WithTypeResolver makes the parser attach the values coerced by types to the values of the fields
+// as ast.Literal.Coerced, the default is nil, which means no coercion.
+// The values that can not be coerced are left as they are, a schema.Validate pass reports them.
+func WithTypeResolver(types TypeResolver) Option {
+	return func(o *options) {
+		o.types = types
+	}
+}
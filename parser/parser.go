@@ -5,23 +5,50 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/laojianzi/kql-go"
 	"github.com/laojianzi/kql-go/ast"
 	"github.com/laojianzi/kql-go/token"
 )
 
+// Parser is an interface capability that needs to be provided externally
+// when implementing a KQL(kibana query language) parser.
+type Parser interface {
+	// Stmt parses a KQL(kibana query language) expression(AST).
+	//
+	// In recovery mode(see WithRecovery) it returns the partial expression together with an ErrorList.
+	Stmt() (ast.Expr, error)
+}
+
 type defaultParser struct {
-	lexer *defaultLexer
+	lexer  *defaultLexer
+	opts   options
+	depth  int       // nesting depth of the parentheses and braces
+	prefix string    // path of the nested field query with trailing dot, e.g. `items.`
+	errors ErrorList // errors in recovery mode
 }
 
 // New creates a new KQL parser.
-func New(input string) kql.Parser {
-	return &defaultParser{lexer: newLexer(input)}
+func New(input string, opts ...Option) Parser {
+	o := newOptions(opts)
+
+	p := &defaultParser{lexer: newLexer(input, o), opts: o}
+	if o.recovery {
+		p.lexer.onError = p.addError
+	}
+
+	return p
 }
 
 // Stmt parses a statement from the input.
 func (p *defaultParser) Stmt() (ast.Expr, error) {
 	expr, err := p.parseStmt()
+	if p.opts.recovery {
+		if err != nil { // only the empty query is not recovered
+			p.addError(err)
+		}
+
+		return expr, p.errors.Err()
+	}
+
 	if err != nil {
 		return nil, p.toKQLError(err)
 	}
@@ -29,9 +56,100 @@ func (p *defaultParser) Stmt() (ast.Expr, error) {
 	return expr, nil
 }
 
+// addError adds err at the current token to the errors in recovery mode. An error at the same position
+// as the last one is dropped if it has the same code, or if it is at an illegal token, which is caused by
+// the error of the lexer that is reported already, e.g. an unexpected token after a bad escape.
+func (p *defaultParser) addError(err error) {
+	e, _ := p.toKQLError(err).(*Error)
+	if n := len(p.errors); n > 0 && p.errors[n-1].RuneOffset() == e.RuneOffset() &&
+		(p.errors[n-1].Code() == e.Code() || p.lexer.Token.Kind == token.TokenKindIllegal) {
+		return
+	}
+
+	p.errors = append(p.errors, e)
+}
+
+// recoverClause parses the source from pos up to the next `)`, `}`, keyword or <EOF> as an ast.BadExpr
+// after err in recovery mode.
+func (p *defaultParser) recoverClause(pos int, err error) (ast.Expr, error) {
+	if !p.opts.recovery {
+		return nil, err
+	}
+
+	p.addError(err)
+
+	end := maxInt(p.sync(), pos)
+
+	return ast.NewBadExpr(pos, end, string(p.lexer.Value[pos:end])), nil
+}
+
+// sync skips the tokens up to the next `)`, `}`, keyword or <EOF> that is not inside the skipped parentheses
+// and braces, it returns the end position of the last skipped token.
+func (p *defaultParser) sync() int {
+	end, depth := p.lexer.lastTokenEnd, 0
+
+	for {
+		switch kind := p.lexer.Token.Kind; {
+		case kind == token.TokenKindEof:
+			return end
+		case kind == token.TokenKindLparen || kind == token.TokenKindLbrace:
+			depth++
+		case kind == token.TokenKindRparen || kind == token.TokenKindRbrace:
+			if depth == 0 {
+				return end
+			}
+
+			depth--
+		case kind.IsKeyword():
+			if depth == 0 {
+				return end
+			}
+		}
+
+		end = p.lexer.pos
+		_ = p.lexer.nextToken() // the lexer reports its errors to addError in recovery mode
+	}
+}
+
+// recoverStray parses the last clause of left and the stray tokens after it(e.g. `b` of `a b` or `)` of `a)`)
+// up to the next `)`, `}`, keyword or <EOF> as an ast.BadExpr in recovery mode,
+// it reports whether there are stray tokens.
+func (p *defaultParser) recoverStray(left ast.Expr) (ast.Expr, bool) {
+	if !p.opts.recovery {
+		return left, false
+	}
+
+	kind := p.lexer.Token.Kind
+
+	switch {
+	case kind == token.TokenKindRparen || kind == token.TokenKindRbrace:
+		if p.depth > 0 {
+			return left, false
+		}
+
+		p.addError(eofError(kind))
+		_ = p.lexer.nextToken()
+	case isExprEnd(kind) || kind.IsKeyword():
+		return left, false
+	default:
+		p.addError(expectError(ErrorCodeUnexpectedToken, token.KeywordsExpected(kind.String()), keywordKinds...))
+	}
+
+	end := p.sync()
+
+	if combine, ok := left.(*ast.CombineExpr); ok { // only the last clause is bad
+		pos := combine.RightExpr.Pos()
+		combine.RightExpr = ast.NewBadExpr(pos, end, string(p.lexer.Value[pos:end]))
+
+		return combine, true
+	}
+
+	return ast.NewBadExpr(left.Pos(), end, string(p.lexer.Value[left.Pos():end])), true
+}
+
 func (p *defaultParser) parseStmt() (ast.Expr, error) {
 	if strings.TrimSpace(string(p.lexer.Value)) == "" {
-		return nil, errors.New("expected KQL(kibana query language) string, but got empty string")
+		return nil, errorf(ErrorCodeEmptyQuery, "expected KQL(kibana query language) string, but got empty string")
 	}
 
 	stmt, err := p.parseExpr()
@@ -39,19 +157,37 @@ func (p *defaultParser) parseStmt() (ast.Expr, error) {
 		return nil, err
 	}
 
-	if p.lexer.Token.Kind != token.TokenKindEof {
-		return nil, fmt.Errorf("expected <EOF>, but got %q", p.lexer.Token.Kind.String())
+	if kind := p.lexer.Token.Kind; kind != token.TokenKindEof {
+		return nil, eofError(kind)
 	}
 
 	return stmt, nil
 }
 
+// eofError is the error of the token kind found at the place of <EOF>.
+func eofError(kind token.Kind) error {
+	code := ErrorCodeUnexpectedToken
+
+	switch kind {
+	case token.TokenKindRparen:
+		code = ErrorCodeUnmatchedParen
+	case token.TokenKindRbrace:
+		code = ErrorCodeUnmatchedBrace
+	}
+
+	return expectError(code, fmt.Errorf("expected <EOF>, but got %q", kind.String()), token.TokenKindEof)
+}
+
 func (p *defaultParser) parseExpr() (ast.Expr, error) {
 	if err := p.lexer.nextToken(); err != nil {
 		return nil, err
 	}
 
-	expr, err := p.parseBinary()
+	if p.opts.precedence == PrecedenceKibana {
+		return p.parseOr()
+	}
+
+	expr, err := p.parseClause()
 	if err != nil {
 		return nil, err
 	}
@@ -59,21 +195,92 @@ func (p *defaultParser) parseExpr() (ast.Expr, error) {
 	return p.parseCombine(expr)
 }
 
+// parseOr parses clauses joined by OR, each of them is an AND group.
+func (p *defaultParser) parseOr() (ast.Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.lexer.Token.Kind == token.TokenKindKeywordOr {
+		if err := p.lexer.nextToken(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = ast.NewCombineExpr(left, token.TokenKindKeywordOr, right)
+	}
+
+	if kind := p.lexer.Token.Kind; !isExprEnd(kind) {
+		return nil, expectError(ErrorCodeUnexpectedToken, token.KeywordsExpected(kind.String()), keywordKinds...)
+	}
+
+	return left, nil
+}
+
+// parseAnd parses clauses joined by AND, a NOT between two clauses(`a NOT b`) binds as tight as AND.
+func (p *defaultParser) parseAnd() (ast.Expr, error) {
+	left, err := p.parseClause()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var stray bool
+		if left, stray = p.recoverStray(left); stray {
+			continue
+		}
+
+		kind := p.lexer.Token.Kind
+		if kind != token.TokenKindKeywordAnd && kind != token.TokenKindKeywordNot {
+			return left, nil
+		}
+
+		if err := p.checkStrictNot(kind); err != nil {
+			return nil, err
+		}
+
+		if err := p.lexer.nextToken(); err != nil {
+			return nil, err
+		}
+
+		right, err := p.parseClause()
+		if err != nil {
+			return nil, err
+		}
+
+		left = ast.NewCombineExpr(left, kind, right)
+	}
+}
+
+// parseCombine combines clauses strictly from left to right, see PrecedenceLeftToRight.
 func (p *defaultParser) parseCombine(left ast.Expr) (ast.Expr, error) {
+	if left, stray := p.recoverStray(left); stray {
+		return p.parseCombine(left)
+	}
+
 	kind := p.lexer.Token.Kind
-	if kind == token.TokenKindEof || kind == token.TokenKindRparen {
+	if isExprEnd(kind) {
 		return left, nil
 	}
 
 	if !kind.IsKeyword() && kind != token.TokenKindKeywordNot {
-		return nil, token.KeywordsExpected(p.lexer.Token.Kind.String())
+		return nil, expectError(ErrorCodeUnexpectedToken, token.KeywordsExpected(kind.String()), keywordKinds...)
+	}
+
+	if err := p.checkStrictNot(kind); err != nil {
+		return nil, err
 	}
 
 	if err := p.lexer.nextToken(); err != nil {
 		return nil, err
 	}
 
-	right, err := p.parseBinary()
+	right, err := p.parseClause()
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +292,18 @@ func (p *defaultParser) parseCombine(left ast.Expr) (ast.Expr, error) {
 	})
 }
 
+// parseClause parses a clause, which is parsed as an ast.BadExpr if it has errors in recovery mode.
+func (p *defaultParser) parseClause() (ast.Expr, error) {
+	pos := p.lexer.Token.Pos
+
+	expr, err := p.parseBinary()
+	if err != nil {
+		return p.recoverClause(pos, err)
+	}
+
+	return expr, nil
+}
+
 func (p *defaultParser) parseBinary() (ast.Expr, error) {
 	pos, hasNot := 0, false
 
@@ -108,27 +327,143 @@ func (p *defaultParser) parseBinary() (ast.Expr, error) {
 
 	op := p.lexer.Token.Kind
 	if !op.IsOperator() || !p.lexer.lastTokenKind.IsField() {
-		return ast.NewBinaryExpr(pos, "", 0, expr, hasNot), nil
+		return ast.NewBinaryExpr(pos, nil, 0, expr, hasNot), nil
 	}
 
 	if err := p.lexer.nextToken(); err != nil {
 		return nil, err
 	}
 
+	if op == token.TokenKindOperatorEql && p.lexer.Token.Kind == token.TokenKindLbrace {
+		return p.parseNested(pos, expr, hasNot)
+	}
+
 	right, err := p.parseLiteral()
 	if err != nil {
 		return nil, err
 	}
 
-	// check >=, >, <=, < operator must be followed by Int|Float|Ident
 	switch op {
 	case token.TokenKindOperatorGeq, token.TokenKindOperatorGtr, token.TokenKindOperatorLeq, token.TokenKindOperatorLss:
-		if n := strings.ReplaceAll(right.String(), token.TokenKindWildcard.String(), ""); n != "" && !token.IsNumber(n) {
-			return nil, fmt.Errorf("expected number or number with wildcard, but got %q", n)
+		value, err := p.parseRangeValue(right)
+		if err != nil {
+			return nil, p.errorAt(right, err)
 		}
+
+		right = value
+	}
+
+	if op == token.TokenKindOperatorEql && isBareWildcard(right) { // `field: *` means the field exists
+		return ast.NewExistsExpr(pos, right.End(), expr, hasNot), nil
 	}
 
-	return ast.NewBinaryExpr(pos, expr.String(), op, right, hasNot), nil
+	p.coerce(expr, right)
+
+	if paren, ok := right.(*ast.ParenExpr); ok && isValueList(paren.Expr) {
+		return ast.NewValueListExpr(pos, expr, op, paren.L, paren.R, paren.Expr, hasNot), nil
+	}
+
+	return ast.NewBinaryExpr(pos, expr, op, right, hasNot), nil
+}
+
+// isValueList reports whether expr in the parentheses after a field is a list of values, e.g. `a OR NOT (b AND c)`
+// of `f: (a OR NOT (b AND c))`, which has no field query and no source that can not be parsed(*ast.BadExpr).
+func isValueList(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.CombineExpr:
+		return isValueList(e.LeftExpr) && isValueList(e.RightExpr)
+	case *ast.BinaryExpr:
+		if e.Field != nil {
+			return false
+		}
+
+		if paren, ok := e.Value.(*ast.ParenExpr); ok {
+			return isValueList(paren.Expr)
+		}
+
+		switch e.Value.(type) {
+		case *ast.Literal, *ast.WildcardExpr:
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRangeValue checks the value of a range operator(>=, >, <=, <), which is a number, a number with wildcard
+// (except in strict mode), or a date or a date math value that is parsed as an *ast.DateExpr.
+// In RangeModeKibana any value is accepted.
+func (p *defaultParser) parseRangeValue(value ast.Expr) (ast.Expr, error) {
+	kibana := p.opts.rangeMode == RangeModeKibana
+
+	if n := strings.ReplaceAll(value.String(), token.TokenKindWildcard.String(), ""); n != "" && !token.IsNumber(n) {
+		switch v := value.(type) {
+		case *ast.Literal:
+			date, err := ast.ParseDateExpr(v)
+
+			switch {
+			case err == nil:
+				return date, nil
+			case kibana:
+				return v, nil
+			case ast.IsDateMath(v.Value):
+				return nil, expectError(ErrorCodeBadDateMath, err)
+			}
+
+			return nil, nonNumericRangeValue(n)
+		case *ast.WildcardExpr:
+			if !kibana {
+				return nil, nonNumericRangeValue(n)
+			}
+		default:
+			return nil, nonNumericRangeValue(n)
+		}
+	}
+
+	if _, ok := value.(*ast.WildcardExpr); ok && p.opts.strict {
+		return nil, errorf(ErrorCodeStrict, "expected number without wildcard in strict mode, but got %q", value.String())
+	}
+
+	return value, nil
+}
+
+func nonNumericRangeValue(value string) error {
+	return expectError(ErrorCodeNonNumericRangeValue,
+		fmt.Errorf("expected number, number with wildcard, date or date math, but got %q", value),
+		token.TokenKindInt, token.TokenKindFloat)
+}
+
+// coerce attaches the values coerced by the TypeResolver to the values(e.g. `a` and `b` of `f: (a OR b)`)
+// of field, a wildcard field has no type.
+func (p *defaultParser) coerce(field, value ast.Expr) {
+	lit, ok := field.(*ast.Literal)
+	if p.opts.types == nil || !ok {
+		return
+	}
+
+	name := p.prefix + lit.Value
+
+	ast.Inspect(value, func(expr ast.Expr) bool {
+		switch e := expr.(type) {
+		case *ast.Literal:
+			if v, ok := p.opts.types.Coerce(name, e); ok {
+				e.Coerced = v
+			}
+
+			return false
+		case *ast.WildcardExpr, *ast.DateExpr:
+			return false
+		}
+
+		return expr != nil
+	})
+}
+
+// isBareWildcard reports whether expr is an unquoted single wildcard.
+func isBareWildcard(expr ast.Expr) bool {
+	wildcard, ok := expr.(*ast.WildcardExpr)
+
+	return ok && wildcard.Kind == token.TokenKindIdent && wildcard.Value == token.TokenKindWildcard.String()
 }
 
 func (p *defaultParser) parseLiteral() (ast.Expr, error) {
@@ -141,19 +476,28 @@ func (p *defaultParser) parseLiteral() (ast.Expr, error) {
 		return p.parseWildcard()
 	}
 
-	return nil, fmt.Errorf("unexpected token: %s", p.lexer.Token.Kind)
+	return nil, expectError(ErrorCodeUnexpectedToken, fmt.Errorf("unexpected token: %s", p.lexer.Token.Kind),
+		token.TokenKindInt, token.TokenKindFloat, token.TokenKindString, token.TokenKindIdent, token.TokenKindLparen)
 }
 
 func (p *defaultParser) parseParen() (ast.Expr, error) {
 	tok := p.lexer.Token
 
-	expr, err := p.parseExpr()
+	expr, err := p.parseNestingExpr()
 	if err != nil {
 		return nil, err
 	}
 
 	if p.lexer.Token.Kind != token.TokenKindRparen {
-		return nil, fmt.Errorf("expected token <Rparen>, but got %q", p.lexer.Token.Kind.String())
+		err := expectError(ErrorCodeUnmatchedParen,
+			fmt.Errorf("expected token <Rparen>, but got %q", p.lexer.Token.Kind.String()), token.TokenKindRparen)
+		if !p.opts.recovery {
+			return nil, err
+		}
+
+		p.addError(err) // keep the parenthesis expression as if it is closed
+
+		return ast.NewParenExpr(tok.Pos, p.lexer.lastTokenEnd, expr), nil
 	}
 
 	rparen := p.lexer.Token.End
@@ -165,6 +509,75 @@ func (p *defaultParser) parseParen() (ast.Expr, error) {
 	return ast.NewParenExpr(tok.Pos, rparen, expr), nil
 }
 
+func (p *defaultParser) parseNested(pos int, path ast.Expr, hasNot bool) (ast.Expr, error) {
+	if _, ok := path.(*ast.WildcardExpr); ok {
+		return nil, errorf(ErrorCodeWildcardNestedPath, "expected nested path without wildcard, but got %q", path.String())
+	}
+
+	lbrace := p.lexer.Token.Pos
+
+	prefix := p.prefix
+	if lit, ok := path.(*ast.Literal); ok {
+		p.prefix += lit.Value + "."
+	}
+
+	expr, err := p.parseNestingExpr()
+	p.prefix = prefix
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.lexer.Token.Kind != token.TokenKindRbrace {
+		err := expectError(ErrorCodeUnmatchedBrace,
+			fmt.Errorf("expected token <Rbrace>, but got %q", p.lexer.Token.Kind.String()), token.TokenKindRbrace)
+		if !p.opts.recovery {
+			return nil, err
+		}
+
+		p.addError(err) // keep the nested field query as if it is closed
+
+		return ast.NewNestedExpr(pos, path, lbrace, p.lexer.lastTokenEnd, expr, hasNot), nil
+	}
+
+	rbrace := p.lexer.Token.End
+
+	if err := p.lexer.nextToken(); err != nil {
+		return nil, err
+	}
+
+	return ast.NewNestedExpr(pos, path, lbrace, rbrace, expr, hasNot), nil
+}
+
+// parseNestingExpr parses the expression inside the parentheses or braces at the current token.
+func (p *defaultParser) parseNestingExpr() (ast.Expr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if maxDepth := p.opts.maxDepth; maxDepth > 0 && p.depth > maxDepth {
+		return nil, errorf(ErrorCodeTooDeep, "expected nesting depth of at most %d, but got %d", maxDepth, p.depth)
+	}
+
+	return p.parseExpr()
+}
+
+// checkStrictNot rejects NOT between two clauses(`a NOT b`) in strict mode.
+func (p *defaultParser) checkStrictNot(kind token.Kind) error {
+	if kind != token.TokenKindKeywordNot || !p.opts.strict {
+		return nil
+	}
+
+	err := expectError(ErrorCodeStrict, errors.New("expected keyword AND|OR before NOT in strict mode"),
+		token.TokenKindKeywordAnd, token.TokenKindKeywordOr)
+	if !p.opts.recovery {
+		return err
+	}
+
+	p.addError(err) // go on with the lenient form
+
+	return nil
+}
+
 func (p *defaultParser) parseWildcard() (ast.Expr, error) {
 	kind := p.lexer.Token.Kind
 
@@ -173,13 +586,7 @@ func (p *defaultParser) parseWildcard() (ast.Expr, error) {
 		return nil, err
 	}
 
-	pos, end := tok.Pos, tok.End
-	if kind == token.TokenKindString { // with double quote "
-		pos -= 1
-		end += 1
-	}
-
-	lit := ast.NewLiteral(pos, end, kind, tok.Value, tok.EscapeIndexes)
+	lit := ast.NewLiteral(tok.Pos, tok.End, kind, tok.Value, tok.EscapeIndexes)
 	if kind != token.TokenKindIdent && kind != token.TokenKindString {
 		return lit, nil
 	}
@@ -202,7 +609,8 @@ func (p *defaultParser) parseWildcard() (ast.Expr, error) {
 
 func (p *defaultParser) expect(kind token.Kind) (*Token, error) {
 	if p.lexer.Token.Kind != kind {
-		return nil, fmt.Errorf("expected token: %s, but: %s", kind, p.lexer.Token.Kind)
+		return nil, expectError(ErrorCodeUnexpectedToken,
+			fmt.Errorf("expected token: %s, but: %s", kind, p.lexer.Token.Kind), kind)
 	}
 
 	t := p.lexer.Token.Clone()
@@ -214,6 +622,16 @@ func (p *defaultParser) expect(kind token.Kind) (*Token, error) {
 	return t, nil
 }
 
+// isExprEnd reports whether kind terminates an expression(<EOF>, ")" or "}").
+func isExprEnd(kind token.Kind) bool {
+	return kind == token.TokenKindEof || kind == token.TokenKindRparen || kind == token.TokenKindRbrace
+}
+
 func (p *defaultParser) toKQLError(err error) error {
-	return kql.NewError(string(p.lexer.Value), p.lexer.lastTokenKind, p.lexer.Token.Value, p.lexer.Token.Pos, err)
+	return spanError(p.lexer.input, p.lexer.Token.Pos, p.lexer.Token.End, err)
+}
+
+// errorAt positions err at expr instead of the current token.
+func (p *defaultParser) errorAt(expr ast.Expr, err error) error {
+	return spanError(p.lexer.input, expr.Pos(), expr.End(), err)
 }
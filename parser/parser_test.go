@@ -3,12 +3,15 @@ package parser_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/laojianzi/kql-go"
 	"github.com/laojianzi/kql-go/ast"
 	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/schema"
 	"github.com/laojianzi/kql-go/token"
 )
 
@@ -20,39 +23,39 @@ func Test_defaultParser_Stmt(t *testing.T) {
 		}{
 			{
 				input: "foo",
-				want:  ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
+				want:  ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
 			},
 			{
 				input: "1",
-				want:  ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 1, token.TokenKindInt, "1", nil), false),
+				want:  ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 1, token.TokenKindInt, "1", nil), false),
 			},
 			{
 				input: "0.1",
-				want:  ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 3, token.TokenKindFloat, "0.1", nil), false),
+				want:  ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 3, token.TokenKindFloat, "0.1", nil), false),
 			},
 			{
 				input: `"0.1"`,
-				want:  ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 5, token.TokenKindString, "0.1", nil), false),
+				want:  ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 5, token.TokenKindString, "0.1", nil), false),
 			},
 			{
 				input: `f1: "v1"`,
-				want:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
 			},
 			{
 				input: `f1 > 1`,
-				want:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorGtr, ast.NewLiteral(5, 6, token.TokenKindInt, "1", nil), false),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorGtr, ast.NewLiteral(5, 6, token.TokenKindInt, "1", nil), false),
 			},
 			{
 				input: `f1 < 1.1`,
-				want:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorLss, ast.NewLiteral(5, 8, token.TokenKindFloat, "1.1", nil), false),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorLss, ast.NewLiteral(5, 8, token.TokenKindFloat, "1.1", nil), false),
 			},
 			{
 				input: `f1 >= 1000.0001`,
-				want:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorGeq, ast.NewLiteral(6, 15, token.TokenKindFloat, "1000.0001", nil), false),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorGeq, ast.NewLiteral(6, 15, token.TokenKindFloat, "1000.0001", nil), false),
 			},
 			{
 				input: `f1 <= 100000011`,
-				want:  ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorLeq, ast.NewLiteral(6, 15, token.TokenKindInt, "100000011", nil), false),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorLeq, ast.NewLiteral(6, 15, token.TokenKindInt, "100000011", nil), false),
 			},
 		}
 
@@ -73,22 +76,22 @@ func Test_defaultParser_Stmt(t *testing.T) {
 		}{
 			{
 				input: "NOT bar",
-				want:  ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(4, 7, token.TokenKindIdent, "bar", nil), true),
+				want:  ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(4, 7, token.TokenKindIdent, "bar", nil), true),
 			},
 			{
 				input: "foo AND bar",
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
+					ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(8, "", 0, ast.NewLiteral(8, 11, token.TokenKindIdent, "bar", nil), false),
+					ast.NewBinaryExpr(8, nil, 0, ast.NewLiteral(8, 11, token.TokenKindIdent, "bar", nil), false),
 				),
 			},
 			{
 				input: "foo AND NOT bar",
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
+					ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(8, "", 0, ast.NewLiteral(12, 15, token.TokenKindIdent, "bar", nil), true),
+					ast.NewBinaryExpr(8, nil, 0, ast.NewLiteral(12, 15, token.TokenKindIdent, "bar", nil), true),
 				),
 			},
 			{
@@ -96,39 +99,39 @@ func Test_defaultParser_Stmt(t *testing.T) {
 				want: ast.NewCombineExpr(
 					ast.NewCombineExpr(
 						ast.NewCombineExpr(
-							ast.NewCombineExpr(
-								ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 2, token.TokenKindIdent, "v1", nil), false),
-								token.TokenKindKeywordAnd,
-								ast.NewBinaryExpr(7, "", 0, ast.NewLiteral(7, 8, token.TokenKindInt, "2", nil), false),
-							),
-							token.TokenKindKeywordOr,
-							ast.NewBinaryExpr(12, "", 0, ast.NewLiteral(12, 15, token.TokenKindFloat, "0.3", nil), false),
+							ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 2, token.TokenKindIdent, "v1", nil), false),
+							token.TokenKindKeywordAnd,
+							ast.NewBinaryExpr(7, nil, 0, ast.NewLiteral(7, 8, token.TokenKindInt, "2", nil), false),
+						),
+						token.TokenKindKeywordOr,
+						ast.NewCombineExpr(
+							ast.NewBinaryExpr(12, nil, 0, ast.NewLiteral(12, 15, token.TokenKindFloat, "0.3", nil), false),
+							token.TokenKindKeywordAnd,
+							ast.NewBinaryExpr(20, nil, 0, ast.NewLiteral(24, 28, token.TokenKindString, "v4", nil), true),
 						),
-						token.TokenKindKeywordAnd,
-						ast.NewBinaryExpr(20, "", 0, ast.NewLiteral(24, 28, token.TokenKindString, "v4", nil), true),
 					),
 					token.TokenKindKeywordOr,
-					ast.NewBinaryExpr(32, "", 0, ast.NewLiteral(36, 39, token.TokenKindFloat, "5.0", nil), true),
+					ast.NewBinaryExpr(32, nil, 0, ast.NewLiteral(36, 39, token.TokenKindFloat, "5.0", nil), true),
 				),
 			},
 			{
 				input: "NOT f: v",
-				want:  ast.NewBinaryExpr(0, "f", token.TokenKindOperatorEql, ast.NewLiteral(7, 8, token.TokenKindIdent, "v", nil), true),
+				want:  ast.NewBinaryExpr(0, ast.NewLiteral(4, 5, token.TokenKindIdent, "f", nil), token.TokenKindOperatorEql, ast.NewLiteral(7, 8, token.TokenKindIdent, "v", nil), true),
 			},
 			{
 				input: `f1: "v1" AND f2 > 2`,
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+					ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(13, "f2", token.TokenKindOperatorGtr, ast.NewLiteral(18, 19, token.TokenKindInt, "2", nil), false),
+					ast.NewBinaryExpr(13, ast.NewLiteral(13, 15, token.TokenKindIdent, "f2", nil), token.TokenKindOperatorGtr, ast.NewLiteral(18, 19, token.TokenKindInt, "2", nil), false),
 				),
 			},
 			{
 				input: `f1: "v1" AND NOT f2 > 2`,
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+					ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(13, "f2", token.TokenKindOperatorGtr, ast.NewLiteral(22, 23, token.TokenKindInt, "2", nil), true),
+					ast.NewBinaryExpr(13, ast.NewLiteral(17, 19, token.TokenKindIdent, "f2", nil), token.TokenKindOperatorGtr, ast.NewLiteral(22, 23, token.TokenKindInt, "2", nil), true),
 				),
 			},
 			{
@@ -136,19 +139,19 @@ func Test_defaultParser_Stmt(t *testing.T) {
 				want: ast.NewCombineExpr(
 					ast.NewCombineExpr(
 						ast.NewCombineExpr(
-							ast.NewCombineExpr(
-								ast.NewBinaryExpr(0, "f1", token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
-								token.TokenKindKeywordAnd,
-								ast.NewBinaryExpr(13, "f2", token.TokenKindOperatorGtr, ast.NewLiteral(18, 19, token.TokenKindInt, "2", nil), false),
-							),
-							token.TokenKindKeywordOr,
-							ast.NewBinaryExpr(23, "f3", token.TokenKindOperatorLss, ast.NewLiteral(28, 31, token.TokenKindFloat, "0.3", nil), false),
+							ast.NewBinaryExpr(0, ast.NewLiteral(0, 2, token.TokenKindIdent, "f1", nil), token.TokenKindOperatorEql, ast.NewLiteral(4, 8, token.TokenKindString, "v1", nil), false),
+							token.TokenKindKeywordAnd,
+							ast.NewBinaryExpr(13, ast.NewLiteral(13, 15, token.TokenKindIdent, "f2", nil), token.TokenKindOperatorGtr, ast.NewLiteral(18, 19, token.TokenKindInt, "2", nil), false),
+						),
+						token.TokenKindKeywordOr,
+						ast.NewCombineExpr(
+							ast.NewBinaryExpr(23, ast.NewLiteral(23, 25, token.TokenKindIdent, "f3", nil), token.TokenKindOperatorLss, ast.NewLiteral(28, 31, token.TokenKindFloat, "0.3", nil), false),
+							token.TokenKindKeywordAnd,
+							ast.NewBinaryExpr(36, ast.NewLiteral(40, 42, token.TokenKindIdent, "f4", nil), token.TokenKindOperatorGeq, ast.NewLiteral(46, 47, token.TokenKindInt, "4", nil), true),
 						),
-						token.TokenKindKeywordAnd,
-						ast.NewBinaryExpr(36, "f4", token.TokenKindOperatorGeq, ast.NewLiteral(46, 47, token.TokenKindInt, "4", nil), true),
 					),
 					token.TokenKindKeywordOr,
-					ast.NewBinaryExpr(51, "f5", token.TokenKindOperatorLeq, ast.NewLiteral(61, 64, token.TokenKindFloat, "5.0", nil), true),
+					ast.NewBinaryExpr(51, ast.NewLiteral(55, 57, token.TokenKindIdent, "f5", nil), token.TokenKindOperatorLeq, ast.NewLiteral(61, 64, token.TokenKindFloat, "5.0", nil), true),
 				),
 			},
 		}
@@ -171,9 +174,9 @@ func Test_defaultParser_Stmt(t *testing.T) {
 			{
 				input: "foo AND (NOT bar)",
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "", 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
+					ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(8, "", 0, ast.NewParenExpr(8, 17, ast.NewBinaryExpr(9, "", 0, ast.NewLiteral(13, 16, token.TokenKindIdent, "bar", nil), true)), false),
+					ast.NewBinaryExpr(8, nil, 0, ast.NewParenExpr(8, 17, ast.NewBinaryExpr(9, nil, 0, ast.NewLiteral(13, 16, token.TokenKindIdent, "bar", nil), true)), false),
 				),
 			},
 		}
@@ -195,20 +198,32 @@ func Test_defaultParser_Stmt(t *testing.T) {
 		}{
 			{
 				input: "foo: *",
-				want: ast.NewBinaryExpr(0, "foo", token.TokenKindOperatorEql, ast.NewWildcardExpr(
-					ast.NewLiteral(5, 6, token.TokenKindIdent, "*", nil),
+				want:  ast.NewExistsExpr(0, 6, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
+			},
+			{
+				input: "NOT foo: *",
+				want:  ast.NewExistsExpr(0, 10, ast.NewLiteral(4, 7, token.TokenKindIdent, "foo", nil), true),
+			},
+			{
+				input: `foo: "*"`,
+				want: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), token.TokenKindOperatorEql, ast.NewWildcardExpr(
+					ast.NewLiteral(5, 8, token.TokenKindString, "*", nil),
+					[]int{1},
+				), false),
+			},
+			{
+				input: "*",
+				want: ast.NewBinaryExpr(0, nil, 0, ast.NewWildcardExpr(
+					ast.NewLiteral(0, 1, token.TokenKindIdent, "*", nil),
 					[]int{0},
 				), false),
 			},
 			{
 				input: `foo: * AND bar: *v2`,
 				want: ast.NewCombineExpr(
-					ast.NewBinaryExpr(0, "foo", token.TokenKindOperatorEql, ast.NewWildcardExpr(
-						ast.NewLiteral(5, 6, token.TokenKindIdent, "*", nil),
-						[]int{0},
-					), false),
+					ast.NewExistsExpr(0, 6, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), false),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(11, "bar", token.TokenKindOperatorEql, ast.NewWildcardExpr(
+					ast.NewBinaryExpr(11, ast.NewLiteral(11, 14, token.TokenKindIdent, "bar", nil), token.TokenKindOperatorEql, ast.NewWildcardExpr(
 						ast.NewLiteral(16, 19, token.TokenKindIdent, "*v2", nil),
 						[]int{0},
 					), false),
@@ -216,7 +231,7 @@ func Test_defaultParser_Stmt(t *testing.T) {
 			},
 			{
 				input: "foo: v*1",
-				want: ast.NewBinaryExpr(0, "foo", token.TokenKindOperatorEql, ast.NewWildcardExpr(
+				want: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), token.TokenKindOperatorEql, ast.NewWildcardExpr(
 					ast.NewLiteral(5, 8, token.TokenKindIdent, "v*1", nil),
 					[]int{1},
 				), false),
@@ -225,23 +240,80 @@ func Test_defaultParser_Stmt(t *testing.T) {
 				input: "foo: *0 AND bar: 1* AND 2*0",
 				want: ast.NewCombineExpr(
 					ast.NewCombineExpr(
-						ast.NewBinaryExpr(0, "foo", token.TokenKindOperatorEql, ast.NewWildcardExpr(
+						ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "foo", nil), token.TokenKindOperatorEql, ast.NewWildcardExpr(
 							ast.NewLiteral(5, 7, token.TokenKindIdent, "*0", nil),
 							[]int{0},
 						), false),
 						token.TokenKindKeywordAnd,
-						ast.NewBinaryExpr(12, "bar", token.TokenKindOperatorEql, ast.NewWildcardExpr(
+						ast.NewBinaryExpr(12, ast.NewLiteral(12, 15, token.TokenKindIdent, "bar", nil), token.TokenKindOperatorEql, ast.NewWildcardExpr(
 							ast.NewLiteral(17, 19, token.TokenKindIdent, "1*", nil),
 							[]int{1},
 						), false),
 					),
 					token.TokenKindKeywordAnd,
-					ast.NewBinaryExpr(24, "", 0, ast.NewWildcardExpr(
+					ast.NewBinaryExpr(24, nil, 0, ast.NewWildcardExpr(
 						ast.NewLiteral(24, 27, token.TokenKindIdent, "2*0", nil),
 						[]int{1},
 					), false),
 				),
 			},
+			{
+				input: "machine.os*: windows",
+				want: ast.NewBinaryExpr(0, ast.NewWildcardExpr(
+					ast.NewLiteral(0, 11, token.TokenKindIdent, "machine.os*", nil),
+					[]int{10},
+				), token.TokenKindOperatorEql, ast.NewLiteral(13, 20, token.TokenKindIdent, "windows", nil), false),
+			},
+			{
+				input: "NOT labels.*: *",
+				want: ast.NewExistsExpr(0, 15, ast.NewWildcardExpr(
+					ast.NewLiteral(4, 12, token.TokenKindIdent, "labels.*", nil),
+					[]int{7},
+				), true),
+			},
+		}
+
+		for _, c := range cases {
+			t.Run(c.input, func(t *testing.T) {
+				stmt, err := parser.New(c.input).Stmt()
+				assert.NoError(t, err)
+				assert.EqualValues(t, c.want, stmt)
+				assert.Equal(t, c.input, stmt.String())
+			})
+		}
+	})
+
+	t.Run("with nested", func(t *testing.T) {
+		cases := []struct {
+			input string
+			want  ast.Expr
+		}{
+			{
+				input: `items: { name: "x" AND qty > 2 }`,
+				want: ast.NewNestedExpr(0, ast.NewLiteral(0, 5, token.TokenKindIdent, "items", nil), 7, 32, ast.NewCombineExpr(
+					ast.NewBinaryExpr(9, ast.NewLiteral(9, 13, token.TokenKindIdent, "name", nil), token.TokenKindOperatorEql, ast.NewLiteral(15, 18, token.TokenKindString, "x", nil), false),
+					token.TokenKindKeywordAnd,
+					ast.NewBinaryExpr(23, ast.NewLiteral(23, 26, token.TokenKindIdent, "qty", nil), token.TokenKindOperatorGtr, ast.NewLiteral(29, 30, token.TokenKindInt, "2", nil), false),
+				), false),
+			},
+			{
+				input: `NOT items: { name: x } OR id: 1`,
+				want: ast.NewCombineExpr(
+					ast.NewNestedExpr(0, ast.NewLiteral(4, 9, token.TokenKindIdent, "items", nil), 11, 22, ast.NewBinaryExpr(
+						13, ast.NewLiteral(13, 17, token.TokenKindIdent, "name", nil), token.TokenKindOperatorEql, ast.NewLiteral(19, 20, token.TokenKindIdent, "x", nil), false,
+					), true),
+					token.TokenKindKeywordOr,
+					ast.NewBinaryExpr(26, ast.NewLiteral(26, 28, token.TokenKindIdent, "id", nil), token.TokenKindOperatorEql, ast.NewLiteral(30, 31, token.TokenKindInt, "1", nil), false),
+				),
+			},
+			{
+				input: `orders: { items: { sku: 1 } }`,
+				want: ast.NewNestedExpr(0, ast.NewLiteral(0, 6, token.TokenKindIdent, "orders", nil), 8, 29, ast.NewNestedExpr(
+					10, ast.NewLiteral(10, 15, token.TokenKindIdent, "items", nil), 17, 27, ast.NewBinaryExpr(
+						19, ast.NewLiteral(19, 22, token.TokenKindIdent, "sku", nil), token.TokenKindOperatorEql, ast.NewLiteral(24, 25, token.TokenKindInt, "1", nil), false,
+					), false,
+				), false),
+			},
 		}
 
 		for _, c := range cases {
@@ -272,10 +344,10 @@ func Test_defaultParser_Stmt(t *testing.T) {
 			{
 				input: "foo: ",
 				want: kql.NewError(
-					"foo:",
+					"foo: ",
 					token.TokenKindEof,
 					"",
-					4,
+					5,
 					errors.New("unexpected token: Eof"),
 				),
 			},
@@ -291,6 +363,459 @@ func Test_defaultParser_Stmt(t *testing.T) {
 	})
 }
 
+func TestParser_Precedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		precedence parser.Precedence
+		want       ast.Expr
+		wantStr    string
+	}{
+		{
+			name:  "AND binds tighter than OR",
+			input: "a OR b AND c",
+			want: ast.NewCombineExpr(
+				ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 1, token.TokenKindIdent, "a", nil), false),
+				token.TokenKindKeywordOr,
+				ast.NewCombineExpr(
+					ast.NewBinaryExpr(5, nil, 0, ast.NewLiteral(5, 6, token.TokenKindIdent, "b", nil), false),
+					token.TokenKindKeywordAnd,
+					ast.NewBinaryExpr(11, nil, 0, ast.NewLiteral(11, 12, token.TokenKindIdent, "c", nil), false),
+				),
+			),
+			wantStr: "a OR b AND c",
+		},
+		{
+			name:  "NOT between clauses binds as AND",
+			input: "a OR b NOT c",
+			want: ast.NewCombineExpr(
+				ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 1, token.TokenKindIdent, "a", nil), false),
+				token.TokenKindKeywordOr,
+				ast.NewCombineExpr(
+					ast.NewBinaryExpr(5, nil, 0, ast.NewLiteral(5, 6, token.TokenKindIdent, "b", nil), false),
+					token.TokenKindKeywordNot,
+					ast.NewBinaryExpr(11, nil, 0, ast.NewLiteral(11, 12, token.TokenKindIdent, "c", nil), false),
+				),
+			),
+			wantStr: "a OR b NOT c",
+		},
+		{
+			name:       "legacy left to right",
+			input:      "a OR b AND c",
+			precedence: parser.PrecedenceLeftToRight,
+			want: ast.NewCombineExpr(
+				ast.NewCombineExpr(
+					ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 1, token.TokenKindIdent, "a", nil), false),
+					token.TokenKindKeywordOr,
+					ast.NewBinaryExpr(5, nil, 0, ast.NewLiteral(5, 6, token.TokenKindIdent, "b", nil), false),
+				),
+				token.TokenKindKeywordAnd,
+				ast.NewBinaryExpr(11, nil, 0, ast.NewLiteral(11, 12, token.TokenKindIdent, "c", nil), false),
+			),
+			wantStr: "(a OR b) AND c",
+		},
+		{
+			name:       "legacy left to right keeps AND first",
+			input:      "a AND b OR c",
+			precedence: parser.PrecedenceLeftToRight,
+			want: ast.NewCombineExpr(
+				ast.NewCombineExpr(
+					ast.NewBinaryExpr(0, nil, 0, ast.NewLiteral(0, 1, token.TokenKindIdent, "a", nil), false),
+					token.TokenKindKeywordAnd,
+					ast.NewBinaryExpr(6, nil, 0, ast.NewLiteral(6, 7, token.TokenKindIdent, "b", nil), false),
+				),
+				token.TokenKindKeywordOr,
+				ast.NewBinaryExpr(11, nil, 0, ast.NewLiteral(11, 12, token.TokenKindIdent, "c", nil), false),
+			),
+			wantStr: "a AND b OR c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, err := parser.New(tt.input, parser.WithPrecedence(tt.precedence)).Stmt()
+			assert.NoError(t, err)
+			assert.EqualValues(t, tt.want, stmt)
+			assert.Equal(t, tt.wantStr, stmt.String())
+
+			// the string representation must be parsed back to the same tree
+			stmt2, err := parser.New(stmt.String()).Stmt()
+			assert.NoError(t, err)
+			assert.Equal(t, stmt.String(), stmt2.String())
+		})
+	}
+}
+
+func TestParser_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    []parser.Option
+		wantErr string
+	}{
+		{name: "within max length", input: "a: 1", opts: []parser.Option{parser.WithMaxLength(4)}},
+		{
+			name:    "exceeds max length",
+			input:   "a: 12",
+			opts:    []parser.Option{parser.WithMaxLength(4)},
+			wantErr: "line 0:4 expected at most 4 characters, but got 5\na: 12\n    ^\n",
+		},
+		{name: "within max depth", input: "(a: 1 AND items: { b: 2 })", opts: []parser.Option{parser.WithMaxDepth(2)}},
+		{
+			name:    "exceeds max depth",
+			input:   "(a: 1 AND items: { b: (2 OR 3) })",
+			opts:    []parser.Option{parser.WithMaxDepth(2)},
+			wantErr: "line 0:22 expected nesting depth of at most 2, but got 3\n(a: 1 AND items: { b: (2 OR 3) })\n                      ^\n",
+		},
+		{name: "lenient NOT between clauses", input: "a NOT b"},
+		{
+			name:    "strict NOT between clauses",
+			input:   "a NOT b",
+			opts:    []parser.Option{parser.WithStrict(true)},
+			wantErr: "line 0:2 expected keyword AND|OR before NOT in strict mode\na NOT b\n  ^^^\n",
+		},
+		{
+			name:    "strict NOT between clauses from left to right",
+			input:   "a NOT b",
+			opts:    []parser.Option{parser.WithStrict(true), parser.WithPrecedence(parser.PrecedenceLeftToRight)},
+			wantErr: "line 0:2 expected keyword AND|OR before NOT in strict mode\na NOT b\n  ^^^\n",
+		},
+		{name: "strict AND NOT", input: "a AND NOT b", opts: []parser.Option{parser.WithStrict(true)}},
+		{name: "lenient wildcard in range", input: "age > 1*"},
+		{
+			name:    "strict wildcard in range",
+			input:   "age > 1*",
+			opts:    []parser.Option{parser.WithStrict(true)},
+			wantErr: "line 0:6 expected number without wildcard in strict mode, but got \"1*\"\nage > 1*\n      ^^\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.New(tt.input, tt.opts...).Stmt()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParser_DateRange(t *testing.T) {
+	tests := []struct {
+		input  string
+		anchor string
+	}{
+		{input: `@timestamp >= now-7d/d`, anchor: "now"},
+		{input: `@timestamp < now`, anchor: "now"},
+		{input: `@timestamp > "2024-01-01"`, anchor: "2024-01-01"},
+		{input: `@timestamp <= "2024-01-01T08:00:00+08:00||+1M/d"`, anchor: "2024-01-01T08:00:00+08:00"},
+		{input: `NOT t > "now-15m"`, anchor: "now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := parser.New(tt.input).Stmt()
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, expr.String())
+
+			date, ok := expr.(*ast.BinaryExpr).Value.(*ast.DateExpr)
+			require.True(t, ok)
+			assert.Equal(t, tt.anchor, date.Anchor)
+		})
+	}
+
+	expr, err := parser.New(`t: now-1d`).Stmt()
+	require.NoError(t, err)
+	assert.IsType(t, &ast.Literal{}, expr.(*ast.BinaryExpr).Value, "only the range values are dates")
+
+	errs := []struct {
+		input string
+		code  parser.ErrorCode
+		want  string
+	}{
+		{input: `t > now-1x`, code: parser.ErrorCodeBadDateMath, want: `invalid date math now-1x: unknown date unit "x"`},
+		{input: `t > "2024-01-01||+"`, code: parser.ErrorCodeBadDateMath, want: `expected unit at the end`},
+		{input: `t > yesterday`, code: parser.ErrorCodeNonNumericRangeValue, want: `date or date math, but got "yesterday"`},
+		{input: `t > "2024-13-01"`, code: parser.ErrorCodeNonNumericRangeValue, want: `but got "\"2024-13-01\""`},
+		{input: `t > now*`, code: parser.ErrorCodeNonNumericRangeValue, want: `but got "now"`},
+	}
+
+	for _, tt := range errs {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parser.New(tt.input).Stmt()
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tt.code), "%v", err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestParser_RangeMode(t *testing.T) {
+	tests := []struct {
+		input string
+		value ast.Expr
+	}{
+		{input: `host.ip > 10.0.0.0`, value: ast.NewLiteral(10, 18, token.TokenKindIdent, "10.0.0.0", nil)},
+		{input: `host.ip <= "::1"`, value: ast.NewLiteral(11, 16, token.TokenKindString, "::1", nil)},
+		{input: `version >= "1.2.3"`, value: ast.NewLiteral(11, 18, token.TokenKindString, "1.2.3", nil)},
+		{input: `name > nowhere`, value: ast.NewLiteral(7, 14, token.TokenKindIdent, "nowhere", nil)},
+		{input: `t > now-1x`, value: ast.NewLiteral(4, 10, token.TokenKindIdent, "now-1x", nil)},
+		{input: `name < b*`, value: ast.NewWildcardExpr(ast.NewLiteral(7, 9, token.TokenKindIdent, "b*", nil), []int{1})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parser.New(tt.input).Stmt()
+			assert.True(t, errors.Is(err, parser.ErrorCodeNonNumericRangeValue) || errors.Is(err, parser.ErrorCodeBadDateMath),
+				"%v", err)
+
+			expr, err := parser.New(tt.input, parser.WithRangeMode(parser.RangeModeKibana)).Stmt()
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, expr.String())
+			assert.Equal(t, tt.value, expr.(*ast.BinaryExpr).Value)
+		})
+	}
+
+	expr, err := parser.New(`t >= now-7d/d AND n > 1`, parser.WithRangeMode(parser.RangeModeKibana)).Stmt()
+	require.NoError(t, err)
+	assert.IsType(t, &ast.DateExpr{}, expr.(*ast.CombineExpr).LeftExpr.(*ast.BinaryExpr).Value)
+	assert.IsType(t, &ast.Literal{}, expr.(*ast.CombineExpr).RightExpr.(*ast.BinaryExpr).Value)
+
+	_, err = parser.New(`name < b*`, parser.WithRangeMode(parser.RangeModeKibana), parser.WithStrict(true)).Stmt()
+	assert.True(t, errors.Is(err, parser.ErrorCodeStrict), "%v", err)
+}
+
+func TestParser_ValueList(t *testing.T) {
+	expr, err := parser.New(`NOT level: (error OR NOT (warn AND w*))`).Stmt()
+	require.NoError(t, err)
+	assert.Equal(t, ast.NewValueListExpr(0, ast.NewLiteral(4, 9, token.TokenKindIdent, "level", nil),
+		token.TokenKindOperatorEql, 11, 39, ast.NewCombineExpr(
+			ast.NewBinaryExpr(12, nil, 0, ast.NewLiteral(12, 17, token.TokenKindIdent, "error", nil), false),
+			token.TokenKindKeywordOr,
+			ast.NewBinaryExpr(21, nil, 0, ast.NewParenExpr(25, 38, ast.NewCombineExpr(
+				ast.NewBinaryExpr(26, nil, 0, ast.NewLiteral(26, 30, token.TokenKindIdent, "warn", nil), false),
+				token.TokenKindKeywordAnd,
+				ast.NewBinaryExpr(35, nil, 0, ast.NewWildcardExpr(
+					ast.NewLiteral(35, 37, token.TokenKindIdent, "w*", nil), []int{1}), false),
+			)), true),
+		), true), expr)
+
+	// the parentheses with field queries are not a list of values
+	for _, input := range []string{`f: (g: x)`, `f: (a OR g: *)`, `f: (a OR g: { x })`} {
+		expr, err := parser.New(input).Stmt()
+		require.NoError(t, err)
+		assert.IsType(t, &ast.BinaryExpr{}, expr, input)
+		assert.Equal(t, input, expr.String())
+	}
+
+	expr, err = parser.New(`f: (a OR ) AND g: (b)`, parser.WithRecovery(true)).Stmt()
+	require.Error(t, err)
+	assert.IsType(t, &ast.BinaryExpr{}, expr.(*ast.CombineExpr).LeftExpr, "a list with errors")
+	assert.IsType(t, &ast.ValueListExpr{}, expr.(*ast.CombineExpr).RightExpr)
+}
+
+func TestParser_TypeResolver(t *testing.T) {
+	types := schema.Schema{
+		"code":       schema.TypeLong,
+		"status":     schema.TypeKeyword,
+		"items":      schema.TypeNested,
+		"items.qty":  schema.TypeLong,
+		"qty":        schema.TypeDouble,
+		"@timestamp": schema.TypeDate,
+	}
+
+	expr, err := parser.New(`code: (200 OR "404" OR 5*) AND code > 1 AND status: \*a AND c*: 1 AND code: x AND `+
+		`items: { qty: 3 } AND qty: 3 AND other: 1 AND @timestamp: 2024`, parser.WithTypeResolver(types)).Stmt()
+	require.NoError(t, err)
+
+	var got []interface{}
+
+	ast.Inspect(expr, func(e ast.Expr) bool {
+		if lit, ok := e.(*ast.Literal); ok {
+			got = append(got, lit.Coerced)
+		}
+
+		return e != nil
+	})
+
+	// the fields are not coerced, neither are the wildcard values(`5*`) and the values of wildcard fields(`c*`)
+	assert.Equal(t, []interface{}{
+		nil, int64(200), int64(404),
+		nil, int64(1),
+		nil, "*a",
+		nil,
+		nil, nil,
+		nil, nil, int64(3),
+		nil, float64(3),
+		nil, nil,
+		nil, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), // a year, not milliseconds since the Unix epoch
+	}, got)
+
+	expr, err = parser.New(`code: 200`).Stmt()
+	require.NoError(t, err)
+	assert.Nil(t, expr.(*ast.BinaryExpr).Value.(*ast.Literal).Coerced)
+}
+
+func TestParser_Recovery(t *testing.T) {
+	type wantError struct {
+		code   parser.ErrorCode
+		offset int
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		opts       []parser.Option
+		wantString string
+		wantBad    []string
+		wantErrors []wantError
+	}{
+		{name: "no errors", input: "a: 1 AND b: 2", wantString: "a: 1 AND b: 2"},
+		{
+			name:       "missing value",
+			input:      "foo: AND bar: baz",
+			wantString: "foo: AND bar: baz",
+			wantBad:    []string{"foo:"},
+			wantErrors: []wantError{{parser.ErrorCodeUnexpectedToken, 5}},
+		},
+		{
+			name:       "missing keyword",
+			input:      "foo bar AND x: 1 y",
+			wantString: "foo bar AND x: 1 y",
+			wantBad:    []string{"foo bar", "x: 1 y"},
+			wantErrors: []wantError{{parser.ErrorCodeUnexpectedToken, 4}, {parser.ErrorCodeUnexpectedToken, 17}},
+		},
+		{
+			name:       "errors in several clauses",
+			input:      `x: b\ar AND y: 1. OR z: ok AND w > v`,
+			wantString: `x: b\ar AND y: 1. OR z: ok AND w > v`,
+			wantBad:    []string{`x: b\ar`, "y: 1.", "w > v"},
+			wantErrors: []wantError{
+				{parser.ErrorCodeBadEscape, 3},
+				{parser.ErrorCodeBadNumber, 15},
+				{parser.ErrorCodeNonNumericRangeValue, 35},
+			},
+		},
+		{
+			name:       "unclosed string and parenthesis",
+			input:      `(a: 1 OR b: "x) AND c: 2`,
+			wantString: `(a: 1 OR b: "x) AND c: 2)`,
+			wantBad:    []string{`b: "x) AND c: 2`},
+			wantErrors: []wantError{{parser.ErrorCodeUnclosedString, 12}, {parser.ErrorCodeUnmatchedParen, 24}},
+		},
+		{
+			name:       "unmatched right parenthesis",
+			input:      "a: 1) AND b: 2",
+			wantString: "a: 1) AND b: 2",
+			wantBad:    []string{"a: 1)"},
+			wantErrors: []wantError{{parser.ErrorCodeUnmatchedParen, 4}},
+		},
+		{
+			name:       "nested field query",
+			input:      "items: { a: 1 AND b: } AND c: 1",
+			wantString: "items: { a: 1 AND b: } AND c: 1",
+			wantBad:    []string{"b:"},
+			wantErrors: []wantError{{parser.ErrorCodeUnexpectedToken, 21}},
+		},
+		{
+			name:       "unclosed brace",
+			input:      "items: { a: 1 OR (b: 2",
+			wantString: "items: { a: 1 OR (b: 2) }",
+			wantErrors: []wantError{{parser.ErrorCodeUnmatchedParen, 22}, {parser.ErrorCodeUnmatchedBrace, 22}},
+		},
+		{
+			name:       "too deep",
+			input:      "((a: 1)) AND (b: 2)",
+			opts:       []parser.Option{parser.WithMaxDepth(1)},
+			wantString: "((a: 1)) AND (b: 2)",
+			wantBad:    []string{"(a: 1)"},
+			wantErrors: []wantError{{parser.ErrorCodeTooDeep, 1}},
+		},
+		{
+			name:       "strict",
+			input:      "a NOT b AND c > 1*",
+			opts:       []parser.Option{parser.WithStrict(true)},
+			wantString: "a NOT b AND c > 1*",
+			wantBad:    []string{"c > 1*"},
+			wantErrors: []wantError{{parser.ErrorCodeStrict, 2}, {parser.ErrorCodeStrict, 16}},
+		},
+		{
+			name:       "left to right",
+			input:      "a: AND b c OR d",
+			opts:       []parser.Option{parser.WithPrecedence(parser.PrecedenceLeftToRight)},
+			wantString: "a: AND b c OR d",
+			wantBad:    []string{"a:", "b c"},
+			wantErrors: []wantError{{parser.ErrorCodeUnexpectedToken, 3}, {parser.ErrorCodeUnexpectedToken, 9}},
+		},
+		{
+			name:       "empty query",
+			input:      " ",
+			wantErrors: []wantError{{parser.ErrorCodeEmptyQuery, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parser.New(tt.input, append(tt.opts, parser.WithRecovery(true))...).Stmt()
+			if tt.wantString == "" {
+				assert.Nil(t, expr)
+			} else {
+				require.NotNil(t, expr)
+				assert.Equal(t, tt.wantString, expr.String())
+			}
+
+			var bad []string
+
+			if expr != nil {
+				ast.Inspect(expr, func(e ast.Expr) bool {
+					if b, ok := e.(*ast.BadExpr); ok {
+						bad = append(bad, b.Value)
+						assert.Equal(t, b.Value, string([]rune(tt.input)[b.Pos():b.End()]))
+					}
+
+					return true
+				})
+			}
+
+			assert.Equal(t, tt.wantBad, bad)
+
+			if len(tt.wantErrors) == 0 {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			var list parser.ErrorList
+			require.True(t, errors.As(err, &list))
+
+			var got []wantError
+			for _, e := range list {
+				got = append(got, wantError{e.Code(), e.RuneOffset()})
+			}
+
+			assert.Equal(t, tt.wantErrors, got)
+			assert.True(t, errors.Is(err, tt.wantErrors[0].code))
+		})
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	_, err := parser.New("a: AND b c", parser.WithRecovery(true)).Stmt()
+	require.Error(t, err)
+
+	assert.Equal(t, "line 0:3 unexpected token: AND\na: AND b c\n   ^^^\n"+
+		"line 0:9 expected keyword OR|AND|NOT, but got \"Ident\"\na: AND b c\n         ^\n", err.Error())
+	assert.True(t, errors.Is(err, parser.ErrorCodeUnexpectedToken))
+	assert.False(t, errors.Is(err, parser.ErrorCodeBadEscape))
+
+	assert.NoError(t, parser.ErrorList(nil).Err())
+	assert.Equal(t, "no errors", parser.ErrorList(nil).Error())
+}
+
 func TestParser_EscapedKeywords(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -679,6 +1204,26 @@ func TestParser_EdgeCases(t *testing.T) {
 			name:  "invalid field name",
 			query: "field space: value",
 		},
+		{
+			name:  "unclosed brace",
+			query: "items: { name: value",
+		},
+		{
+			name:  "brace closed by parenthesis",
+			query: "items: { name: value )",
+		},
+		{
+			name:  "brace without field",
+			query: "{ name: value }",
+		},
+		{
+			name:  "brace after range operator",
+			query: "items > { name: value }",
+		},
+		{
+			name:  "nested path with wildcard",
+			query: "item*: { name: value }",
+		},
 	}
 
 	for _, tt := range tests {
@@ -754,14 +1299,14 @@ func TestParserFuzzBugs(t *testing.T) {
 		},
 		{
 			"\\AND :0",
-			ast.NewBinaryExpr(0, "\\AND", token.TokenKindOperatorEql, ast.NewLiteral(
+			ast.NewBinaryExpr(0, ast.NewLiteral(0, 4, token.TokenKindIdent, "AND", []int{0}), token.TokenKindOperatorEql, ast.NewLiteral(
 				6, 7, token.TokenKindInt, "0", nil,
 			), false),
 			nil,
 		},
 		{
 			"\\AND: 0",
-			ast.NewBinaryExpr(0, "\\AND", token.TokenKindOperatorEql, ast.NewLiteral(
+			ast.NewBinaryExpr(0, ast.NewLiteral(0, 4, token.TokenKindIdent, "AND", []int{0}), token.TokenKindOperatorEql, ast.NewLiteral(
 				6, 7, token.TokenKindInt, "0", nil,
 			), false),
 			nil,
@@ -0,0 +1,99 @@
+package parser
+
+import "github.com/laojianzi/kql-go/token"
+
+// Scanner splits a KQL(kibana query language) query into the tokens that the parser reads,
+// e.g. for syntax highlighting. The tokens are scanned lazily, like bufio.Scanner:
+//
+//	s := parser.NewScanner(query, parser.WithTrivia(true), parser.WithRecovery(true))
+//	for s.Scan() {
+//		tok := s.Token()
+//		...
+//	}
+//
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// The scanning stops at the first error by default. In recovery mode(see WithRecovery) the source that can not
+// be scanned(e.g. an unclosed string) becomes a token.TokenKindIllegal token and the scanning goes on,
+// Err returns an ErrorList of all the errors then. With WithTrivia the spaces are emitted as
+// token.TokenKindWhitespace tokens, so the spans of the tokens cover the whole query.
+//
+// The span of a string token includes its double quotes, but its Value does not, see Token.
+type Scanner struct {
+	lexer  *defaultLexer
+	opts   options
+	errors ErrorList
+	done   bool
+}
+
+// NewScanner creates a new scanner of the input, only WithMaxLength, WithRecovery and WithTrivia take effect.
+func NewScanner(input string, opts ...Option) *Scanner {
+	o := newOptions(opts)
+
+	s := &Scanner{lexer: newLexer(input, o), opts: o}
+	s.lexer.trivia = o.trivia
+
+	if o.recovery {
+		s.lexer.onError = s.addError
+	}
+
+	return s
+}
+
+// Scan advances the scanner to the next token, which will then be available through the Token method.
+// It returns false when the scanning stops, either by reaching the end of the input or an error.
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+
+	if err := s.lexer.nextToken(); err != nil {
+		s.addError(err)
+		s.done = true
+
+		return false
+	}
+
+	if s.lexer.Token.Kind == token.TokenKindEof {
+		s.done = true
+
+		return false
+	}
+
+	return true
+}
+
+// Token returns the most recent token generated by a call to Scan.
+func (s *Scanner) Token() Token {
+	return s.lexer.Token
+}
+
+// Err returns the error that stops the scanning, or the ErrorList of all the errors in recovery mode.
+// It is nil if there are no errors.
+func (s *Scanner) Err() error {
+	if !s.opts.recovery && len(s.errors) > 0 {
+		return s.errors[0]
+	}
+
+	return s.errors.Err()
+}
+
+func (s *Scanner) addError(err error) {
+	e, _ := spanError(s.lexer.input, s.lexer.Token.Pos, s.lexer.Token.End, err).(*Error)
+	s.errors = append(s.errors, e)
+}
+
+// Tokenize scans all the tokens of the input with a Scanner, the <EOF> token is not included.
+// The tokens scanned before an error are returned together with the error.
+func Tokenize(input string, opts ...Option) ([]Token, error) {
+	var tokens []Token
+
+	s := NewScanner(input, opts...)
+	for s.Scan() {
+		tokens = append(tokens, s.Token())
+	}
+
+	return tokens, s.Err()
+}
@@ -0,0 +1,106 @@
+package parser_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestTokenize(t *testing.T) {
+	input := `a\:b: "x y" OR NOT c >= 1.5`
+
+	tokens, err := parser.Tokenize(input)
+	require.NoError(t, err)
+
+	assert.Equal(t, []parser.Token{
+		{Pos: 0, End: 4, Offset: 0, EndOffset: 4, Kind: token.TokenKindIdent, Value: "a:b", EscapeIndexes: []int{1}},
+		{Pos: 4, End: 5, Offset: 4, EndOffset: 5, Kind: token.TokenKindOperatorEql, Value: ":"},
+		{Pos: 6, End: 11, Offset: 6, EndOffset: 11, Kind: token.TokenKindString, Value: "x y"},
+		{Pos: 12, End: 14, Offset: 12, EndOffset: 14, Kind: token.TokenKindKeywordOr, Value: "OR"},
+		{Pos: 15, End: 18, Offset: 15, EndOffset: 18, Kind: token.TokenKindKeywordNot, Value: "NOT"},
+		{Pos: 19, End: 20, Offset: 19, EndOffset: 20, Kind: token.TokenKindIdent, Value: "c"},
+		{Pos: 21, End: 23, Offset: 21, EndOffset: 23, Kind: token.TokenKindOperatorGeq, Value: ">="},
+		{Pos: 24, End: 27, Offset: 24, EndOffset: 27, Kind: token.TokenKindFloat, Value: "1.5"},
+	}, tokens)
+	assert.Equal(t, `"x y"`, input[tokens[2].Offset:tokens[2].EndOffset], "the span includes the double quotes")
+}
+
+func TestTokenize_Trivia(t *testing.T) {
+	input := " 日志:\t\"错误\"\r\n"
+
+	tokens, err := parser.Tokenize(input, parser.WithTrivia(true))
+	require.NoError(t, err)
+
+	var kinds []token.Kind
+
+	end := 0
+	for _, tok := range tokens {
+		kinds = append(kinds, tok.Kind)
+
+		assert.Equal(t, end, tok.Offset)
+
+		end = tok.EndOffset
+	}
+
+	assert.Equal(t, len(input), end, "the tokens cover the whole input")
+	assert.Equal(t, []token.Kind{
+		token.TokenKindWhitespace, token.TokenKindIdent, token.TokenKindOperatorEql,
+		token.TokenKindWhitespace, token.TokenKindString, token.TokenKindWhitespace,
+	}, kinds)
+	assert.Equal(t, "\r\n", tokens[len(tokens)-1].Value)
+}
+
+func TestTokenize_Error(t *testing.T) {
+	tokens, err := parser.Tokenize(`a: "b AND c`)
+	require.Len(t, tokens, 2)
+
+	var e *parser.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, parser.ErrorCodeUnclosedString, e.Code())
+	assert.Equal(t, 3, e.Offset())
+}
+
+func TestTokenize_Recovery(t *testing.T) {
+	tokens, err := parser.Tokenize(`a: b\c OR 1. AND d: "e`, parser.WithRecovery(true))
+
+	type kindValue struct {
+		kind  token.Kind
+		value string
+	}
+
+	var got []kindValue
+	for _, tok := range tokens {
+		got = append(got, kindValue{tok.Kind, tok.Value})
+	}
+
+	assert.Equal(t, []kindValue{
+		{token.TokenKindIdent, "a"}, {token.TokenKindOperatorEql, ":"}, {token.TokenKindIllegal, `b\c`},
+		{token.TokenKindKeywordOr, "OR"}, {token.TokenKindIllegal, "1."}, {token.TokenKindKeywordAnd, "AND"},
+		{token.TokenKindIdent, "d"}, {token.TokenKindOperatorEql, ":"}, {token.TokenKindIllegal, `"e`},
+	}, got)
+
+	var list parser.ErrorList
+	require.True(t, errors.As(err, &list))
+	require.Len(t, list, 3)
+	assert.Equal(t, parser.ErrorCodeBadEscape, list[0].Code())
+	assert.Equal(t, parser.ErrorCodeBadNumber, list[1].Code())
+	assert.Equal(t, parser.ErrorCodeUnclosedString, list[2].Code())
+}
+
+func TestScanner(t *testing.T) {
+	s := parser.NewScanner("a: 12345", parser.WithMaxLength(4), parser.WithRecovery(true))
+	assert.False(t, s.Scan())
+	assert.False(t, s.Scan())
+	assert.True(t, errors.Is(s.Err(), parser.ErrorCodeTooLong))
+
+	s = parser.NewScanner("a")
+	require.True(t, s.Scan())
+	assert.Equal(t, "a", s.Token().Value)
+	assert.False(t, s.Scan())
+	assert.NoError(t, s.Err())
+}
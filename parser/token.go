@@ -3,9 +3,14 @@ package parser
 import "github.com/laojianzi/kql-go/token"
 
 // Token is a token parsed from lexer.
+//
+// Pos and End are rune(character) offsets into the input, Offset and EndOffset are the byte offsets of them.
+// The span of a string includes its double quotes, Value does not.
 type Token struct {
 	Pos           int
 	End           int
+	Offset        int
+	EndOffset     int
 	Kind          token.Kind
 	Value         string
 	EscapeIndexes []int
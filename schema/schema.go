@@ -0,0 +1,150 @@
+// Package schema declares the types of the fields of the KQL(kibana query language) queries,
+// and validates the queries against them.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laojianzi/kql-go/ast"
+)
+
+// Type is the type of a field, which follows the field types of Elasticsearch.
+type Type int
+
+const (
+	TypeKeyword Type = iota + 1 // an exact string, e.g. a status or a tag
+	TypeText                    // a full-text string, e.g. a message
+	TypeLong                    // an integer
+	TypeDouble                  // a floating-point number
+	TypeDate                    // a date
+	TypeIP                      // an IPv4 or IPv6 address
+	TypeBoolean                 // true or false
+	TypeNested                  // an array of objects, which is queried with a nested field query
+	TypeVersion                 // a software version, e.g. 1.2.3
+)
+
+var typeNames = [...]string{
+	TypeKeyword: "keyword",
+	TypeText:    "text",
+	TypeLong:    "long",
+	TypeDouble:  "double",
+	TypeDate:    "date",
+	TypeIP:      "ip",
+	TypeBoolean: "boolean",
+	TypeNested:  "nested",
+	TypeVersion: "version",
+}
+
+// ParseType returns the type of the name, e.g. "keyword".
+func ParseType(name string) (Type, error) {
+	for t, s := range typeNames {
+		if s != "" && s == strings.ToLower(name) {
+			return Type(t), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown field type %q", name)
+}
+
+// String returns the name of the type, e.g. "keyword".
+func (t Type) String() string {
+	if t > 0 && int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+
+	return fmt.Sprintf("Type(%d)", t)
+}
+
+// MarshalText encodes the type as its name.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes the type from its name, so a Schema can be decoded from a JSON object
+// like {"status": "keyword", "latency": "double"}.
+func (t *Type) UnmarshalText(text []byte) error {
+	v, err := ParseType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = v
+
+	return nil
+}
+
+// Numeric reports whether the values of the type are numbers.
+func (t Type) Numeric() bool {
+	return t == TypeLong || t == TypeDouble
+}
+
+// Orderable reports whether the values of the type can be compared with the range operators(>, >=, <, <=),
+// which are the numeric, date, ip, keyword(compared as strings) and version types like Kibana.
+func (t Type) Orderable() bool {
+	return t.Numeric() || t == TypeDate || t == TypeIP || t == TypeKeyword || t == TypeVersion
+}
+
+// Schema maps the field names to their types. The fields of a nested field are named with its path as prefix,
+// e.g. "items" of TypeNested and "items.name" of TypeKeyword for `items: { name: x }`.
+type Schema map[string]Type
+
+// Lookup returns the type of the field name.
+func (s Schema) Lookup(name string) (Type, bool) {
+	t, ok := s[name]
+
+	return t, ok
+}
+
+// Coerce returns the typed value of the value lit of field, which makes a Schema a parser.TypeResolver:
+//
+//   - int64 of a long field
+//   - float64 of a double field
+//   - bool of a boolean field
+//   - time.Time of a date field(see ast.Literal.Time)
+//   - net.IP of an ip field
+//   - string of a keyword, text or version field
+//
+// It returns false if the field is unknown or nested, or lit can not be coerced into the type of the field.
+func (s Schema) Coerce(field string, lit *ast.Literal) (interface{}, bool) {
+	var (
+		v   interface{}
+		err error
+	)
+
+	switch t, _ := s.Lookup(field); t {
+	case TypeKeyword, TypeText, TypeVersion:
+		return lit.Unescaped(), true
+	case TypeLong:
+		v, err = lit.Int64()
+	case TypeDouble:
+		v, err = lit.Float64()
+	case TypeDate:
+		v, err = lit.Time()
+	case TypeIP:
+		v, err = lit.IP()
+	case TypeBoolean:
+		v, err = lit.Bool()
+	default:
+		return nil, false
+	}
+
+	return v, err == nil
+}
+
+// resolve returns the type of field(*ast.Literal or *ast.WildcardExpr) in the nested field query of prefix
+// (the path with trailing dot), a wildcard field has no type and is known if it matches any field.
+func (s Schema) resolve(prefix string, field ast.Expr) (t Type, known bool) {
+	switch f := field.(type) {
+	case *ast.Literal:
+		return s.Lookup(prefix + f.Value)
+	case *ast.WildcardExpr:
+		for name := range s {
+			if strings.HasPrefix(name, prefix) && f.Match(strings.TrimPrefix(name, prefix)) {
+				return 0, true
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go"
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/schema"
+	"github.com/laojianzi/kql-go/token"
+)
+
+func TestParseType(t *testing.T) {
+	for _, want := range []schema.Type{
+		schema.TypeKeyword, schema.TypeText, schema.TypeLong, schema.TypeDouble,
+		schema.TypeDate, schema.TypeIP, schema.TypeBoolean, schema.TypeNested, schema.TypeVersion,
+	} {
+		got, err := schema.ParseType(want.String())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	got, err := schema.ParseType("KEYWORD")
+	require.NoError(t, err)
+	assert.Equal(t, schema.TypeKeyword, got)
+
+	_, err = schema.ParseType("integer")
+	assert.EqualError(t, err, `unknown field type "integer"`)
+	assert.Equal(t, "Type(0)", schema.Type(0).String())
+}
+
+func TestType(t *testing.T) {
+	cases := []struct {
+		typ       schema.Type
+		numeric   bool
+		orderable bool
+	}{
+		{schema.TypeKeyword, false, true},
+		{schema.TypeText, false, false},
+		{schema.TypeLong, true, true},
+		{schema.TypeDouble, true, true},
+		{schema.TypeDate, false, true},
+		{schema.TypeIP, false, true},
+		{schema.TypeBoolean, false, false},
+		{schema.TypeNested, false, false},
+		{schema.TypeVersion, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.typ.String(), func(t *testing.T) {
+			assert.Equal(t, c.numeric, c.typ.Numeric())
+			assert.Equal(t, c.orderable, c.typ.Orderable())
+		})
+	}
+}
+
+func TestSchema_JSON(t *testing.T) {
+	var s schema.Schema
+
+	require.NoError(t, json.Unmarshal([]byte(`{"status": "keyword", "latency": "Double", "items": "nested"}`), &s))
+	assert.Equal(t, schema.Schema{
+		"status":  schema.TypeKeyword,
+		"latency": schema.TypeDouble,
+		"items":   schema.TypeNested,
+	}, s)
+
+	typ, ok := s.Lookup("latency")
+	assert.True(t, ok)
+	assert.Equal(t, schema.TypeDouble, typ)
+
+	_, ok = s.Lookup("missing")
+	assert.False(t, ok)
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status": "keyword", "latency": "double", "items": "nested"}`, string(data))
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"status": "string"}`), &s), `unknown field type "string"`)
+}
+
+func TestSchema_Coerce(t *testing.T) {
+	s := schema.Schema{
+		"status":     schema.TypeKeyword,
+		"message":    schema.TypeText,
+		"code":       schema.TypeLong,
+		"latency":    schema.TypeDouble,
+		"@timestamp": schema.TypeDate,
+		"client.ip":  schema.TypeIP,
+		"enabled":    schema.TypeBoolean,
+		"items":      schema.TypeNested,
+		"version":    schema.TypeVersion,
+	}
+
+	cases := []struct {
+		field string
+		lit   *ast.Literal
+		want  interface{}
+		ok    bool
+	}{
+		{"status", ast.NewLiteral(0, 3, token.TokenKindString, "a*", []int{1}), "a*", true},
+		{"message", ast.NewLiteral(0, 2, token.TokenKindInt, "42", nil), "42", true},
+		{"code", ast.NewLiteral(0, 4, token.TokenKindString, "404", nil), int64(404), true},
+		{"code", ast.NewLiteral(0, 3, token.TokenKindFloat, "1.5", nil), int64(0), false},
+		{"latency", ast.NewLiteral(0, 3, token.TokenKindFloat, "1.5", nil), 1.5, true},
+		{"@timestamp", ast.NewLiteral(0, 12, token.TokenKindString, "2024-01-02", nil),
+			time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		{"client.ip", ast.NewLiteral(0, 8, token.TokenKindIdent, "10.0.0.1", nil), net.IPv4(10, 0, 0, 1), true},
+		{"enabled", ast.NewLiteral(0, 4, token.TokenKindIdent, "true", nil), true, true},
+		{"enabled", ast.NewLiteral(0, 3, token.TokenKindIdent, "yes", nil), false, false},
+		{"version", ast.NewLiteral(0, 5, token.TokenKindIdent, "1.2.3", nil), "1.2.3", true},
+		{"items", ast.NewLiteral(0, 1, token.TokenKindIdent, "x", nil), nil, false},
+		{"missing", ast.NewLiteral(0, 1, token.TokenKindIdent, "x", nil), nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.field+" "+c.lit.String(), func(t *testing.T) {
+			got, ok := s.Coerce(c.field, c.lit)
+			assert.Equal(t, c.ok, ok)
+
+			if ok {
+				assert.Equal(t, c.want, got)
+			}
+		})
+	}
+
+	var _ kql.TypeResolver = s
+}
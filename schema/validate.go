@@ -0,0 +1,297 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Code identifies the kind of a diagnostic, it is stable and suitable for localizing the message.
+type Code int
+
+const (
+	CodeUnknownField      Code = iota + 1 // a field that is not in the schema
+	CodeNotOrderable                      // a range operator(>, >=, <, <=) on a field whose type is not orderable
+	CodeNonNumericValue                   // a value of a numeric field that is not a number
+	CodeNumericWildcard                   // a wildcard value of a numeric field
+	CodeNotNested                         // a nested field query on a field that is not nested
+	CodeDateValue                         // a date or a date math value(*ast.DateExpr) of a field that is not a date
+	CodeInvalidRangeValue                 // a range value that is not valid for the type of the field, e.g. not an IP
+)
+
+var codes = [...]string{
+	CodeUnknownField:      "unknown_field",
+	CodeNotOrderable:      "not_orderable",
+	CodeNonNumericValue:   "non_numeric_value",
+	CodeNumericWildcard:   "numeric_wildcard",
+	CodeNotNested:         "not_nested",
+	CodeDateValue:         "date_value",
+	CodeInvalidRangeValue: "invalid_range_value",
+}
+
+// String returns the name of the code, e.g. "unknown_field".
+func (c Code) String() string {
+	if c > 0 && int(c) < len(codes) {
+		return codes[c]
+	}
+
+	return fmt.Sprintf("Code(%d)", c)
+}
+
+// MarshalText encodes the code as its name.
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Diagnostic is a problem of a query against a schema. Like a parse error(*kql.Error), it carries a code
+// and the span of the problem in rune(character) positions, so an editor can underline it.
+type Diagnostic struct {
+	Code    Code
+	Message string
+	Field   string // the name of the field, with the path of the nested field query as prefix
+	Pos     int    // the position of the expression with the problem
+	End     int    // the end position of the expression with the problem
+}
+
+// Error returns the message of the diagnostic.
+func (d Diagnostic) Error() string {
+	return d.Message
+}
+
+// RuneOffset returns the rune(character) offset of the diagnostic in the query.
+func (d Diagnostic) RuneOffset() int {
+	return d.Pos
+}
+
+// Len returns the length(in runes) of the span of the diagnostic.
+func (d Diagnostic) Len() int {
+	return d.End - d.Pos
+}
+
+// Offset returns the byte offset of the diagnostic in query.
+func (d Diagnostic) Offset(query string) int {
+	return ast.ByteOffset(query, d.Pos)
+}
+
+// Line returns the line(from 0) of the diagnostic in query, lines are separated by '\n'.
+func (d Diagnostic) Line(query string) int {
+	line, _ := d.lineColumn(query)
+
+	return line
+}
+
+// Column returns the column(in runes, from 0) of the diagnostic in its line of query.
+func (d Diagnostic) Column(query string) int {
+	_, column := d.lineColumn(query)
+
+	return column
+}
+
+func (d Diagnostic) lineColumn(query string) (line, column int) {
+	for i, r := range []rune(query) {
+		if i >= d.Pos {
+			break
+		}
+
+		if r == '\n' {
+			line++
+			column = 0
+		} else {
+			column++
+		}
+	}
+
+	return line, column
+}
+
+// MarshalJSON encodes the diagnostic as a JSON object with the message, code, field and span.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message    string `json:"message"`
+		Code       Code   `json:"code"`
+		Field      string `json:"field"`
+		RuneOffset int    `json:"rune_offset"`
+		Len        int    `json:"len"`
+	}{
+		Message:    d.Message,
+		Code:       d.Code,
+		Field:      d.Field,
+		RuneOffset: d.RuneOffset(),
+		Len:        d.Len(),
+	})
+}
+
+// Validate checks the clauses of expr against schema, it returns the diagnostics in the order of their positions:
+//
+//   - a field that is not in the schema(CodeUnknownField), a wildcard field(e.g. `machine.os*`) is known
+//     if it matches any field, the other checks are skipped for it
+//   - a range operator on a field that is not orderable(CodeNotOrderable), e.g. `enabled > 1` of a boolean field
+//   - a range value that is not valid for the type of the field(CodeInvalidRangeValue), which is not a date
+//     of a date field or not an IP of an ip field, e.g. `host.ip > x` in RangeModeKibana(see parser.WithRangeMode)
+//   - a value of a numeric field that is not a number(CodeNonNumericValue), e.g. `latency: fast` of a double field,
+//     a quoted number(e.g. `"200"`) is a number
+//   - a wildcard value of a numeric field(CodeNumericWildcard), e.g. `status: 2*` of a long field
+//   - a nested field query on a field that is not nested(CodeNotNested)
+//   - a date or a date math value of a field that is neither a date nor a keyword(CodeDateValue),
+//     e.g. `code > now-1d` of a long field
+//
+// The fields inside a nested field query(e.g. `items: { name: x }`) are looked up with the path as prefix,
+// e.g. `items.name`.
+func Validate(expr ast.Expr, schema Schema) []Diagnostic {
+	v := &validator{schema: schema}
+	v.validate(expr, "")
+
+	return v.diagnostics
+}
+
+type validator struct {
+	schema      Schema
+	diagnostics []Diagnostic
+}
+
+// validate checks expr in the nested field query of prefix(the path with trailing dot).
+func (v *validator) validate(expr ast.Expr, prefix string) {
+	switch e := expr.(type) {
+	case *ast.CombineExpr:
+		v.validate(e.LeftExpr, prefix)
+		v.validate(e.RightExpr, prefix)
+	case *ast.ParenExpr:
+		v.validate(e.Expr, prefix)
+	case *ast.BinaryExpr:
+		if e.Field == nil { // a value without field or a group of clauses
+			v.validate(e.Value, prefix)
+
+			return
+		}
+
+		v.clause(e, e.Field, e.Operator, e.Value, prefix)
+	case *ast.ValueListExpr:
+		v.clause(e, e.Field, e.Operator, e.Values, prefix)
+	case *ast.ExistsExpr:
+		v.field(e.Field, prefix)
+	case *ast.NestedExpr:
+		if t, ok := v.field(e.Path, prefix); ok && t != TypeNested {
+			v.report(CodeNotNested, prefix+e.PathName(), e.Path, "field %q of type %s is not nested", prefix+e.PathName(), t)
+		}
+
+		v.validate(e.Expr, prefix+e.PathName()+".")
+	}
+}
+
+// field checks that field is known, it returns the type of the field if it has one.
+func (v *validator) field(field ast.Expr, prefix string) (Type, bool) {
+	t, known := v.schema.resolve(prefix, field)
+	if !known {
+		name := prefix + fieldName(field)
+		v.report(CodeUnknownField, name, field, "unknown field %q", name)
+	}
+
+	return t, known && t > 0
+}
+
+// clause checks the clause e(*ast.BinaryExpr or *ast.ValueListExpr) of field with the operator op and value.
+func (v *validator) clause(e, field ast.Expr, op token.Kind, value ast.Expr, prefix string) {
+	t, ok := v.field(field, prefix)
+	if !ok {
+		return
+	}
+
+	name := prefix + fieldName(field)
+	if op != token.TokenKindOperatorEql && !t.Orderable() {
+		v.report(CodeNotOrderable, name, e, "range operator %s on field %q of type %s", op, name, t)
+	}
+
+	if date, ok := value.(*ast.DateExpr); ok && t != TypeDate && t != TypeKeyword {
+		v.report(CodeDateValue, name, date, "date value %s of field %q of type %s", date, name, t)
+
+		return
+	}
+
+	if op != token.TokenKindOperatorEql && !validRangeValue(t, value) {
+		v.report(CodeInvalidRangeValue, name, value, "invalid range value %s of field %q of type %s", value, name, t)
+	}
+
+	if !t.Numeric() {
+		return
+	}
+
+	for _, item := range values(value) {
+		switch item := item.(type) {
+		case *ast.WildcardExpr:
+			v.report(CodeNumericWildcard, name, item, "wildcard value %s of field %q of type %s", item, name, t)
+		case *ast.Literal:
+			if item.Kind != token.TokenKindInt && item.Kind != token.TokenKindFloat && !token.IsNumber(item.Value) {
+				v.report(CodeNonNumericValue, name, item, "non-numeric value %s of field %q of type %s", item, name, t)
+			}
+		}
+	}
+}
+
+func (v *validator) report(code Code, field string, expr ast.Expr, format string, args ...interface{}) {
+	v.diagnostics = append(v.diagnostics, Diagnostic{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Field:   field,
+		Pos:     expr.Pos(),
+		End:     expr.End(),
+	})
+}
+
+// validRangeValue reports whether value is a valid range value of a field of type t: a date field takes a date
+// or milliseconds since the Unix epoch, an ip field takes an IPv4 or IPv6 address. The numeric values are checked
+// by the numeric checks and the other types take any value.
+func validRangeValue(t Type, value ast.Expr) bool {
+	switch t {
+	case TypeDate:
+		if lit, ok := value.(*ast.Literal); ok {
+			return token.IsNumber(lit.Value)
+		}
+
+		_, ok := value.(*ast.DateExpr)
+
+		return ok
+	case TypeIP:
+		lit, ok := value.(*ast.Literal)
+		if !ok {
+			return false
+		}
+
+		_, err := lit.IP()
+
+		return err == nil
+	}
+
+	return true
+}
+
+// values returns the values(*ast.Literal and *ast.WildcardExpr) of a clause, e.g. `a` and `b` of `f: (a OR b)`.
+func values(expr ast.Expr) []ast.Expr {
+	var list []ast.Expr
+
+	ast.Inspect(expr, func(e ast.Expr) bool {
+		switch e.(type) {
+		case *ast.Literal, *ast.WildcardExpr:
+			list = append(list, e)
+
+			return false
+		}
+
+		return e != nil
+	})
+
+	return list
+}
+
+// fieldName returns the unescaped name of a field(*ast.Literal or *ast.WildcardExpr).
+func fieldName(field ast.Expr) string {
+	switch f := field.(type) {
+	case *ast.Literal:
+		return f.Value
+	case *ast.WildcardExpr:
+		return f.Value
+	}
+
+	return ""
+}
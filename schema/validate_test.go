@@ -0,0 +1,159 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go"
+	"github.com/laojianzi/kql-go/schema"
+)
+
+var testSchema = schema.Schema{
+	"status":     schema.TypeKeyword,
+	"message":    schema.TypeText,
+	"code":       schema.TypeLong,
+	"latency":    schema.TypeDouble,
+	"@timestamp": schema.TypeDate,
+	"client.ip":  schema.TypeIP,
+	"enabled":    schema.TypeBoolean,
+	"items":      schema.TypeNested,
+	"items.name": schema.TypeKeyword,
+	"items.qty":  schema.TypeLong,
+	"version":    schema.TypeVersion,
+}
+
+func TestValidate(t *testing.T) {
+	type diagnostic struct {
+		code    schema.Code
+		field   string
+		span    string
+		message string
+	}
+
+	kibana := []kql.Option{kql.WithRangeMode(kql.RangeModeKibana)}
+
+	cases := []struct {
+		query string
+		opts  []kql.Option
+		want  []diagnostic
+	}{
+		{query: `status: active AND code >= 200 AND latency < 1.5 AND client.ip: "10.0.0.1" AND enabled: true`},
+		{query: `@timestamp > 1700000000 AND code: "404" AND message: *error* AND status: (a OR b*)`},
+		{query: `items: { name: x AND qty > 2 } AND client.*: * AND NOT (code: 1 OR latency: 2)`},
+		{query: `value AND (a OR "b")`},
+		{query: `@timestamp >= now-7d/d AND @timestamp < "2024-01-01"`},
+		{query: `status > a AND status <= "2024-01-01" AND version >= "1.2.3" AND client.ip < 10.0.0.255`, opts: kibana},
+		{query: `client.ip >= "::1" AND @timestamp > "1700000000" AND status > now-1d`, opts: kibana},
+		{
+			query: `code > now-1d AND message < "2024-01-01"`,
+			want: []diagnostic{
+				{schema.CodeDateValue, "code", "now-1d", `date value now-1d of field "code" of type long`},
+				{schema.CodeNotOrderable, "message", `message < "2024-01-01"`, `range operator < on field "message" of type text`},
+				{schema.CodeDateValue, "message", `"2024-01-01"`, `date value "2024-01-01" of field "message" of type text`},
+			},
+		},
+		{
+			query: `client.ip > 10.0.0 AND client.ip <= x* AND @timestamp >= yesterday AND @timestamp < 1.2.3`,
+			opts:  kibana,
+			want: []diagnostic{
+				{schema.CodeInvalidRangeValue, "client.ip", "10.0.0", `invalid range value 10.0.0 of field "client.ip" of type ip`},
+				{schema.CodeInvalidRangeValue, "client.ip", "x*", `invalid range value x* of field "client.ip" of type ip`},
+				{
+					schema.CodeInvalidRangeValue, "@timestamp", "yesterday",
+					`invalid range value yesterday of field "@timestamp" of type date`,
+				},
+				{
+					schema.CodeInvalidRangeValue, "@timestamp", "1.2.3",
+					`invalid range value 1.2.3 of field "@timestamp" of type date`,
+				},
+			},
+		},
+		{
+			query: `user: bob AND NOT host: *`,
+			want: []diagnostic{
+				{schema.CodeUnknownField, "user", "user", `unknown field "user"`},
+				{schema.CodeUnknownField, "host", "host", `unknown field "host"`},
+			},
+		},
+		{
+			query: `foo*: x AND enabled > 1 AND message <= 2`,
+			want: []diagnostic{
+				{schema.CodeUnknownField, "foo*", "foo*", `unknown field "foo*"`},
+				{schema.CodeNotOrderable, "enabled", "enabled > 1", `range operator > on field "enabled" of type boolean`},
+				{schema.CodeNotOrderable, "message", "message <= 2", `range operator <= on field "message" of type text`},
+			},
+		},
+		{
+			query: `code: fast OR latency: (1 OR "slow") OR code: "20*"`,
+			want: []diagnostic{
+				{schema.CodeNonNumericValue, "code", "fast", `non-numeric value fast of field "code" of type long`},
+				{
+					schema.CodeNonNumericValue, "latency", `"slow"`,
+					`non-numeric value "slow" of field "latency" of type double`,
+				},
+				{schema.CodeNumericWildcard, "code", `"20*"`, `wildcard value "20*" of field "code" of type long`},
+			},
+		},
+		{
+			query: `code: 2* AND enabled > 1`,
+			want: []diagnostic{
+				{schema.CodeNumericWildcard, "code", "2*", `wildcard value 2* of field "code" of type long`},
+				{schema.CodeNotOrderable, "enabled", "enabled > 1", `range operator > on field "enabled" of type boolean`},
+			},
+		},
+		{
+			query: `items: { name: x AND qty: many AND price > 1 } AND status: { a: b } AND other: { c: d }`,
+			want: []diagnostic{
+				{schema.CodeNonNumericValue, "items.qty", "many", `non-numeric value many of field "items.qty" of type long`},
+				{schema.CodeUnknownField, "items.price", "price", `unknown field "items.price"`},
+				{schema.CodeNotNested, "status", "status", `field "status" of type keyword is not nested`},
+				{schema.CodeUnknownField, "status.a", "a", `unknown field "status.a"`},
+				{schema.CodeUnknownField, "other", "other", `unknown field "other"`},
+				{schema.CodeUnknownField, "other.c", "c", `unknown field "other.c"`},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			expr, err := kql.Parse(c.query, c.opts...)
+			require.NoError(t, err)
+
+			var got []diagnostic
+			for _, d := range schema.Validate(expr, testSchema) {
+				span := []rune(c.query)[d.RuneOffset() : d.RuneOffset()+d.Len()]
+				got = append(got, diagnostic{d.Code, d.Field, string(span), d.Error()})
+			}
+
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestValidate_Nil(t *testing.T) {
+	assert.Empty(t, schema.Validate(nil, testSchema))
+}
+
+func TestDiagnostic(t *testing.T) {
+	query := "status: ok\nAND 日志: 错误"
+	diagnostics := schema.Validate(kql.MustParse(query), testSchema)
+	require.Len(t, diagnostics, 1)
+
+	d := diagnostics[0]
+	assert.Equal(t, schema.CodeUnknownField, d.Code)
+	assert.Equal(t, "unknown_field", d.Code.String())
+	assert.Equal(t, 15, d.RuneOffset())
+	assert.Equal(t, 15, d.Offset(query))
+	assert.Equal(t, 2, d.Len())
+	assert.Equal(t, 1, d.Line(query))
+	assert.Equal(t, 4, d.Column(query))
+	assert.Equal(t, "Code(0)", schema.Code(0).String())
+
+	data, err := json.Marshal(d)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"message": "unknown field \"日志\"", "code": "unknown_field", "field": "日志", "rune_offset": 15,
+		"len": 2}`, string(data))
+}
@@ -29,9 +29,12 @@ const (
 	TokenKindOperatorLeq // operator <=
 	TokenKindOperatorGeq // operator >=
 	operatorEnd
-	TokenKindLparen   // (
-	TokenKindRparen   // )
-	TokenKindWildcard // *
+	TokenKindLparen     // (
+	TokenKindRparen     // )
+	TokenKindLbrace     // {
+	TokenKindRbrace     // }
+	TokenKindWildcard   // *
+	TokenKindWhitespace // spaces, tabs and line breaks, only emitted as trivia by parser.Scanner
 )
 
 var tokenKinds = [...]string{
@@ -51,7 +54,10 @@ var tokenKinds = [...]string{
 	TokenKindOperatorGeq: ">=",
 	TokenKindLparen:      "(",
 	TokenKindRparen:      ")",
+	TokenKindLbrace:      "{",
+	TokenKindRbrace:      "}",
 	TokenKindWildcard:    "*",
+	TokenKindWhitespace:  "Whitespace",
 }
 
 // String converts the Kind type to a string representation.
@@ -122,9 +128,14 @@ func IsOperator(s string) bool {
 	return ok && kind.IsOperator()
 }
 
-// IsSpecialChar checks if the string is a special character(operator, ( or )).
+// IsSpecialChar checks if the string is a special character(operator, (, ), { or }).
 func IsSpecialChar(s string) bool {
-	return IsOperator(s) || s == TokenKindLparen.String() || s == TokenKindRparen.String()
+	switch s {
+	case TokenKindLparen.String(), TokenKindRparen.String(), TokenKindLbrace.String(), TokenKindRbrace.String():
+		return true
+	}
+
+	return IsOperator(s)
 }
 
 var numberRegex = regexp.MustCompile(`^[+-]?\d+(\.\d+)?$`)
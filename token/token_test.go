@@ -153,6 +153,7 @@ func TestIsSpecialChar(t *testing.T) {
 	}{
 		{"operator is a special char", ":", true},
 		{"paren is a special char", ")", true},
+		{"brace is a special char", "{", true},
 		{"keyword is not a special char", "or", false},
 	}
 
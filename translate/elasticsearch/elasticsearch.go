@@ -0,0 +1,405 @@
+// Package elasticsearch translates a KQL(kibana query language) expression(AST) into Elasticsearch Query DSL.
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Query is a clause of Elasticsearch Query DSL, it is ready to be passed to json.Marshal.
+type Query map[string]interface{}
+
+// Option configures the translation.
+type Option func(*options)
+
+type options struct {
+	termFields map[string]bool
+}
+
+// WithTermFields translates `field: value` on the given fields(e.g. keyword fields) into term queries
+// instead of match queries.
+func WithTermFields(fields ...string) Option {
+	return func(o *options) {
+		for _, field := range fields {
+			o.termFields[field] = true
+		}
+	}
+}
+
+// Translate converts a KQL(kibana query language) expression(AST) into Elasticsearch Query DSL.
+//
+//   - `field: value` is translated into match, match_phrase(quoted value) or term(see WithTermFields)
+//   - `field > value`, `field >= value`, `field < value` and `field <= value` are translated into range,
+//     the dates and date math values(e.g. `now-7d/d`) are kept as they are for Elasticsearch to resolve
+//   - `field: va*ue` is translated into wildcard, `va*ue` without field into query_string
+//   - `field: *` is translated into exists
+//   - `machine.os*: value` is translated into multi_match on the fields that match the pattern,
+//     use ast.ExpandFields for other operators
+//   - AND is translated into bool.must, OR into bool.should and NOT into bool.must_not
+//   - `path: { ... }` is translated into nested
+func Translate(expr ast.Expr, opts ...Option) (Query, error) {
+	o := options{termFields: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t := &translator{opts: o}
+
+	return t.translate(expr, scope{})
+}
+
+// Marshal converts a KQL(kibana query language) expression(AST) into Elasticsearch Query DSL JSON.
+func Marshal(expr ast.Expr, opts ...Option) ([]byte, error) {
+	query, err := Translate(expr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(query)
+}
+
+// scope is the context that an expression is translated in.
+type scope struct {
+	path  string   // path of the nested query that the expression belongs to
+	field ast.Expr // field of the value list(e.g. `f: (v1 OR v2)`) that the expression belongs to
+}
+
+// fieldName returns the full name(or pattern of a wildcard field) of field in the scope.
+func (s scope) fieldName(field ast.Expr) string {
+	var name string
+
+	switch f := field.(type) {
+	case *ast.WildcardExpr:
+		name = strings.Join(f.Segments(), "*")
+	case *ast.Literal:
+		name = f.Value
+	default:
+		name = field.String()
+	}
+
+	if s.path == "" {
+		return name
+	}
+
+	return s.path + "." + name
+}
+
+type translator struct {
+	opts options
+}
+
+func (t *translator) translate(expr ast.Expr, s scope) (Query, error) {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		return t.translateBinary(e, s)
+	case *ast.CombineExpr:
+		return t.translateCombine(e, s)
+	case *ast.ParenExpr:
+		return t.translate(e.Expr, s)
+	case *ast.NestedExpr:
+		return t.translateNested(e, s)
+	case *ast.ValueListExpr:
+		return t.translateValueList(e, s)
+	case *ast.ExistsExpr:
+		return translateExists(e, s), nil
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T: %s", expr, expr)
+}
+
+func (t *translator) translateBinary(e *ast.BinaryExpr, s scope) (Query, error) {
+	query, err := t.translateClause(e, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.HasNot {
+		return mustNot(query), nil
+	}
+
+	return query, nil
+}
+
+func (t *translator) translateValueList(e *ast.ValueListExpr, s scope) (Query, error) {
+	s.field = e.Field
+
+	query, err := t.translate(e.Values, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.HasNot {
+		return mustNot(query), nil
+	}
+
+	return query, nil
+}
+
+func (t *translator) translateClause(e *ast.BinaryExpr, s scope) (Query, error) {
+	field, op := e.Field, e.Operator
+	if field == nil && s.field != nil { // a value of the value list
+		field, op = s.field, token.TokenKindOperatorEql
+	}
+
+	if paren, ok := e.Value.(*ast.ParenExpr); ok {
+		if field != nil {
+			s.field = field
+		}
+
+		return t.translate(paren.Expr, s)
+	}
+
+	if field == nil {
+		return t.translateValue(e.Value, nil)
+	}
+
+	name := s.fieldName(field)
+
+	if _, ok := field.(*ast.WildcardExpr); ok {
+		if op != token.TokenKindOperatorEql {
+			return nil, fmt.Errorf("wildcard field %q is not supported by operator %q, expand it with ast.ExpandFields",
+				field, op)
+		}
+
+		return t.translateValue(e.Value, []string{name})
+	}
+
+	switch op {
+	case token.TokenKindOperatorEql:
+		return t.translateMatch(name, e.Value)
+	case token.TokenKindOperatorLss, token.TokenKindOperatorLeq, token.TokenKindOperatorGtr, token.TokenKindOperatorGeq:
+		return translateRange(name, op, e.Value)
+	}
+
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+// translateValue translates a value that searches fields(patterns of field names), nil fields searches all fields.
+func (t *translator) translateValue(value ast.Expr, fields []string) (Query, error) {
+	var (
+		kind  string
+		query Query
+	)
+
+	switch v := value.(type) {
+	case *ast.WildcardExpr:
+		kind, query = "query_string", Query{"query": queryStringPattern(v)}
+	case *ast.Literal:
+		matchType := "best_fields"
+		if v.WithDoubleQuote {
+			matchType = "phrase"
+		}
+
+		kind, query = "multi_match", Query{"query": literalValue(v), "type": matchType, "lenient": true}
+	default:
+		return nil, fmt.Errorf("unsupported value %T: %s", value, value)
+	}
+
+	if fields != nil {
+		query["fields"] = fields
+	}
+
+	return Query{kind: query}, nil
+}
+
+func (t *translator) translateMatch(field string, value ast.Expr) (Query, error) {
+	switch v := value.(type) {
+	case *ast.WildcardExpr:
+		return Query{"wildcard": Query{field: Query{"value": wildcardPattern(v)}}}, nil
+	case *ast.Literal:
+		switch {
+		case t.opts.termFields[field]:
+			return Query{"term": Query{field: literalValue(v)}}, nil
+		case v.WithDoubleQuote:
+			return Query{"match_phrase": Query{field: literalValue(v)}}, nil
+		default:
+			return Query{"match": Query{field: literalValue(v)}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported value %T: %s", value, value)
+}
+
+func translateRange(field string, op token.Kind, value ast.Expr) (Query, error) {
+	var v interface{}
+
+	switch e := value.(type) {
+	case *ast.Literal:
+		v = literalValue(e)
+	case *ast.DateExpr: // Elasticsearch resolves the date math itself
+		v = e.Value
+	default:
+		return nil, fmt.Errorf("unsupported range value %T: %s", value, value)
+	}
+
+	var key string
+
+	switch op {
+	case token.TokenKindOperatorLss:
+		key = "lt"
+	case token.TokenKindOperatorLeq:
+		key = "lte"
+	case token.TokenKindOperatorGtr:
+		key = "gt"
+	default:
+		key = "gte"
+	}
+
+	return Query{"range": Query{field: Query{key: v}}}, nil
+}
+
+func (t *translator) translateCombine(e *ast.CombineExpr, s scope) (Query, error) {
+	if e.Keyword == token.TokenKindKeywordOr {
+		var should []interface{}
+
+		for _, operand := range flatten(e, token.TokenKindKeywordOr) {
+			query, err := t.translate(operand, s)
+			if err != nil {
+				return nil, err
+			}
+
+			should = append(should, query)
+		}
+
+		return Query{"bool": Query{"should": should, "minimum_should_match": 1}}, nil
+	}
+
+	var must, mustNotQueries []interface{}
+
+	// AND and NOT between clauses(`a NOT b`) bind as tight as each other, see parser.PrecedenceKibana
+	for _, operand := range flattenAnd(e) {
+		query, err := t.translate(operand.expr, s)
+		if err != nil {
+			return nil, err
+		}
+
+		if operand.not {
+			mustNotQueries = append(mustNotQueries, query)
+		} else {
+			must = append(must, query)
+		}
+	}
+
+	boolQuery := Query{}
+	if len(must) > 0 {
+		boolQuery["must"] = must
+	}
+
+	if len(mustNotQueries) > 0 {
+		boolQuery["must_not"] = mustNotQueries
+	}
+
+	return Query{"bool": boolQuery}, nil
+}
+
+func translateExists(e *ast.ExistsExpr, s scope) Query {
+	query := Query{"exists": Query{"field": s.fieldName(e.Field)}}
+	if e.HasNot {
+		return mustNot(query)
+	}
+
+	return query
+}
+
+func (t *translator) translateNested(e *ast.NestedExpr, s scope) (Query, error) {
+	path := s.fieldName(e.Path)
+
+	query, err := t.translate(e.Expr, scope{path: path})
+	if err != nil {
+		return nil, err
+	}
+
+	nested := Query{"nested": Query{"path": path, "query": query, "score_mode": "none"}}
+	if e.HasNot {
+		return mustNot(nested), nil
+	}
+
+	return nested, nil
+}
+
+func mustNot(query Query) Query {
+	return Query{"bool": Query{"must_not": []interface{}{query}}}
+}
+
+// flatten collects the operands of the chain of combination expressions with the same keyword.
+func flatten(expr ast.Expr, keyword token.Kind) []ast.Expr {
+	e, ok := expr.(*ast.CombineExpr)
+	if !ok || e.Keyword != keyword {
+		return []ast.Expr{expr}
+	}
+
+	return append(flatten(e.LeftExpr, keyword), flatten(e.RightExpr, keyword)...)
+}
+
+type andOperand struct {
+	expr ast.Expr
+	not  bool
+}
+
+// flattenAnd collects the operands of the chain of AND/NOT combination expressions.
+func flattenAnd(expr ast.Expr) []andOperand {
+	e, ok := expr.(*ast.CombineExpr)
+	if !ok || e.Keyword == token.TokenKindKeywordOr {
+		return []andOperand{{expr: expr}}
+	}
+
+	operands := flattenAnd(e.LeftExpr)
+	if e.Keyword == token.TokenKindKeywordNot {
+		return append(operands, andOperand{expr: e.RightExpr, not: true})
+	}
+
+	return append(operands, flattenAnd(e.RightExpr)...)
+}
+
+// literalValue returns the value of lit, which is the value coerced by the parser(see kql.WithTypeResolver) if any,
+// numbers are kept as json.Number to preserve their representation.
+func literalValue(lit *ast.Literal) interface{} {
+	if lit.Coerced != nil {
+		return lit.Coerced
+	}
+
+	if lit.Kind == token.TokenKindInt || lit.Kind == token.TokenKindFloat {
+		return json.Number(lit.Value)
+	}
+
+	return lit.Value
+}
+
+// wildcardPattern returns the pattern of a wildcard query, the special characters of the values are escaped.
+func wildcardPattern(e *ast.WildcardExpr) string {
+	segments := e.Segments()
+	for i, segment := range segments {
+		segments[i] = escape(segment, `\*?`)
+	}
+
+	return strings.Join(segments, "*")
+}
+
+// queryStringPattern returns the query of a query_string query, the reserved characters of the values are escaped.
+func queryStringPattern(e *ast.WildcardExpr) string {
+	segments := e.Segments()
+	for i, segment := range segments {
+		segments[i] = escape(segment, `\+-=&|><!(){}[]^"~*?:/ `)
+	}
+
+	return strings.Join(segments, "*")
+}
+
+// escape escapes the characters of s that are in chars with backslash.
+func escape(s, chars string) string {
+	var buf strings.Builder
+
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			buf.WriteByte('\\')
+		}
+
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
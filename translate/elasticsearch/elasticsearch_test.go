@@ -0,0 +1,113 @@
+package elasticsearch_test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/token"
+	"github.com/laojianzi/kql-go/translate/elasticsearch"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestTranslate(t *testing.T) {
+	cases := []struct {
+		name      string
+		query     string
+		parseOpts []parser.Option
+		opts      []elasticsearch.Option
+	}{
+		{name: "match", query: `status: active`},
+		{name: "match_number", query: `status: 200`},
+		{name: "match_phrase", query: `message: "hello world"`},
+		{name: "term", query: `status: active`, opts: []elasticsearch.Option{elasticsearch.WithTermFields("status")}},
+		{name: "range", query: `age >= 18 AND latency < 1.5`},
+		{name: "range_date", query: `@timestamp >= now-7d/d AND @timestamp < "2024-01-01||+1M"`},
+		{
+			name:      "range_ip",
+			query:     `host.ip >= 10.0.0.0 AND host.ip < 10.0.1.0 AND version >= "1.2.3"`,
+			parseOpts: []parser.Option{parser.WithRangeMode(parser.RangeModeKibana)},
+		},
+		{name: "wildcard", query: `name: jo*n\*`},
+		{name: "exists", query: `name: *`},
+		{name: "not_exists", query: `NOT name: *`},
+		{name: "quoted_wildcard", query: `name: "*"`},
+		{name: "value_only", query: `error AND "connection refused"`},
+		{name: "value_only_wildcard", query: `err*r`},
+		{name: "or", query: `level: error OR level: warn OR level: fatal`},
+		{name: "not", query: `NOT level: debug`},
+		{name: "not_between_clauses", query: `level: error NOT service: api`},
+		{name: "precedence", query: `a: 1 OR b: 2 AND NOT c: 3`},
+		{name: "value_list", query: `level: ("error" OR warn) AND NOT service: (api OR web)`},
+		{name: "nested", query: `items: { name: "x" AND qty > 2 }`},
+		{name: "nested_in_nested", query: `NOT orders: { items: { sku: 1 } }`},
+		{name: "escaped_field", query: `\AND: 1`},
+		{name: "wildcard_field", query: `machine.os*: "windows 10" AND NOT labels.*: *`},
+		{name: "wildcard_field_wildcard", query: `machine.os*: win*`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stmt, err := parser.New(c.query, c.parseOpts...).Stmt()
+			require.NoError(t, err)
+
+			query, err := elasticsearch.Translate(stmt, c.opts...)
+			require.NoError(t, err)
+
+			got, err := json.MarshalIndent(query, "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", c.name+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o600))
+			}
+
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got)+"\n")
+
+			marshaled, err := elasticsearch.Marshal(stmt, c.opts...)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(marshaled))
+		})
+	}
+}
+
+func TestTranslate_Error(t *testing.T) {
+	cases := []struct {
+		name string
+		expr ast.Expr
+	}{
+		{
+			name: "wildcard in range",
+			expr: ast.NewBinaryExpr(0, ast.NewLiteral(0, 3, token.TokenKindIdent, "age", nil), token.TokenKindOperatorGtr, ast.NewWildcardExpr(
+				ast.NewLiteral(6, 8, token.TokenKindIdent, "1*", nil), []int{1},
+			), false),
+		},
+		{
+			name: "wildcard field in range",
+			expr: ast.NewBinaryExpr(0, ast.NewWildcardExpr(
+				ast.NewLiteral(0, 2, token.TokenKindIdent, "a*", nil), []int{1},
+			), token.TokenKindOperatorGtr, ast.NewLiteral(5, 6, token.TokenKindInt, "1", nil), false),
+		},
+		{
+			name: "nil expression",
+			expr: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := elasticsearch.Translate(c.expr)
+			assert.Error(t, err)
+		})
+	}
+}
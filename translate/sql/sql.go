@@ -0,0 +1,333 @@
+// Package sql compiles a KQL(kibana query language) expression(AST) into a parameterized SQL WHERE clause.
+package sql
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/token"
+)
+
+// Dialect describes the syntax that differs between SQL databases.
+type Dialect interface {
+	// Placeholder returns the placeholder of the n-th(starting from 1) bound parameter.
+	Placeholder(n int) string
+}
+
+type dollarDialect struct{}
+
+func (dollarDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+type questionDialect struct{}
+
+func (questionDialect) Placeholder(int) string {
+	return "?"
+}
+
+var (
+	// PostgreSQL uses `$1`, `$2`, ... as placeholders.
+	PostgreSQL Dialect = dollarDialect{}
+	// MySQL uses `?` as placeholders.
+	MySQL Dialect = questionDialect{}
+	// SQLite uses `?` as placeholders.
+	SQLite Dialect = questionDialect{}
+)
+
+// FieldMapper maps a KQL field to the column identifier that is written into the SQL verbatim,
+// it reports false if the field is not allowed to be queried.
+type FieldMapper func(field string) (column string, ok bool)
+
+// Allowlist returns a FieldMapper that only allows the fields in columns, each field is mapped to its column.
+func Allowlist(columns map[string]string) FieldMapper {
+	return func(field string) (string, bool) {
+		column, ok := columns[field]
+
+		return column, ok
+	}
+}
+
+// likeEscape is the escape character of LIKE patterns, it is not special in any string literal syntax.
+const likeEscape = '!'
+
+// Where compiles a KQL(kibana query language) expression(AST) into a SQL WHERE clause(without the WHERE keyword)
+// and the arguments of its placeholders.
+//
+//   - `field: value` is compiled into `column = ?`, `field: va*ue` into `column LIKE ? ESCAPE '!'`
+//   - `field: *` is compiled into `column IS NOT NULL` and `NOT field: *` into `column IS NULL`
+//   - `field > value`, `field >= value`, `field < value` and `field <= value` are compiled into comparisons
+//   - the dates and date math values(e.g. `now-7d/d`) of the comparisons are bound as time.Time,
+//     which are resolved with the resolver of WithDateResolver when compiled
+//   - AND/OR are compiled into AND/OR with parentheses and NOT into `NOT (...)`
+//
+// Every field goes through fields, a field that is not allowed is reported as an error.
+// Values without field, wildcard fields(see ast.ExpandFields) and nested field queries are not supported.
+func Where(expr ast.Expr, dialect Dialect, fields FieldMapper, opts ...Option) (string, []interface{}, error) {
+	c := &compiler{dialect: dialect, fields: fields}
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	if err := c.compile(expr, nil); err != nil {
+		return "", nil, err
+	}
+
+	return c.buf.String(), c.args, nil
+}
+
+// Option configures the compilation.
+type Option func(*options)
+
+type options struct {
+	dateResolver ast.DateResolver
+}
+
+// WithDateResolver resolves the dates and date math values(e.g. `now-7d/d`) of the comparisons with r,
+// which sets the clock of `now` and the time zone. The default is the zero ast.DateResolver, the current time in UTC.
+func WithDateResolver(r ast.DateResolver) Option {
+	return func(o *options) {
+		o.dateResolver = r
+	}
+}
+
+type compiler struct {
+	dialect Dialect
+	fields  FieldMapper
+	opts    options
+	buf     strings.Builder
+	args    []interface{}
+}
+
+// compile writes the SQL of expr, field is the field of the value list(e.g. `f: (v1 OR v2)`) expr belongs to.
+func (c *compiler) compile(expr ast.Expr, field ast.Expr) error {
+	switch e := expr.(type) {
+	case *ast.BinaryExpr:
+		return c.compileBinary(e, field)
+	case *ast.CombineExpr:
+		return c.compileCombine(e, field)
+	case *ast.ParenExpr:
+		return c.compile(e.Expr, field)
+	case *ast.NestedExpr:
+		return fmt.Errorf("nested field query is not supported: %s", e)
+	case *ast.ValueListExpr:
+		return c.compileValueList(e)
+	case *ast.ExistsExpr:
+		return c.compileExists(e)
+	}
+
+	return fmt.Errorf("unsupported expression %T: %s", expr, expr)
+}
+
+func (c *compiler) compileBinary(e *ast.BinaryExpr, field ast.Expr) error {
+	if e.HasNot {
+		c.buf.WriteString("NOT (")
+		defer c.buf.WriteByte(')')
+	}
+
+	op := e.Operator
+	if e.Field != nil {
+		field = e.Field
+	} else {
+		op = token.TokenKindOperatorEql
+	}
+
+	if paren, ok := e.Value.(*ast.ParenExpr); ok {
+		return c.compile(paren.Expr, field)
+	}
+
+	if field == nil {
+		return fmt.Errorf("value without field is not supported: %s", e)
+	}
+
+	column, err := c.column(field)
+	if err != nil {
+		return err
+	}
+
+	switch v := e.Value.(type) {
+	case *ast.WildcardExpr:
+		if op != token.TokenKindOperatorEql {
+			return fmt.Errorf("wildcard is not supported by operator %q: %s", op, e)
+		}
+
+		c.compileLike(column, v)
+
+		return nil
+	case *ast.Literal:
+		c.compileComparison(column, op, literalValue(v))
+
+		return nil
+	case *ast.DateExpr:
+		t, err := c.opts.dateResolver.Resolve(v, op)
+		if err != nil {
+			return err
+		}
+
+		c.compileComparison(column, op, t)
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported value %T: %s", e.Value, e.Value)
+}
+
+func (c *compiler) compileValueList(e *ast.ValueListExpr) error {
+	if e.HasNot {
+		c.buf.WriteString("NOT (")
+		defer c.buf.WriteByte(')')
+	}
+
+	return c.compile(e.Values, e.Field)
+}
+
+func (c *compiler) compileComparison(column string, op token.Kind, value interface{}) {
+	c.buf.WriteString(column)
+	c.buf.WriteByte(' ')
+
+	if op == token.TokenKindOperatorEql {
+		c.buf.WriteByte('=')
+	} else {
+		c.buf.WriteString(op.String())
+	}
+
+	c.buf.WriteByte(' ')
+	c.bind(value)
+}
+
+func (c *compiler) compileLike(column string, e *ast.WildcardExpr) {
+	segments := e.Segments()
+	for i, segment := range segments {
+		segments[i] = escapeLike(segment)
+	}
+
+	c.buf.WriteString(column)
+	c.buf.WriteString(" LIKE ")
+	c.bind(strings.Join(segments, "%"))
+	c.buf.WriteString(" ESCAPE '" + string(likeEscape) + "'")
+}
+
+func (c *compiler) compileExists(e *ast.ExistsExpr) error {
+	column, err := c.column(e.Field)
+	if err != nil {
+		return err
+	}
+
+	c.buf.WriteString(column)
+
+	if e.HasNot {
+		c.buf.WriteString(" IS NULL")
+	} else {
+		c.buf.WriteString(" IS NOT NULL")
+	}
+
+	return nil
+}
+
+func (c *compiler) compileCombine(e *ast.CombineExpr, field ast.Expr) error {
+	keyword := " AND "
+
+	switch e.Keyword {
+	case token.TokenKindKeywordOr:
+		keyword = " OR "
+	case token.TokenKindKeywordNot: // `a NOT b` means `a AND NOT b`
+		keyword = " AND NOT "
+	}
+
+	c.buf.WriteByte('(')
+
+	for i, operand := range flatten(e, e.Keyword) {
+		if i > 0 {
+			c.buf.WriteString(keyword)
+		}
+
+		if err := c.compile(operand, field); err != nil {
+			return err
+		}
+	}
+
+	c.buf.WriteByte(')')
+
+	return nil
+}
+
+// flatten collects the operands of the chain of combination expressions with the same keyword,
+// e.g. `(a AND b AND c)` instead of `((a AND b) AND c)`.
+func flatten(expr ast.Expr, keyword token.Kind) []ast.Expr {
+	e, ok := expr.(*ast.CombineExpr)
+	if !ok || e.Keyword != keyword {
+		return []ast.Expr{expr}
+	}
+
+	operands := flatten(e.LeftExpr, keyword)
+	if keyword == token.TokenKindKeywordNot { // only the left side of `a NOT b` can be flattened
+		return append(operands, e.RightExpr)
+	}
+
+	return append(operands, flatten(e.RightExpr, keyword)...)
+}
+
+// column maps field to its column.
+func (c *compiler) column(field ast.Expr) (string, error) {
+	lit, ok := field.(*ast.Literal)
+	if !ok {
+		return "", fmt.Errorf("wildcard field %q is not supported, expand it with ast.ExpandFields", field)
+	}
+
+	column, ok := c.fields(lit.Value)
+	if !ok {
+		return "", fmt.Errorf("field %q is not allowed", lit.Value)
+	}
+
+	return column, nil
+}
+
+// bind writes the placeholder of value and appends value to the arguments.
+func (c *compiler) bind(value interface{}) {
+	c.args = append(c.args, value)
+	c.buf.WriteString(c.dialect.Placeholder(len(c.args)))
+}
+
+// literalValue returns the argument of lit, which is the value coerced by the parser(see kql.WithTypeResolver)
+// if it is a type of database/sql/driver.Value, a net.IP is converted into its string form(e.g. "10.0.0.1")
+// instead of its raw bytes. Otherwise, int and float literals are converted into int64 and float64.
+func literalValue(lit *ast.Literal) interface{} {
+	switch v := lit.Coerced.(type) {
+	case int64, float64, bool, string, time.Time:
+		return v
+	case net.IP:
+		return v.String()
+	}
+
+	switch lit.Kind {
+	case token.TokenKindInt:
+		if v, err := lit.Int64(); err == nil {
+			return v
+		}
+	case token.TokenKindFloat:
+		if v, err := lit.Float64(); err == nil {
+			return v
+		}
+	}
+
+	return lit.Value
+}
+
+// escapeLike escapes the special characters of LIKE patterns in s.
+func escapeLike(s string) string {
+	var buf strings.Builder
+
+	for _, r := range s {
+		if r == '%' || r == '_' || r == likeEscape {
+			buf.WriteRune(likeEscape)
+		}
+
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,181 @@
+package sql_test
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/laojianzi/kql-go/ast"
+	"github.com/laojianzi/kql-go/parser"
+	"github.com/laojianzi/kql-go/schema"
+	"github.com/laojianzi/kql-go/translate/sql"
+)
+
+var columns = sql.Allowlist(map[string]string{
+	"status":  "status",
+	"age":     "age",
+	"latency": "latency_ms",
+	"name":    `"user_name"`,
+	"level":   "level",
+	"AND":     "and_column",
+})
+
+func TestWhere(t *testing.T) {
+	cases := []struct {
+		query    string
+		dialect  sql.Dialect
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			query:    `status: active`,
+			dialect:  sql.PostgreSQL,
+			want:     `status = $1`,
+			wantArgs: []interface{}{"active"},
+		},
+		{
+			query:    `age >= 18 AND latency < 1.5`,
+			dialect:  sql.PostgreSQL,
+			want:     `(age >= $1 AND latency_ms < $2)`,
+			wantArgs: []interface{}{int64(18), 1.5},
+		},
+		{
+			query:    `age >= 18 AND latency < 1.5`,
+			dialect:  sql.MySQL,
+			want:     `(age >= ? AND latency_ms < ?)`,
+			wantArgs: []interface{}{int64(18), 1.5},
+		},
+		{
+			query:    `name: jo*n_100%!\*`,
+			dialect:  sql.SQLite,
+			want:     `"user_name" LIKE ? ESCAPE '!'`,
+			wantArgs: []interface{}{"jo%n!_100!%!!*"},
+		},
+		{
+			query:   `name: *`,
+			dialect: sql.PostgreSQL,
+			want:    `"user_name" IS NOT NULL`,
+		},
+		{
+			query:   `NOT name: *`,
+			dialect: sql.PostgreSQL,
+			want:    `"user_name" IS NULL`,
+		},
+		{
+			query:    `status: a OR status: b AND NOT age > 1 OR status: c`,
+			dialect:  sql.PostgreSQL,
+			want:     `(status = $1 OR (status = $2 AND NOT (age > $3)) OR status = $4)`,
+			wantArgs: []interface{}{"a", "b", int64(1), "c"},
+		},
+		{
+			query:    `level: ("error" OR warn) AND NOT (status: a)`,
+			dialect:  sql.PostgreSQL,
+			want:     `((level = $1 OR level = $2) AND NOT (status = $3))`,
+			wantArgs: []interface{}{"error", "warn", "a"},
+		},
+		{
+			query:    `status: a NOT status: b NOT status: c`,
+			dialect:  sql.PostgreSQL,
+			want:     `(status = $1 AND NOT status = $2 AND NOT status = $3)`,
+			wantArgs: []interface{}{"a", "b", "c"},
+		},
+		{
+			query:    `age > "2024-01-01||+1d" AND age <= "2024-01-01T08:00:00Z||/h"`,
+			dialect:  sql.PostgreSQL,
+			want:     `(age > $1 AND age <= $2)`,
+			wantArgs: []interface{}{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 8, 59, 59, 999999999, time.UTC)},
+		},
+		{
+			query:    `\AND: 1`,
+			dialect:  sql.PostgreSQL,
+			want:     `and_column = $1`,
+			wantArgs: []interface{}{int64(1)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			stmt, err := parser.New(c.query).Stmt()
+			require.NoError(t, err)
+
+			where, args, err := sql.Where(stmt, c.dialect, columns)
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, where)
+			assert.Equal(t, c.wantArgs, args)
+		})
+	}
+}
+
+func TestWhere_Coerced(t *testing.T) {
+	types := schema.Schema{"age": schema.TypeLong, "status": schema.TypeKeyword}
+
+	stmt, err := parser.New(`age: "18" AND status: 200`, parser.WithTypeResolver(types)).Stmt()
+	require.NoError(t, err)
+
+	where, args, err := sql.Where(stmt, sql.PostgreSQL, columns)
+	require.NoError(t, err)
+	assert.Equal(t, `(age = $1 AND status = $2)`, where)
+	assert.Equal(t, []interface{}{int64(18), "200"}, args)
+
+	types = schema.Schema{"ip": schema.TypeIP, "ts": schema.TypeDate, "ok": schema.TypeBoolean}
+
+	stmt, err = parser.New(`ip: 10.0.0.1 AND ts: 2024 AND ok: true`, parser.WithTypeResolver(types)).Stmt()
+	require.NoError(t, err)
+
+	where, args, err = sql.Where(stmt, sql.PostgreSQL, sql.Allowlist(map[string]string{"ip": "ip", "ts": "ts", "ok": "ok"}))
+	require.NoError(t, err)
+	assert.Equal(t, `(ip = $1 AND ts = $2 AND ok = $3)`, where)
+
+	// the arguments are bound as they are by database/sql, e.g. the IP is not converted into its raw bytes
+	for i, arg := range args {
+		v, err := driver.DefaultParameterConverter.ConvertValue(arg)
+		require.NoError(t, err)
+		assert.Equal(t, arg, v, "argument %d", i+1)
+	}
+
+	assert.Equal(t, []interface{}{"10.0.0.1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true}, args)
+}
+
+func TestWhere_DateResolver(t *testing.T) {
+	stmt, err := parser.New(`age >= now-1d/d`).Stmt()
+	require.NoError(t, err)
+
+	loc := time.FixedZone("", 8*3600)
+	r := ast.DateResolver{
+		Clock:    func() time.Time { return time.Date(2024, 3, 14, 15, 20, 0, 0, time.UTC) },
+		Location: loc,
+	}
+
+	where, args, err := sql.Where(stmt, sql.PostgreSQL, columns, sql.WithDateResolver(r))
+	require.NoError(t, err)
+	assert.Equal(t, `age >= $1`, where)
+	require.Len(t, args, 1)
+	assert.True(t, time.Date(2024, 3, 13, 0, 0, 0, 0, loc).Equal(args[0].(time.Time)), "%s", args[0])
+}
+
+func TestWhere_Error(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{name: "field is not allowed", query: `password: secret`},
+		{name: "injected field is not allowed", query: `status;DROP: 1`},
+		{name: "value without field", query: `active`},
+		{name: "nested field query", query: `items: { name: x }`},
+		{name: "wildcard in range", query: `age > 1*`},
+		{name: "wildcard field", query: `stat*: active`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stmt, err := parser.New(c.query).Stmt()
+			require.NoError(t, err)
+
+			_, _, err = sql.Where(stmt, sql.PostgreSQL, columns)
+			assert.Error(t, err)
+		})
+	}
+}